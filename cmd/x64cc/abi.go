@@ -0,0 +1,381 @@
+package main
+
+import (
+	"github.com/andrewchambers/cc/parse"
+)
+
+// Classes of the SysV x86-64 calling convention.
+// Each eightbyte of a value is classified separately,
+// and values which do not fit in registers are passed in memory.
+type abiClass int
+
+const (
+	classNone abiClass = iota
+	classInteger
+	classSSE
+)
+
+// The backend only ever uses caller saved registers (%rax, %rcx, %rdx,
+// %rsi, %rdi, %r8-%r11 and the vector registers), so apart from %rbp,
+// which is saved in every prologue, callee saved registers are never
+// clobbered and need no spilling.
+var intArgRegs = [...]string{
+	"%rdi", "%rsi", "%rdx", "%rcx", "%r8", "%r9",
+}
+
+var sseArgRegs = [...]string{
+	"%xmm0", "%xmm1", "%xmm2", "%xmm3", "%xmm4", "%xmm5", "%xmm6", "%xmm7",
+}
+
+var intRetRegs = [...]string{"%rax", "%rdx"}
+var sseRetRegs = [...]string{"%xmm0", "%xmm1"}
+
+// Classify the eightbytes of a value of type ty.
+// Returns nil if the value must be passed in memory.
+func classify(ty parse.CType) []abiClass {
+	if !parse.IsStructType(ty) {
+		if parse.IsFloatType(ty) {
+			return []abiClass{classSSE}
+		}
+		return []abiClass{classInteger}
+	}
+	sz := getSize(ty)
+	if sz == 0 || sz > 16 {
+		return nil
+	}
+	classes := make([]abiClass, (sz+7)/8)
+	classifyAt(ty, 0, classes)
+	for idx, c := range classes {
+		// An eightbyte made up of only padding.
+		if c == classNone {
+			classes[idx] = classSSE
+		}
+	}
+	return classes
+}
+
+// Merge the classes of every scalar inside ty into the eightbytes
+// they occupy. INTEGER wins over SSE when they share an eightbyte.
+func classifyAt(ty parse.CType, offset int, classes []abiClass) {
+	switch ty := parse.Unqual(ty).(type) {
+	case *parse.CStruct:
+		layout := x64SzDesc.GetLayout(ty)
+		for idx, mty := range ty.Types {
+			classifyAt(mty, offset+layout.Offsets[idx], classes)
+		}
+	case *parse.Array:
+		sz := getSize(ty.MemberType)
+		for i := 0; i < ty.Dim; i++ {
+			classifyAt(ty.MemberType, offset+i*sz, classes)
+		}
+	default:
+		c := classInteger
+		if parse.IsFloatType(ty) {
+			c = classSSE
+		}
+		eb := offset / 8
+		if classes[eb] != classInteger {
+			classes[eb] = c
+		}
+	}
+}
+
+// Where an argument, parameter or return value lives.
+type abiLoc struct {
+	// The register holding each eightbyte,
+	// empty if the value is in memory.
+	regs []string
+	// Offset from the start of the stack argument area.
+	stackOffset int
+}
+
+func (l abiLoc) inMemory() bool {
+	return len(l.regs) == 0
+}
+
+// Returns true if values of type ty are returned through a hidden
+// pointer passed by the caller in %rdi.
+func isSret(ty parse.CType) bool {
+	return parse.IsStructType(ty) && classify(ty) == nil
+}
+
+func retLoc(ty parse.CType) abiLoc {
+	var loc abiLoc
+	nint, nsse := 0, 0
+	for _, c := range classify(ty) {
+		if c == classSSE {
+			loc.regs = append(loc.regs, sseRetRegs[nsse])
+			nsse += 1
+		} else {
+			loc.regs = append(loc.regs, intRetRegs[nint])
+			nint += 1
+		}
+	}
+	return loc
+}
+
+// Assign argument locations in order. A struct is only passed in
+// registers if all of its eightbytes fit, otherwise it goes on the
+// stack and later arguments may still use the remaining registers.
+// Also returns the size of the stack argument area.
+func argLocs(types []parse.CType, sret bool) ([]abiLoc, int) {
+	nint, nsse := 0, 0
+	if sret {
+		nint = 1
+	}
+	stacksz := 0
+	locs := make([]abiLoc, len(types))
+	for idx, ty := range types {
+		classes := classify(ty)
+		needint, needsse := 0, 0
+		for _, c := range classes {
+			if c == classSSE {
+				needsse += 1
+			} else {
+				needint += 1
+			}
+		}
+		if classes != nil && nint+needint <= len(intArgRegs) && nsse+needsse <= len(sseArgRegs) {
+			for _, c := range classes {
+				if c == classSSE {
+					locs[idx].regs = append(locs[idx].regs, sseArgRegs[nsse])
+					nsse += 1
+				} else {
+					locs[idx].regs = append(locs[idx].regs, intArgRegs[nint])
+					nint += 1
+				}
+			}
+			continue
+		}
+		align := getAlign(ty)
+		if align < 8 {
+			align = 8
+		}
+		stacksz = (stacksz + align - 1) &^ (align - 1)
+		locs[idx].stackOffset = stacksz
+		stacksz += (getSize(ty) + 7) &^ 7
+	}
+	return locs, stacksz
+}
+
+func isSSEReg(reg string) bool {
+	return reg[1] == 'x'
+}
+
+// Load n bytes at off(%src) into dst, which may be an SSE register.
+// Only whole objects are read, so the last eightbyte of a struct
+// never reads past its end. Clobbers %r10 and %r11.
+func (e *emitter) loadEightbyte(src string, off, n int, dst string) {
+	r := dst
+	if isSSEReg(dst) {
+		r = "%r11"
+	}
+	switch n {
+	case 8:
+		e.asm("movq %d(%%%s), %s\n", off, src, r)
+	default:
+		e.asm("xorl %%r10d, %%r10d\n")
+		for i := n - 1; i >= 0; i-- {
+			e.asm("shlq $8, %%r10\n")
+			e.asm("movzbq %d(%%%s), %%r11\n", off+i, src)
+			e.asm("orq %%r11, %%r10\n")
+		}
+		e.asm("movq %%r10, %s\n", r)
+	}
+	if r != dst {
+		e.asm("movq %s, %s\n", r, dst)
+	}
+}
+
+// Store the eightbyte in reg to off(%dst). The destination must have
+// room for the whole eightbyte.
+func (e *emitter) storeEightbyte(reg string, dst string, off int) {
+	e.asm("movq %s, %d(%%%s)\n", reg, off, dst)
+}
+
+// Copy sz bytes from (%rax) to (%dst), leaving the destination
+// address in %rax. Small copies are unrolled, larger ones use a loop.
+// Clobbers %rdx and %r11.
+func (e *emitter) copyMem(dst string, sz int) {
+	off := 0
+	if sz > 64 {
+		lloop := e.NextLabel()
+		e.asm("xorl %%r11d, %%r11d\n")
+		e.raw("%s:\n", lloop)
+		e.asm("movq (%%rax,%%r11), %%rdx\n")
+		e.asm("movq %%rdx, (%%%s,%%r11)\n", dst)
+		e.asm("addq $8, %%r11\n")
+		e.asm("cmpq $%d, %%r11\n", sz&^7)
+		e.asm("jb %s\n", lloop)
+		off = sz &^ 7
+	}
+	for off < sz {
+		switch {
+		case sz-off >= 8:
+			e.asm("movq %d(%%rax), %%rdx\n", off)
+			e.asm("movq %%rdx, %d(%%%s)\n", off, dst)
+			off += 8
+		case sz-off >= 4:
+			e.asm("movl %d(%%rax), %%edx\n", off)
+			e.asm("movl %%edx, %d(%%%s)\n", off, dst)
+			off += 4
+		case sz-off >= 2:
+			e.asm("movw %d(%%rax), %%dx\n", off)
+			e.asm("movw %%dx, %d(%%%s)\n", off, dst)
+			off += 2
+		default:
+			e.asm("movb %d(%%rax), %%dl\n", off)
+			e.asm("movb %%dl, %d(%%%s)\n", off, dst)
+			off += 1
+		}
+	}
+	e.asm("movq %%%s, %%rax\n", dst)
+}
+
+// Zero sz bytes at (%dst). Clobbers %rax and %r11.
+func (e *emitter) zeroMem(dst string, sz int) {
+	e.asm("xorl %%eax, %%eax\n")
+	off := 0
+	if sz > 64 {
+		lloop := e.NextLabel()
+		e.asm("xorl %%r11d, %%r11d\n")
+		e.raw("%s:\n", lloop)
+		e.asm("movq %%rax, (%%%s,%%r11)\n", dst)
+		e.asm("addq $8, %%r11\n")
+		e.asm("cmpq $%d, %%r11\n", sz&^7)
+		e.asm("jb %s\n", lloop)
+		off = sz &^ 7
+	}
+	for off < sz {
+		switch {
+		case sz-off >= 8:
+			e.asm("movq %%rax, %d(%%%s)\n", off, dst)
+			off += 8
+		case sz-off >= 4:
+			e.asm("movl %%eax, %d(%%%s)\n", off, dst)
+			off += 4
+		case sz-off >= 2:
+			e.asm("movw %%ax, %d(%%%s)\n", off, dst)
+			off += 2
+		default:
+			e.asm("movb %%al, %d(%%%s)\n", off, dst)
+			off += 1
+		}
+	}
+}
+
+// The register save area of a variadic function holds every integer
+// argument register followed by every vector register, so va_arg can
+// find arguments which were passed in registers.
+const (
+	regSaveIntSize = 8 * len(intArgRegs)
+	regSaveSize    = regSaveIntSize + 16*len(sseArgRegs)
+)
+
+func (e *emitter) saveArgRegs() {
+	e.regsaveoffset = e.allocTemp(regSaveSize)
+	for i, reg := range intArgRegs {
+		e.asm("movq %s, %d(%%rbp)\n", reg, e.regsaveoffset+8*i)
+	}
+	// Only doubles are read back, so the low eightbyte is enough.
+	for i, reg := range sseArgRegs {
+		e.asm("movq %s, %d(%%rbp)\n", reg, e.regsaveoffset+regSaveIntSize+16*i)
+	}
+}
+
+// Initialize the va_list pointed to by ap so the next argument
+// read is the first one after the named parameters.
+func (e *emitter) VaStart(v *parse.VaStart) {
+	fty := e.f.FuncType
+	sret := isSret(fty.RetType)
+	locs, stacksz := argLocs(fty.ArgTypes, sret)
+	nint, nsse := 0, 0
+	if sret {
+		nint += 1
+	}
+	for _, loc := range locs {
+		for _, reg := range loc.regs {
+			if isSSEReg(reg) {
+				nsse += 1
+			} else {
+				nint += 1
+			}
+		}
+	}
+	e.Expr(v.Ap)
+	e.asm("movl $%d, (%%rax)\n", 8*nint)
+	e.asm("movl $%d, 4(%%rax)\n", regSaveIntSize+16*nsse)
+	e.asm("leaq %d(%%rbp), %%rcx\n", 16+stacksz)
+	e.asm("movq %%rcx, 8(%%rax)\n")
+	e.asm("leaq %d(%%rbp), %%rcx\n", e.regsaveoffset)
+	e.asm("movq %%rcx, 16(%%rax)\n")
+}
+
+// Fetch the next variadic argument following the algorithm in the
+// SysV ABI: take it from the register save area if all of its
+// eightbytes are still available there, else from the overflow area.
+func (e *emitter) VaArg(v *parse.VaArg) {
+	ty := v.Type
+	classes := classify(ty)
+	lstack := e.NextLabel()
+	ldone := e.NextLabel()
+	e.Expr(v.Ap)
+	e.asm("movq %%rax, %%rcx\n")
+	if classes != nil {
+		nint, nsse := 0, 0
+		for _, c := range classes {
+			if c == classSSE {
+				nsse += 1
+			} else {
+				nint += 1
+			}
+		}
+		if nint != 0 {
+			e.asm("cmpl $%d, (%%rcx)\n", regSaveIntSize-8*nint)
+			e.asm("ja %s\n", lstack)
+		}
+		if nsse != 0 {
+			e.asm("cmpl $%d, 4(%%rcx)\n", regSaveSize-16*nsse)
+			e.asm("ja %s\n", lstack)
+		}
+		switch {
+		case nsse == 0:
+			// Integer eightbytes are contiguous in the save area.
+			e.asm("movl (%%rcx), %%eax\n")
+			e.asm("addq 16(%%rcx), %%rax\n")
+			e.asm("addl $%d, (%%rcx)\n", 8*nint)
+		case nint == 0 && nsse == 1:
+			e.asm("movl 4(%%rcx), %%eax\n")
+			e.asm("addq 16(%%rcx), %%rax\n")
+			e.asm("addl $16, 4(%%rcx)\n")
+		default:
+			// Gather the eightbytes into a temporary.
+			tmp := e.allocTemp(getSize(ty))
+			for i, c := range classes {
+				field := "(%rcx)"
+				stride := 8
+				if c == classSSE {
+					field = "4(%rcx)"
+					stride = 16
+				}
+				e.asm("movl %s, %%edx\n", field)
+				e.asm("addq 16(%%rcx), %%rdx\n")
+				e.asm("movq (%%rdx), %%rdx\n")
+				e.asm("movq %%rdx, %d(%%rbp)\n", tmp+8*i)
+				e.asm("addl $%d, %s\n", stride, field)
+			}
+			e.asm("leaq %d(%%rbp), %%rax\n", tmp)
+		}
+		e.asm("jmp %s\n", ldone)
+	}
+	e.raw("%s:\n", lstack)
+	e.asm("movq 8(%%rcx), %%rax\n")
+	if align := getAlign(ty); align > 8 {
+		e.asm("addq $%d, %%rax\n", align-1)
+		e.asm("andq $%d, %%rax\n", -align)
+	}
+	e.asm("leaq %d(%%rax), %%rdx\n", (getSize(ty)+7)&^7)
+	e.asm("movq %%rdx, 8(%%rcx)\n")
+	e.raw("%s:\n", ldone)
+	e.LoadFromPtr("rax", ty)
+}
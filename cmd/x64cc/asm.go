@@ -0,0 +1,432 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andrewchambers/cc/parse"
+)
+
+// A general purpose register, with its names for each operand size,
+// and for the first four, the name of its second byte.
+type x64Reg struct {
+	q, k, w, b, h string
+}
+
+var x64Regs = []x64Reg{
+	{"rax", "eax", "ax", "al", "ah"},
+	{"rbx", "ebx", "bx", "bl", "bh"},
+	{"rcx", "ecx", "cx", "cl", "ch"},
+	{"rdx", "edx", "dx", "dl", "dh"},
+	{"rsi", "esi", "si", "sil", ""},
+	{"rdi", "edi", "di", "dil", ""},
+	{"rbp", "ebp", "bp", "bpl", ""},
+	{"rsp", "esp", "sp", "spl", ""},
+	{"r8", "r8d", "r8w", "r8b", ""},
+	{"r9", "r9d", "r9w", "r9b", ""},
+	{"r10", "r10d", "r10w", "r10b", ""},
+	{"r11", "r11d", "r11w", "r11b", ""},
+	{"r12", "r12d", "r12w", "r12b", ""},
+	{"r13", "r13d", "r13w", "r13b", ""},
+	{"r14", "r14d", "r14w", "r14b", ""},
+	{"r15", "r15d", "r15w", "r15b", ""},
+}
+
+const (
+	regRAX = 0
+	regRBX = 1
+	regRCX = 2
+	regRDX = 3
+	regRSI = 4
+	regRDI = 5
+	regRBP = 6
+	regRSP = 7
+)
+
+// Registers given by single letter constraints.
+var asmRegConstraints = map[rune]int{
+	'a': regRAX,
+	'b': regRBX,
+	'c': regRCX,
+	'd': regRDX,
+	'S': regRSI,
+	'D': regRDI,
+}
+
+// Registers which an asm operand can be allocated, caller saved
+// registers first as using the others means saving them.
+var asmRegOrder = []int{regRAX, regRCX, regRDX, regRSI, regRDI, 8, 9, 10, 11, regRBX, 12, 13, 14, 15}
+
+// Registers the ABI requires a function to preserve.
+func isCalleeSaved(r int) bool {
+	return r == regRBX || r >= 12
+}
+
+// Returns the register named by a clobber, accepting any of its names
+// with or without a leading %, or -1.
+func regByName(name string) int {
+	name = strings.TrimPrefix(name, "%")
+	for i, r := range x64Regs {
+		if name == r.q || name == r.k || name == r.w || name == r.b || (r.h != "" && name == r.h) {
+			return i
+		}
+	}
+	return -1
+}
+
+const (
+	asmInReg = iota
+	asmInMem
+	asmImm
+)
+
+// Where an operand is placed for an asm statement.
+type asmPlace struct {
+	op   *parse.AsmOperand
+	ty   parse.CType
+	kind int
+	reg  int
+	// The text of a memory operand, or the register which
+	// holds its address if it is empty.
+	mem string
+	imm int64
+	// Frame slots holding the address of an output or memory
+	// operand, and the value of a register operand, or 0.
+	addr int
+	val  int
+}
+
+// Splits a constraint into its modifiers and the letters giving
+// the places the operand may be in.
+func splitConstraint(c string) (string, string) {
+	i := strings.IndexFunc(c, func(r rune) bool {
+		return !strings.ContainsRune("=+&%", r)
+	})
+	if i < 0 {
+		return c, ""
+	}
+	return c[:i], c[i:]
+}
+
+// Emit an asm statement. The operands are evaluated first and kept
+// in the frame, then loaded into the registers the asm reads, and
+// after it the registers holding outputs are stored. As no value is
+// kept in a register across statements, a memory clobber needs no
+// more than that.
+func (e *emitter) Asm(a *parse.Asm) {
+	if a.Basic {
+		e.raw("%s\n", a.Template)
+		return
+	}
+	nout := len(a.Outputs)
+	var places []*asmPlace
+	for i := range a.Outputs {
+		places = append(places, &asmPlace{op: &a.Outputs[i]})
+	}
+	for i := range a.Inputs {
+		places = append(places, &asmPlace{op: &a.Inputs[i]})
+	}
+	// Registers which cannot be allocated to an operand, because
+	// they are clobbered or already hold an operand.
+	taken := make(map[int]bool)
+	// Callee saved registers which must be preserved.
+	saved := make(map[int]bool)
+	for _, c := range a.Clobbers {
+		if c == "memory" || c == "cc" {
+			continue
+		}
+		r := regByName(c)
+		switch {
+		case r < 0:
+			e.errorPos(a.Pos, "unknown register name %s in asm", c)
+		case r == regRBP || r == regRSP:
+			e.errorPos(a.Pos, "%s cannot be clobbered by asm", c)
+		}
+		taken[r] = true
+		if isCalleeSaved(r) {
+			saved[r] = true
+		}
+	}
+	// Fixed registers, memory and immediates first, so general
+	// registers are allocated from what is left.
+	outRegs := make(map[int]bool)
+	inRegs := make(map[int]bool)
+	var general, tied []*asmPlace
+	for i, pl := range places {
+		isOut := i < nout
+		pl.ty = pl.op.Expr.GetType()
+		if _, ok := pl.op.Matching(); ok && !isOut {
+			tied = append(tied, pl)
+			continue
+		}
+		mods, letters := splitConstraint(pl.op.Constraint)
+		pl.kind = -1
+		for _, c := range letters {
+			if r, ok := asmRegConstraints[c]; ok {
+				used := inRegs
+				if isOut {
+					used = outRegs
+				}
+				if used[r] || taken[r] {
+					e.errorPos(pl.op.Pos, "register %s is used by more than one asm operand or clobber", x64Regs[r].q)
+				}
+				used[r] = true
+				if isOut && strings.ContainsAny(mods, "&+") {
+					// No input may share the register.
+					inRegs[r] = true
+				}
+				pl.kind = asmInReg
+				pl.reg = r
+				if isCalleeSaved(r) {
+					saved[r] = true
+				}
+				break
+			}
+		}
+		switch {
+		case pl.kind >= 0:
+		case strings.ContainsAny(letters, "rqgRQX"):
+			pl.kind = asmInReg
+			pl.reg = -1
+			general = append(general, pl)
+		case strings.ContainsAny(letters, "moV"):
+			pl.kind = asmInMem
+			pl.mem = e.asmMem(pl.op.Expr)
+			if pl.mem == "" {
+				general = append(general, pl)
+			}
+		case strings.ContainsAny(letters, "in"):
+			c, ok := pl.op.Expr.(*parse.Constant)
+			if !ok || isOut {
+				e.errorPos(pl.op.Pos, "impossible constraint in asm")
+			}
+			pl.kind = asmImm
+			pl.imm = c.Val
+		default:
+			e.errorPos(pl.op.Pos, "unsupported constraint %q in asm", pl.op.Constraint)
+		}
+		if pl.kind == asmInReg && !parse.IsScalarType(pl.ty) {
+			e.errorPos(pl.op.Pos, "impossible constraint in asm")
+		}
+	}
+	for r := range inRegs {
+		taken[r] = true
+	}
+	for r := range outRegs {
+		taken[r] = true
+	}
+	for _, pl := range general {
+		pl.reg = -1
+		for _, r := range asmRegOrder {
+			if !taken[r] {
+				pl.reg = r
+				break
+			}
+		}
+		if pl.reg < 0 {
+			e.errorPos(pl.op.Pos, "asm operand has impossible constraints, no register is left")
+		}
+		taken[pl.reg] = true
+		if isCalleeSaved(pl.reg) {
+			saved[pl.reg] = true
+		}
+	}
+	for _, pl := range tied {
+		n, _ := pl.op.Matching()
+		out := places[n]
+		if out.kind != asmInReg {
+			e.errorPos(pl.op.Pos, "matching constraint for a memory operand is not supported")
+		}
+		pl.kind = asmInReg
+		pl.reg = out.reg
+	}
+	// Evaluate the operands in order.
+	for i, pl := range places {
+		isOut := i < nout
+		switch {
+		case pl.kind == asmImm:
+		case pl.kind == asmInMem && pl.mem != "":
+		case pl.kind == asmInMem:
+			e.GetAddr(pl.op.Expr)
+			pl.addr = e.allocTemp(8)
+			e.asm("movq %%rax, %d(%%rbp)\n", pl.addr)
+		case isOut:
+			e.GetAddr(pl.op.Expr)
+			pl.addr = e.allocTemp(8)
+			e.asm("movq %%rax, %d(%%rbp)\n", pl.addr)
+			pl.val = e.allocTemp(8)
+			if strings.HasPrefix(pl.op.Constraint, "+") {
+				e.LoadFromPtr("rax", pl.ty)
+				e.asm("movq %%rax, %d(%%rbp)\n", pl.val)
+			}
+		default:
+			e.Expr(pl.op.Expr)
+			pl.val = e.allocTemp(8)
+			e.asm("movq %%rax, %d(%%rbp)\n", pl.val)
+		}
+	}
+	var pushed []int
+	for _, r := range asmRegOrder {
+		if saved[r] {
+			e.push(x64Regs[r].q)
+			pushed = append(pushed, r)
+		}
+	}
+	for i, pl := range places {
+		isOut := i < nout
+		switch {
+		case pl.kind == asmInMem && pl.mem == "":
+			e.asm("movq %d(%%rbp), %%%s\n", pl.addr, x64Regs[pl.reg].q)
+		case pl.kind != asmInReg:
+		case !isOut || strings.HasPrefix(pl.op.Constraint, "+"):
+			e.asm("movq %d(%%rbp), %%%s\n", pl.val, x64Regs[pl.reg].q)
+		}
+	}
+	e.raw("%s\n", e.asmTemplate(a, places))
+	for _, pl := range places[:nout] {
+		if pl.kind == asmInReg {
+			e.asm("movq %%%s, %d(%%rbp)\n", x64Regs[pl.reg].q, pl.val)
+		}
+	}
+	for i := len(pushed) - 1; i >= 0; i-- {
+		e.pop(x64Regs[pushed[i]].q)
+	}
+	for _, pl := range places[:nout] {
+		if pl.kind == asmInReg {
+			e.asm("movq %d(%%rbp), %%rax\n", pl.val)
+			e.asm("movq %d(%%rbp), %%rcx\n", pl.addr)
+			e.StoreToPtr("rcx", pl.ty)
+		}
+	}
+}
+
+// Returns the text of a memory operand which can be addressed
+// directly, or "" if its address must be computed into a register.
+func (e *emitter) asmMem(n parse.Expr) string {
+	ident, ok := n.(*parse.Ident)
+	if !ok {
+		return ""
+	}
+	switch sym := ident.Sym.(type) {
+	case *parse.LSymbol:
+		if offset, ok := e.loffsets[sym]; ok && !parse.IsVariableSize(sym.Type) {
+			return fmt.Sprintf("%d(%%rbp)", offset)
+		}
+	case *parse.GSymbol:
+		if !sym.Weak {
+			return fmt.Sprintf("%s(%%rip)", sym.Label)
+		}
+	}
+	return ""
+}
+
+// Substitutes the operands into the template of an asm statement.
+// An operand may be preceded by a modifier: b, h, w, k or q for the
+// name of a register of that size, or c for an immediate without $.
+func (e *emitter) asmTemplate(a *parse.Asm, places []*asmPlace) string {
+	t := a.Template
+	var b strings.Builder
+	unique := ""
+	for i := 0; i < len(t); i++ {
+		if t[i] != '%' {
+			b.WriteByte(t[i])
+			continue
+		}
+		i++
+		if i == len(t) {
+			e.errorPos(a.Pos, "invalid %% at end of asm template")
+		}
+		switch t[i] {
+		case '%':
+			b.WriteByte('%')
+			continue
+		case '=':
+			// A number unique to each asm statement, for labels.
+			if unique == "" {
+				e.labelcounter += 1
+				unique = strconv.Itoa(e.labelcounter)
+			}
+			b.WriteString(unique)
+			continue
+		}
+		var mod byte
+		if strings.IndexByte("bhwkqc", t[i]) >= 0 {
+			mod = t[i]
+			i++
+		}
+		n := -1
+		switch {
+		case i < len(t) && t[i] == '[':
+			end := strings.IndexByte(t[i:], ']')
+			if end < 0 {
+				e.errorPos(a.Pos, "invalid operand name in asm template")
+			}
+			name := t[i+1 : i+end]
+			for idx, pl := range places {
+				if pl.op.Name == name {
+					n = idx
+				}
+			}
+			if n < 0 {
+				e.errorPos(a.Pos, "undefined named operand %s in asm", name)
+			}
+			i += end
+		case i < len(t) && t[i] >= '0' && t[i] <= '9':
+			n = 0
+			for i < len(t) && t[i] >= '0' && t[i] <= '9' {
+				n = n*10 + int(t[i]-'0')
+				i++
+			}
+			i--
+			if n >= len(places) {
+				e.errorPos(a.Pos, "operand number %d out of range in asm", n)
+			}
+		default:
+			e.errorPos(a.Pos, "invalid operand in asm template")
+		}
+		b.WriteString(e.asmOperand(a, places[n], mod))
+	}
+	return b.String()
+}
+
+func (e *emitter) asmOperand(a *parse.Asm, pl *asmPlace, mod byte) string {
+	switch pl.kind {
+	case asmImm:
+		if mod == 'c' {
+			return strconv.FormatInt(pl.imm, 10)
+		}
+		return "$" + strconv.FormatInt(pl.imm, 10)
+	case asmInMem:
+		if pl.mem != "" {
+			return pl.mem
+		}
+		return "(%" + x64Regs[pl.reg].q + ")"
+	}
+	r := x64Regs[pl.reg]
+	sz := getSize(pl.ty)
+	switch mod {
+	case 'b':
+		sz = 1
+	case 'w':
+		sz = 2
+	case 'k':
+		sz = 4
+	case 'q':
+		sz = 8
+	case 'h':
+		if r.h == "" {
+			e.errorPos(a.Pos, "invalid use of %%h with %s in asm", r.q)
+		}
+		return "%" + r.h
+	}
+	switch sz {
+	case 1:
+		return "%" + r.b
+	case 2:
+		return "%" + r.w
+	case 4:
+		return "%" + r.k
+	}
+	return "%" + r.q
+}
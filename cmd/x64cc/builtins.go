@@ -0,0 +1,151 @@
+package main
+
+import (
+	"github.com/andrewchambers/cc/parse"
+)
+
+// Emit a call to a builtin as inline instructions.
+func (e *emitter) BuiltinCall(b *parse.BuiltinCall) {
+	switch b.Name {
+	case "__builtin_trap", "__builtin_unreachable":
+		// Reaching __builtin_unreachable is undefined, trapping
+		// is kinder than running into whatever code follows.
+		e.asm("ud2\n")
+	case "__builtin_memcpy", "__builtin_memset":
+		e.Expr(b.Args[0])
+		e.push("rax")
+		e.Expr(b.Args[1])
+		e.push("rax")
+		e.Expr(b.Args[2])
+		e.asm("movq %%rax, %%rcx\n")
+		if b.Name == "__builtin_memcpy" {
+			e.pop("rsi")
+		} else {
+			e.pop("rax")
+		}
+		e.pop("rdi")
+		e.asm("movq %%rdi, %%rdx\n")
+		if b.Name == "__builtin_memcpy" {
+			e.asm("rep movsb\n")
+		} else {
+			e.asm("rep stosb\n")
+		}
+		e.asm("movq %%rdx, %%rax\n")
+	case "__builtin_clz", "__builtin_clzl", "__builtin_clzll":
+		// The index of the highest set bit, counted from the other end.
+		e.Expr(b.Args[0])
+		if getSize(b.Args[0].GetType()) == 4 {
+			e.asm("bsrl %%eax, %%eax\n")
+			e.asm("xorl $31, %%eax\n")
+		} else {
+			e.asm("bsrq %%rax, %%rax\n")
+			e.asm("xorq $63, %%rax\n")
+		}
+	case "__builtin_ctz", "__builtin_ctzl", "__builtin_ctzll":
+		e.Expr(b.Args[0])
+		e.asm("bsfq %%rax, %%rax\n")
+	case "__builtin_popcount", "__builtin_popcountl", "__builtin_popcountll":
+		e.Expr(b.Args[0])
+		e.popcount()
+	case "__builtin_bswap16":
+		e.Expr(b.Args[0])
+		e.asm("rolw $8, %%ax\n")
+		e.asm("movzwq %%ax, %%rax\n")
+	case "__builtin_bswap32":
+		e.Expr(b.Args[0])
+		e.asm("bswapl %%eax\n")
+	case "__builtin_bswap64":
+		e.Expr(b.Args[0])
+		e.asm("bswapq %%rax\n")
+	case "__builtin_add_overflow", "__builtin_sub_overflow", "__builtin_mul_overflow":
+		e.overflow(b)
+	default:
+		panic(b.Name)
+	}
+}
+
+// Count the set bits of %rax. popcnt is not part of the baseline
+// x86-64, so the bits are summed in parallel within each byte, and
+// the bytes summed by a multiply. Clobbers %rcx and %rdx.
+func (e *emitter) popcount() {
+	e.asm("movq %%rax, %%rcx\n")
+	e.asm("shrq $1, %%rcx\n")
+	e.asm("movabsq $0x5555555555555555, %%rdx\n")
+	e.asm("andq %%rdx, %%rcx\n")
+	e.asm("subq %%rcx, %%rax\n")
+	e.asm("movabsq $0x3333333333333333, %%rdx\n")
+	e.asm("movq %%rax, %%rcx\n")
+	e.asm("andq %%rdx, %%rax\n")
+	e.asm("shrq $2, %%rcx\n")
+	e.asm("andq %%rdx, %%rcx\n")
+	e.asm("addq %%rcx, %%rax\n")
+	e.asm("movq %%rax, %%rcx\n")
+	e.asm("shrq $4, %%rcx\n")
+	e.asm("addq %%rcx, %%rax\n")
+	e.asm("movabsq $0x0f0f0f0f0f0f0f0f, %%rdx\n")
+	e.asm("andq %%rdx, %%rax\n")
+	e.asm("movabsq $0x0101010101010101, %%rdx\n")
+	e.asm("imulq %%rdx, %%rax\n")
+	e.asm("shrq $56, %%rax\n")
+}
+
+// Store a op b to *res and set %rax to 1 if it overflowed the type of
+// *res. Operands of up to 64 bits, signed or not, are extended to 128
+// bits, so a sum or difference is exact. A product may not be, but
+// only when it is at least 2^127, and it then reads as a negative
+// value too large to fit any result type, so overflow is still found.
+// The result fits if its low 64 bits survive truncation to the type
+// of *res, and its high 64 bits extend them.
+func (e *emitter) overflow(b *parse.BuiltinCall) {
+	ty := parse.Unqual(parse.Unqual(b.Args[2].GetType()).(*parse.Ptr).PointsTo)
+	e.Expr(b.Args[0])
+	e.push("rax")
+	e.Expr(b.Args[1])
+	e.push("rax")
+	e.Expr(b.Args[2])
+	e.asm("movq %%rax, %%rdi\n")
+	e.pop("rcx")
+	e.pop("rax")
+	// The high halves of the operands, in %r8 and %r9.
+	for i, hi := range []string{"r8", "r9"} {
+		lo := []string{"rax", "rcx"}[i]
+		if parse.IsSignedIntType(b.Args[i].GetType()) {
+			e.asm("movq %%%s, %%%s\n", lo, hi)
+			e.asm("sarq $63, %%%s\n", hi)
+		} else {
+			e.asm("xorl %%%sd, %%%sd\n", hi, hi)
+		}
+	}
+	switch b.Name {
+	case "__builtin_add_overflow":
+		e.asm("addq %%rcx, %%rax\n")
+		e.asm("adcq %%r9, %%r8\n")
+		e.asm("movq %%r8, %%rdx\n")
+	case "__builtin_sub_overflow":
+		e.asm("subq %%rcx, %%rax\n")
+		e.asm("sbbq %%r9, %%r8\n")
+		e.asm("movq %%r8, %%rdx\n")
+	default:
+		// The cross products only affect the high half.
+		e.asm("imulq %%rcx, %%r8\n")
+		e.asm("imulq %%rax, %%r9\n")
+		e.asm("addq %%r9, %%r8\n")
+		e.asm("mulq %%rcx\n")
+		e.asm("addq %%r8, %%rdx\n")
+	}
+	e.asm("movq %%rax, %%rsi\n")
+	e.extendRax(ty)
+	e.asm("cmpq %%rax, %%rsi\n")
+	e.asm("setne %%cl\n")
+	if parse.IsSignedIntType(ty) {
+		e.asm("movq %%rax, %%r8\n")
+		e.asm("sarq $63, %%r8\n")
+	} else {
+		e.asm("xorl %%r8d, %%r8d\n")
+	}
+	e.asm("cmpq %%r8, %%rdx\n")
+	e.asm("setne %%r8b\n")
+	e.asm("orb %%r8b, %%cl\n")
+	e.StoreToPtr("rdi", ty)
+	e.asm("movzbq %%cl, %%rax\n")
+}
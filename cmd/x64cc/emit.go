@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/andrewchambers/cc/cpp"
 	"github.com/andrewchambers/cc/parse"
 	"io"
+	"strconv"
+	"strings"
 )
 
 type emitter struct {
@@ -12,6 +15,53 @@ type emitter struct {
 	labelcounter int
 	loffsets     map[*parse.LSymbol]int
 	f            *parse.CFunc
+	// Locals aligned more strictly than the 16 byte aligned frame
+	// pointer live in a block realigned at run time. Their offsets
+	// are from the end of the block, whose address is in the slot
+	// at alignbase.
+	aoffsets  map[*parse.LSymbol]int
+	alignbase int
+	// Lowest offset from %rbp used by the current frame.
+	frameoffset int
+	// Slot holding the hidden return pointer, if any.
+	sretoffset int
+	// Register save area of a variadic function.
+	regsaveoffset int
+	// Number of eightbytes pushed below the frame,
+	// used to keep calls 16 byte aligned.
+	depth int
+	// Variable length arrays and variables with cleanup functions
+	// in scope, innermost last, and the number in scope at each
+	// label, so jumps out of their scope can free or clean them up.
+	scopes     []scope
+	vlasave    map[*parse.LSymbol]int
+	labeldepth map[string]int
+}
+
+// A scope entry is either the slot holding %rsp from before the
+// allocation of a variable length array, or a variable whose
+// cleanup function must be called when it goes out of scope.
+type scope struct {
+	vlasave int
+	cleanup *parse.LSymbol
+}
+
+func (e *emitter) push(reg string) {
+	e.asm("pushq %%%s\n", reg)
+	e.depth += 1
+}
+
+func (e *emitter) pop(reg string) {
+	e.asm("popq %%%s\n", reg)
+	e.depth -= 1
+}
+
+// Reserve frame space for a temporary such as a struct returned
+// by a call, returning its offset from %rbp.
+func (e *emitter) allocTemp(sz int) int {
+	sz = (sz + 7) &^ 7
+	e.frameoffset -= sz
+	return e.frameoffset
 }
 
 func (e *emitter) NextLabel() string {
@@ -19,10 +69,29 @@ func (e *emitter) NextLabel() string {
 	return fmt.Sprintf(".LL%d", e.labelcounter)
 }
 
-func Emit(tu *parse.TranslationUnit, o io.Writer) error {
+// An error in the program found while emitting it, such as an asm
+// constraint which cannot be met on this target.
+type emitError struct {
+	err error
+}
+
+func (e *emitter) errorPos(pos cpp.FilePos, m string, vals ...interface{}) {
+	panic(emitError{cpp.ErrWithLoc(fmt.Errorf(m, vals...), pos)})
+}
+
+func Emit(tu *parse.TranslationUnit, o io.Writer) (err error) {
 	e := &emitter{
 		o: o,
 	}
+	defer func() {
+		if r := recover(); r != nil {
+			ee, ok := r.(emitError)
+			if !ok {
+				panic(r)
+			}
+			err = ee.err
+		}
+	}()
 
 	for _, init := range tu.AnonymousInits {
 		switch init := init.(type) {
@@ -39,24 +108,61 @@ func Emit(tu *parse.TranslationUnit, o io.Writer) error {
 		switch tl := tl.(type) {
 		case *parse.CFunc:
 			e.CFunc(tl)
+		case *parse.Asm:
+			// Like a function, file scope asm starts in .text.
+			e.raw(".text\n")
+			e.raw("%s\n", tl.Template)
 		case *parse.DeclList:
-			if tl.Storage == parse.SC_TYPEDEF {
-				continue
-			}
-			for idx, decl := range tl.Symbols {
-				global, ok := decl.(*parse.GSymbol)
-				if !ok {
-					panic("internal error")
-				}
-				e.Global(global, tl.Inits[idx])
-			}
+			// Declarations emit nothing, objects
+			// they define are listed separately.
 		default:
 			panic(tl)
 		}
 	}
+	for _, g := range tu.Objects {
+		e.Global(g)
+	}
+	for _, g := range tu.Symbols {
+		e.symbolDirectives(g)
+	}
 	return nil
 }
 
+// Emit the directives for the attributes of a symbol which do not
+// depend on whether it is defined, and the entries in .init_array
+// and .fini_array for constructors and destructors.
+func (e *emitter) symbolDirectives(g *parse.GSymbol) {
+	if g.Alias != "" {
+		if !g.Internal && !g.Weak {
+			e.raw(".global %s\n", g.Label)
+		}
+		e.raw(".set %s, %s\n", g.Label, g.Alias)
+	}
+	if g.Weak {
+		e.raw(".weak %s\n", g.Label)
+	}
+	switch g.Visibility {
+	case "hidden", "protected", "internal":
+		e.raw(".%s %s\n", g.Visibility, g.Label)
+	}
+	if g.Constructor {
+		e.arrayEntry(".init_array", g.CtorPriority, g.Label)
+	}
+	if g.Destructor {
+		e.arrayEntry(".fini_array", g.DtorPriority, g.Label)
+	}
+}
+
+// Entries with a priority are sorted by the linker, lower first.
+func (e *emitter) arrayEntry(section string, priority int, label string) {
+	if priority != 0 {
+		section = fmt.Sprintf("%s.%05d", section, priority)
+	}
+	e.raw(".section %s,\"aw\"\n", section)
+	e.raw(".align 8\n")
+	e.raw(".quad %s\n", label)
+}
+
 func (e *emitter) raw(s string, args ...interface{}) {
 	_, err := fmt.Fprintf(e.o, s, args...)
 	if err != nil {
@@ -70,48 +176,162 @@ func (e *emitter) asm(s string, args ...interface{}) {
 	e.raw("  "+s, args...)
 }
 
-func (e *emitter) Global(g *parse.GSymbol, init parse.Expr) {
-	_, ok := g.Type.(*parse.CFuncT)
-	if ok {
-		return
+func (e *emitter) Global(g *parse.GSymbol) {
+	init := g.Init
+	if g.Section != "" {
+		e.raw(".section %s,\"aw\",@progbits\n", g.Section)
+	} else {
+		e.raw("%s\n", globalSection(g))
+	}
+	if !g.Internal && !g.Weak {
+		e.raw(".global %s\n", g.Label)
+	}
+	align := getAlign(g.Type)
+	if g.Align > align {
+		align = g.Align
 	}
-	e.raw(".data\n")
-	e.raw(".global %s\n", g.Label)
+	e.raw(".align %d\n", align)
 	if init == nil {
-		e.raw(".lcomm %s, %d\n", g.Label, getSize(g.Type))
+		e.raw("%s:\n", g.Label)
+		e.raw(".zero %d\n", getSize(g.Type))
+	} else if agg, ok := init.(*parse.Initializer); ok {
+		e.raw("%s:\n", g.Label)
+		e.staticInit(agg)
 	} else {
 		e.raw("%s:\n", g.Label)
-		switch {
-		case parse.IsIntType(g.Type):
-			v := init.(*parse.Constant)
+		if !parse.IsScalarType(g.Type) {
+			panic("unimplemented")
+		}
+		switch init := init.(type) {
+		case *parse.Constant:
 			switch getSize(g.Type) {
 			case 8:
-				e.raw(".quad %d\n", v.Val)
+				e.raw(".quad %d\n", init.Val)
 			case 4:
-				e.raw(".long %d\n", v.Val)
+				e.raw(".long %d\n", init.Val)
 			case 2:
-				e.raw(".short %d\n", v.Val)
+				e.raw(".short %d\n", init.Val)
 			case 1:
-				e.raw(".byte %d\n", v.Val)
+				e.raw(".byte %d\n", init.Val)
 			}
-		case parse.IsPtrType(g.Type):
-			switch init := init.(type) {
-			case *parse.ConstantGPtr:
-				switch {
-				case init.Offset > 0:
-					e.raw(".quad %s + %d\n", init.Offset)
-				case init.Offset < 0:
-					e.raw(".quad %s - %d\n", init.Offset)
-				default:
-					e.raw(".quad %s\n", init.PtrLabel)
+		case *parse.ConstantGPtr:
+			e.quadAddr(init)
+		default:
+			panic(init)
+		}
+	}
+}
+
+func (e *emitter) quadAddr(c *parse.ConstantGPtr) {
+	switch {
+	case c.Offset > 0:
+		e.raw(".quad %s + %d\n", c.PtrLabel, c.Offset)
+	case c.Offset < 0:
+		e.raw(".quad %s - %d\n", c.PtrLabel, -c.Offset)
+	default:
+		e.raw(".quad %s\n", c.PtrLabel)
+	}
+}
+
+// Returns the size of the object initialized by init, which is
+// larger than its type if it initializes a flexible array member.
+func initSize(init *parse.Initializer) int {
+	sz := getSize(init.Type)
+	for _, m := range init.Members {
+		end := m.Offset + getSize(m.Init.GetType())
+		if m.BitWidth >= 0 {
+			end = m.Offset + m.UnitSize
+		}
+		if end > sz {
+			sz = end
+		}
+	}
+	return sz
+}
+
+// Emit the data of a static object with an aggregate initializer.
+// Constant members are written into an image of the object, setting
+// bit-fields bit by bit, and addresses are emitted as relocations. Runs of zero bytes between them are emitted with .zero.
+func (e *emitter) staticInit(init *parse.Initializer) {
+	sz := initSize(init)
+	data := make([]byte, sz)
+	addrs := make(map[int]*parse.ConstantGPtr)
+	for _, m := range init.Members {
+		switch v := m.Init.(type) {
+		case *parse.Constant:
+			n := getSize(v.Type)
+			val := uint64(v.Val)
+			if m.BitWidth >= 0 {
+				for i := 0; i < m.BitWidth; i++ {
+					bit := m.BitOffset + i
+					b := &data[m.Offset+bit/8]
+					*b &^= 1 << uint(bit%8)
+					*b |= byte(val>>uint(i)&1) << uint(bit%8)
 				}
-			case *parse.String:
-				e.raw(".quad %s\n", init.Label)
+				continue
 			}
+			for i := 0; i < n; i++ {
+				data[m.Offset+i] = byte(val >> uint(8*i))
+			}
+		case *parse.ConstantGPtr:
+			addrs[m.Offset] = v
 		default:
-			panic("unimplemented")
+			panic(v)
+		}
+	}
+	for off := 0; off < sz; {
+		if c, ok := addrs[off]; ok {
+			e.quadAddr(c)
+			off += 8
+			continue
+		}
+		end := off
+		for end < sz && addrs[end] == nil && (data[end] == 0) == (data[off] == 0) {
+			end++
+		}
+		if data[off] == 0 {
+			e.raw(".zero %d\n", end-off)
+		} else {
+			vals := make([]string, end-off)
+			for i := range vals {
+				vals[i] = strconv.Itoa(int(data[off+i]))
+			}
+			e.raw(".byte %s\n", strings.Join(vals, ", "))
 		}
+		off = end
+	}
+}
+
+// Const globals are read only. A const volatile object may still
+// change, so it stays writable, and initializers holding addresses
+// go in .data.rel.ro so the dynamic linker can relocate them.
+// Zero initialized objects go in .bss.
+func globalSection(g *parse.GSymbol) string {
+	quals := parse.QualsOf(g.Type)
+	if quals&parse.QualConst == 0 || quals&parse.QualVolatile != 0 {
+		if g.Init == nil {
+			return ".bss"
+		}
+		return ".data"
+	}
+	if hasAddrs(g.Init) {
+		return ".section .data.rel.ro"
 	}
+	return ".section .rodata"
+}
+
+func hasAddrs(init parse.Expr) bool {
+	switch init := init.(type) {
+	case *parse.ConstantGPtr:
+		return true
+	case *parse.Initializer:
+		for _, m := range init.Members {
+			if hasAddrs(m.Init) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (e *emitter) LoadScalarFromPtr(reg string, sz int, signed bool) {
@@ -133,7 +353,7 @@ func (e *emitter) LoadScalarFromPtr(reg string, sz int, signed bool) {
 		case 8:
 			e.asm("movq (%%%s), %%rax\n", reg)
 		case 4:
-			e.asm("movzlq (%%%s), %%rax\n", reg)
+			e.asm("movl (%%%s), %%eax\n", reg)
 		case 2:
 			e.asm("movzwq (%%%s), %%rax\n", reg)
 		case 1:
@@ -187,6 +407,9 @@ func (e *emitter) StoreToPtr(reg string, ty parse.CType) {
 		}
 	case parse.IsPtrType(ty):
 		e.StoreScalarToPtr(reg, getSize(ty))
+	case parse.IsStructType(ty):
+		// Struct values are represented by their address.
+		e.copyMem(reg, getSize(ty))
 	default:
 		panic(ty)
 	}
@@ -197,10 +420,19 @@ func (e *emitter) GetAddr(n parse.Expr) {
 	case *parse.Ident:
 		switch s := n.Sym.(type) {
 		case *parse.LSymbol:
-			offset := e.loffsets[s]
-			e.asm("leaq %d(%%rbp), %%rax\n", offset)
+			if parse.IsVariableSize(s.Type) {
+				e.asm("movq %d(%%rbp), %%rax\n", e.loffsets[s])
+			} else {
+				e.localAddr(s, "rax")
+			}
 		case *parse.GSymbol:
-			e.asm("leaq %s(%%rip), %%rax\n", s.Label)
+			if s.Weak {
+				// An undefined weak symbol resolves to 0,
+				// which only the GOT can hold in PIE code.
+				e.asm("movq %s@GOTPCREL(%%rip), %%rax\n", s.Label)
+			} else {
+				e.asm("leaq %s(%%rip), %%rax\n", s.Label)
+			}
 		default:
 			panic(n)
 		}
@@ -210,83 +442,450 @@ func (e *emitter) GetAddr(n parse.Expr) {
 		e.Expr(n.Operand)
 	case *parse.Index:
 		e.Expr(n.Idx)
-		sz := getSize(n.GetType())
-		if sz != 1 {
-			e.asm("imul $%d, %%rax\n", sz)
-		}
-		e.asm("pushq %%rax\n")
+		e.scaleBySize("rax", n.GetType(), "rcx")
+		e.push("rax")
 		e.Expr(n.Arr)
-		e.asm("popq %%rcx\n")
+		e.pop("rcx")
 		e.asm("addq %%rcx, %%rax\n")
 	case *parse.Selector:
-		var ty *parse.CStruct
-		if n.Op == cpp.ARROW {
-			e.Expr(n.Operand)
-			ty = n.Operand.GetType().(*parse.Ptr).PointsTo.(*parse.CStruct)
-		} else {
-			e.GetAddr(n.Operand)
-			ty = n.Operand.GetType().(*parse.CStruct)
+		// For '.' the value of a struct expression is its address.
+		e.Expr(n.Operand)
+		e.asm("addq $%d, %%rax\n", getStructOffset(n))
+	case *parse.CompoundLiteral:
+		switch s := n.Sym.(type) {
+		case *parse.LSymbol:
+			// The object is initialized each time the literal is evaluated.
+			e.initLocal(s, n.Init)
+			e.localAddr(s, "rax")
+		case *parse.GSymbol:
+			e.asm("leaq %s(%%rip), %%rax\n", s.Label)
+		default:
+			panic(n)
 		}
-		e.asm("addq $%d, %%rax\n", getStructOffset(ty, n.Sel))
+	default:
+		panic(n)
 	}
 }
 
-var intParamLUT = [...]string{
-	"%rdi", "%rsi", "%rdx", "%rcx", "r8", "r9",
-}
-
 func (e *emitter) CFunc(f *parse.CFunc) {
 	e.f = f
-	e.raw(".text\n")
-	e.raw(".global %s\n", f.Name)
+	if f.Sym.Section != "" {
+		e.raw(".section %s,\"ax\",@progbits\n", f.Sym.Section)
+	} else {
+		e.raw(".text\n")
+	}
+	switch {
+	case f.Weak:
+		e.raw(".weak %s\n", f.Name)
+	case !f.Internal:
+		e.raw(".global %s\n", f.Name)
+	}
+	if f.Sym.Align != 0 {
+		e.raw(".align %d\n", f.Sym.Align)
+	}
 	e.raw("%s:\n", f.Name)
 	e.asm("pushq %%rbp\n")
 	e.asm("movq %%rsp, %%rbp\n")
-	curlocaloffset, loffsets := e.calcLocalOffsets(f)
-	e.loffsets = loffsets
-	if curlocaloffset != 0 {
-		e.asm("sub $%d, %%rsp\n", -curlocaloffset)
+	blocksz, blockalign := e.calcLocalOffsets(f)
+	e.calcLabelDepths(f)
+	// The frame size is only known once temporaries
+	// have been allocated, so buffer the body.
+	out := e.o
+	var body bytes.Buffer
+	e.o = &body
+	if blocksz != 0 {
+		// Reserve enough space to align the block within it.
+		top := e.allocTemp(blocksz+blockalign) + blocksz + blockalign
+		e.alignbase = e.allocTemp(8)
+		e.asm("leaq %d(%%rbp), %%rax\n", top)
+		e.asm("andq $%d, %%rax\n", -blockalign)
+		e.asm("movq %%rax, %d(%%rbp)\n", e.alignbase)
+	}
+	sret := isSret(f.FuncType.RetType)
+	if sret {
+		e.sretoffset = e.allocTemp(8)
+		e.asm("movq %%rdi, %d(%%rbp)\n", e.sretoffset)
+	}
+	locs, _ := argLocs(f.FuncType.ArgTypes, sret)
+	if f.FuncType.IsVarArg {
+		e.saveArgRegs()
 	}
 	for idx, psym := range f.ParamSymbols {
-		e.asm("movq %s, %d(%%rbp)\n", intParamLUT[idx], e.loffsets[psym])
+		loc := locs[idx]
+		if loc.inMemory() {
+			// Stack arguments start above the saved %rbp and return address.
+			e.loffsets[psym] = 16 + loc.stackOffset
+			continue
+		}
+		for i, reg := range loc.regs {
+			e.storeEightbyte(reg, "rbp", e.loffsets[psym]+8*i)
+		}
 	}
 	for _, stmt := range f.Body {
 		e.Stmt(stmt)
 	}
+	e.popScopes(0)
 	e.asm("leave\n")
 	e.asm("ret\n")
+	if e.depth != 0 {
+		panic("internal error")
+	}
+	e.o = out
+	// Calls rely on the frame keeping %rsp 16 byte aligned.
+	framesz := (-e.frameoffset + 15) &^ 15
+	if framesz != 0 {
+		e.asm("sub $%d, %%rsp\n", framesz)
+	}
+	e.raw("%s", body.String())
 	e.f = nil
 }
 
-func (e *emitter) calcLocalOffsets(f *parse.CFunc) (int, map[*parse.LSymbol]int) {
-	loffset := 0
-	loffsets := make(map[*parse.LSymbol]int)
-	addLSymbol := func(lsym *parse.LSymbol) {
+// Assign a stack slot to every local variable in the function,
+// returning the size and alignment of the block for those which
+// are aligned more strictly than the frame.
+// Variables in sibling blocks can never be live at the same time,
+// so they share the same stack space.
+func (e *emitter) calcLocalOffsets(f *parse.CFunc) (int, int) {
+	loffset, aoffset := 0, 0
+	minoffset, minaoffset := 0, 0
+	blockalign := 0
+	e.loffsets = make(map[*parse.LSymbol]int)
+	e.aoffsets = make(map[*parse.LSymbol]int)
+	e.alignbase = 0
+	addLSymbol := func(lsym *parse.LSymbol, isParam bool) {
 		sz := getSize(lsym.Type)
-		if sz < 8 {
+		align := localAlign(lsym)
+		if parse.IsVariableSize(lsym.Type) {
+			// The slot holds the address of the array.
 			sz = 8
+			align = 8
+		}
+		sz = (sz + 7) &^ 7
+		if align > 16 && !isParam {
+			aoffset = (aoffset - sz) &^ (align - 1)
+			if aoffset < minaoffset {
+				minaoffset = aoffset
+			}
+			if align > blockalign {
+				blockalign = align
+			}
+			e.aoffsets[lsym] = aoffset
+			return
 		}
-		sz = sz + (sz % 8)
 		loffset -= sz
-		loffsets[lsym] = loffset
+		// Slots are 8 byte aligned unless more is required,
+		// which the 16 byte aligned frame pointer allows.
+		if align > 8 {
+			loffset &^= 15
+		}
+		if loffset < minoffset {
+			minoffset = loffset
+		}
+		e.loffsets[lsym] = loffset
+	}
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		eachStmtExpr(n, func(s *parse.StmtExpr) {
+			walk(s.Body)
+		})
+		switch n := n.(type) {
+		case *parse.DeclList:
+			for _, sym := range n.Symbols {
+				lsym, ok := sym.(*parse.LSymbol)
+				if !ok {
+					continue
+				}
+				addLSymbol(lsym, false)
+			}
+		case *parse.Block:
+			saved, asaved := loffset, aoffset
+			for _, stmt := range n.Body {
+				walk(stmt)
+			}
+			loffset, aoffset = saved, asaved
+		case *parse.If:
+			walk(n.Stmt)
+			if n.Else != nil {
+				walk(n.Else)
+			}
+		case *parse.While:
+			walk(n.Body)
+		case *parse.DoWhile:
+			walk(n.Body)
+		case *parse.For:
+			saved, asaved := loffset, aoffset
+			if n.Init != nil {
+				walk(n.Init)
+			}
+			walk(n.Body)
+			loffset, aoffset = saved, asaved
+		case *parse.Switch:
+			walk(n.Stmt)
+		case *parse.LabeledStmt:
+			walk(n.Stmt)
+		}
 	}
+	// Parameters are only ever given the alignment of the frame.
 	for _, lsym := range f.ParamSymbols {
-		addLSymbol(lsym)
+		addLSymbol(lsym, true)
+	}
+	for _, lsym := range f.Anonymous {
+		addLSymbol(lsym, false)
 	}
 	for _, n := range f.Body {
+		walk(n)
+	}
+	e.frameoffset = minoffset
+	return -minaoffset, blockalign
+}
+
+// The alignment of a local, which its declaration may increase.
+func localAlign(lsym *parse.LSymbol) int {
+	align := getAlign(lsym.Type)
+	if lsym.Align > align {
+		align = lsym.Align
+	}
+	return align
+}
+
+// Compute the address of a local with a fixed size in reg.
+func (e *emitter) localAddr(lsym *parse.LSymbol, reg string) {
+	if offset, ok := e.aoffsets[lsym]; ok {
+		e.asm("movq %d(%%rbp), %%%s\n", e.alignbase, reg)
+		e.asm("leaq %d(%%%s), %%%s\n", offset, reg, reg)
+		return
+	}
+	e.asm("leaq %d(%%rbp), %%%s\n", e.loffsets[lsym], reg)
+}
+
+// Calls f with each statement expression in the expressions of the
+// statement n, or in n if it is an expression, but not those nested
+// in the statement expressions found.
+func eachStmtExpr(n parse.Node, f func(*parse.StmtExpr)) {
+	var exprs []parse.Node
+	switch n := n.(type) {
+	case *parse.StmtExpr:
+		f(n)
+	case *parse.ExprStmt:
+		exprs = append(exprs, n.Expr)
+	case *parse.Return:
+		exprs = append(exprs, n.Ret)
+	case *parse.If:
+		exprs = append(exprs, n.Cond)
+	case *parse.While:
+		exprs = append(exprs, n.Cond)
+	case *parse.DoWhile:
+		exprs = append(exprs, n.Cond)
+	case *parse.For:
+		if _, ok := n.Init.(*parse.DeclList); !ok {
+			exprs = append(exprs, n.Init)
+		}
+		exprs = append(exprs, n.Cond, n.Step)
+	case *parse.Switch:
+		exprs = append(exprs, n.Expr)
+	case *parse.Goto:
+		exprs = append(exprs, n.Target)
+	case *parse.DeclList:
+		for i := range n.Symbols {
+			exprs = append(exprs, n.Dims[i], n.Inits[i])
+		}
+	case *parse.Asm:
+		for _, op := range append(n.Outputs, n.Inputs...) {
+			exprs = append(exprs, op.Expr)
+		}
+	case *parse.Binop:
+		exprs = append(exprs, n.L, n.R)
+	case *parse.CompoundAssign:
+		exprs = append(exprs, n.L, n.R)
+	case *parse.Unop:
+		exprs = append(exprs, n.Operand)
+	case *parse.IncDec:
+		exprs = append(exprs, n.Operand)
+	case *parse.Cast:
+		exprs = append(exprs, n.Operand)
+	case *parse.Selector:
+		exprs = append(exprs, n.Operand)
+	case *parse.Index:
+		exprs = append(exprs, n.Arr, n.Idx)
+	case *parse.Cond:
+		exprs = append(exprs, n.Cond, n.Then, n.Else)
+	case *parse.Comma:
+		for _, expr := range n.Exprs {
+			exprs = append(exprs, expr)
+		}
+	case *parse.Call:
+		exprs = append(exprs, n.FuncLike)
+		for _, arg := range n.Args {
+			exprs = append(exprs, arg)
+		}
+	case *parse.CompoundLiteral:
+		exprs = append(exprs, n.Init)
+	case *parse.Initializer:
+		for _, m := range n.Members {
+			exprs = append(exprs, m.Init)
+		}
+	case *parse.Alloca:
+		exprs = append(exprs, n.Size)
+	case *parse.BuiltinCall:
+		for _, arg := range n.Args {
+			exprs = append(exprs, arg)
+		}
+	case *parse.VaStart:
+		exprs = append(exprs, n.Ap)
+	case *parse.VaArg:
+		exprs = append(exprs, n.Ap)
+	}
+	for _, expr := range exprs {
+		if expr != nil {
+			eachStmtExpr(expr, f)
+		}
+	}
+}
+
+// Find how many scope entries are live at each label, and reserve
+// a slot for each variable length array to save %rsp before
+// allocating it.
+func (e *emitter) calcLabelDepths(f *parse.CFunc) {
+	e.vlasave = make(map[*parse.LSymbol]int)
+	e.labeldepth = make(map[string]int)
+	depth := 0
+	label := func(l string) {
+		e.labeldepth[l] = depth
+	}
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		eachStmtExpr(n, func(s *parse.StmtExpr) {
+			walk(s.Body)
+		})
 		switch n := n.(type) {
 		case *parse.DeclList:
 			for _, sym := range n.Symbols {
 				lsym, ok := sym.(*parse.LSymbol)
-				if !ok {
-					continue
+				if ok && parse.IsVariableSize(lsym.Type) {
+					e.vlasave[lsym] = e.allocTemp(8)
+					depth++
+				}
+				if ok && lsym.Cleanup != nil {
+					depth++
 				}
-				addLSymbol(lsym)
 			}
+		case *parse.Block:
+			saved := depth
+			for _, stmt := range n.Body {
+				walk(stmt)
+			}
+			depth = saved
+		case *parse.If:
+			walk(n.Stmt)
+			if n.Else != nil {
+				walk(n.Else)
+			}
+		case *parse.While:
+			label(n.LStart)
+			label(n.LEnd)
+			walk(n.Body)
+		case *parse.DoWhile:
+			label(n.LStart)
+			label(n.LCond)
+			label(n.LEnd)
+			walk(n.Body)
+		case *parse.For:
+			label(n.LEnd)
+			saved := depth
+			if n.Init != nil {
+				walk(n.Init)
+			}
+			label(n.LStart)
+			label(n.LStep)
+			walk(n.Body)
+			depth = saved
+		case *parse.Switch:
+			label(n.LAfter)
+			walk(n.Stmt)
+		case *parse.LabeledStmt:
+			label(n.AnonLabel)
+			walk(n.Stmt)
+		}
+	}
+	for _, n := range f.Body {
+		walk(n)
+	}
+}
+
+// Leave the scopes which are not live at label.
+func (e *emitter) leaveScopes(label string) {
+	e.exitScopes(e.labeldepth[label])
+}
 
+// Run the cleanups and free the variable length arrays of the scope
+// entries above depth, innermost first, without popping them as
+// code after a jump is still in their scope. Clobbers all caller
+// saved registers.
+func (e *emitter) exitScopes(depth int) {
+	for i := len(e.scopes) - 1; i >= depth; i-- {
+		sc := e.scopes[i]
+		if sc.cleanup == nil {
+			e.asm("movq %d(%%rbp), %%rsp\n", sc.vlasave)
+			continue
+		}
+		if e.depth%2 != 0 {
+			e.asm("subq $8, %%rsp\n")
+		}
+		e.localAddr(sc.cleanup, "rdi")
+		e.asm("call %s\n", sc.cleanup.Cleanup.Label)
+		if e.depth%2 != 0 {
+			e.asm("addq $8, %%rsp\n")
 		}
 	}
-	return loffset, loffsets
+}
+
+// Allocate %rax bytes on the stack aligned to align, leaving the
+// address of the allocation in %rax. The size is rounded up to keep
+// %rsp 16 byte aligned. Anything pushed is copied below the
+// allocation, leaving the originals above it intact for when the
+// stack pointer is restored. Clobbers %rcx and %rdx.
+func (e *emitter) allocStack(align int) {
+	if align < 16 {
+		align = 16
+	}
+	e.asm("addq $15, %%rax\n")
+	e.asm("andq $-16, %%rax\n")
+	e.asm("movq %%rsp, %%rcx\n")
+	e.asm("negq %%rax\n")
+	e.asm("leaq (%%rcx,%%rax), %%rax\n")
+	e.asm("andq $%d, %%rax\n", -align)
+	e.asm("leaq %d(%%rax), %%rsp\n", -8*e.depth)
+	for i := 0; i < e.depth; i++ {
+		e.asm("movq %d(%%rcx), %%rdx\n", 8*i)
+		e.asm("movq %%rdx, %d(%%rsp)\n", 8*i)
+	}
+}
+
+// Compute the size of ty in reg. Variable length arrays use the
+// lengths saved when their declarations were reached.
+func (e *emitter) sizeToReg(ty parse.CType, reg string) {
+	arr, ok := ty.(*parse.Array)
+	if !ok || !parse.IsVariableSize(arr) {
+		e.asm("movq $%d, %%%s\n", getSize(ty), reg)
+		return
+	}
+	e.sizeToReg(arr.MemberType, reg)
+	if arr.DimSym != nil {
+		e.asm("imulq %d(%%rbp), %%%s\n", e.loffsets[arr.DimSym], reg)
+	} else {
+		e.asm("imulq $%d, %%%s\n", arr.Dim, reg)
+	}
+}
+
+// Multiply reg by the size of ty, using tmp if the size
+// is only known at run time.
+func (e *emitter) scaleBySize(reg string, ty parse.CType, tmp string) {
+	if parse.IsVariableSize(ty) {
+		e.sizeToReg(ty, tmp)
+		e.asm("imulq %%%s, %%%s\n", tmp, reg)
+	} else if sz := getSize(ty); sz != 1 {
+		e.asm("imul $%d, %%%s\n", sz, reg)
+	}
 }
 
 func (e *emitter) Stmt(stmt parse.Node) {
@@ -306,27 +905,111 @@ func (e *emitter) Stmt(stmt parse.Node) {
 	case *parse.ExprStmt:
 		e.Expr(stmt.Expr)
 	case *parse.Goto:
+		if stmt.Target != nil {
+			// The scopes a computed goto leaves are not known.
+			e.Expr(stmt.Target)
+			e.asm("jmp *%%rax\n")
+			break
+		}
+		e.leaveScopes(stmt.Label)
 		e.asm("jmp %s\n", stmt.Label)
 	case *parse.LabeledStmt:
 		e.raw("%s:\n", stmt.AnonLabel)
 		e.Stmt(stmt.Stmt)
 	case *parse.Switch:
 		e.Switch(stmt)
+	case *parse.Asm:
+		e.Asm(stmt)
 	case *parse.EmptyStmt:
 		// pass
 	case *parse.DeclList:
-		// pass
+		e.DeclList(stmt)
 	default:
 		panic(stmt)
 	}
 }
 
+// Initialize local variables in the order they are declared.
+// Variable length arrays are allocated on the stack, saving
+// %rsp first so it can be restored when they go out of scope,
+// and variables with a cleanup function are pushed as scopes
+// once initialized.
+func (e *emitter) DeclList(d *parse.DeclList) {
+	for idx, sym := range d.Symbols {
+		if d.Dims[idx] != nil {
+			e.Expr(d.Dims[idx])
+		}
+		lsym, ok := sym.(*parse.LSymbol)
+		if !ok {
+			continue
+		}
+		if save, ok := e.vlasave[lsym]; ok {
+			e.asm("movq %%rsp, %d(%%rbp)\n", save)
+			e.sizeToReg(lsym.Type, "rax")
+			e.allocStack(localAlign(lsym))
+			e.asm("movq %%rax, %d(%%rbp)\n", e.loffsets[lsym])
+			e.scopes = append(e.scopes, scope{vlasave: save})
+		}
+		if d.Inits[idx] != nil {
+			e.initLocal(lsym, d.Inits[idx])
+		}
+		if lsym.Cleanup != nil {
+			e.scopes = append(e.scopes, scope{cleanup: lsym})
+		}
+	}
+}
+
+// Leave the scope entries pushed since depth at the end of a block.
+func (e *emitter) popScopes(depth int) {
+	e.exitScopes(depth)
+	e.scopes = e.scopes[:depth]
+}
+
+// Initialize the local object of type ty at offset from %rbp. An
+// aggregate initializer zeroes the object first, so any members it
+// does not mention are zero, then stores each member in order.
+func (e *emitter) initLocal(lsym *parse.LSymbol, init parse.Expr) {
+	ty := lsym.Type
+	agg, ok := init.(*parse.Initializer)
+	if !ok {
+		e.Expr(init)
+		e.localAddr(lsym, "rcx")
+		e.StoreToPtr("rcx", ty)
+		return
+	}
+	e.localAddr(lsym, "rcx")
+	e.zeroMem("rcx", getSize(ty))
+	for _, m := range agg.Members {
+		e.Expr(m.Init)
+		e.localAddr(lsym, "rcx")
+		if m.Offset != 0 {
+			e.asm("addq $%d, %%rcx\n", m.Offset)
+		}
+		if m.BitWidth >= 0 {
+			e.storeBitfield("rcx", m.Init.GetType(), m.BitOffset, m.BitWidth, m.UnitSize)
+		} else {
+			e.StoreToPtr("rcx", m.Init.GetType())
+		}
+	}
+}
+
 func (e *emitter) Switch(sw *parse.Switch) {
 	e.Expr(sw.Expr)
 	for _, swc := range sw.Cases {
-		e.asm("mov $%d, %%rcx\n", swc.V)
-		e.asm("cmp %%rax, %%rcx\n")
-		e.asm("je %s\n", swc.Label)
+		if swc.Hi == swc.V {
+			e.asm("mov $%d, %%rcx\n", swc.V)
+			e.asm("cmp %%rax, %%rcx\n")
+			e.asm("je %s\n", swc.Label)
+			continue
+		}
+		// The value is in a case range when its distance
+		// above V, taken as unsigned, is at most Hi - V.
+		e.asm("mov $%d, %%rdx\n", swc.V)
+		e.asm("movq %%rax, %%rcx\n")
+		e.asm("subq %%rdx, %%rcx\n")
+		e.asm("mov $%d, %%rdx\n", swc.Hi-swc.V)
+		e.asm("cmp %%rdx, %%rcx\n")
+		e.asm("jbe %s\n", swc.Label)
 	}
 	if sw.LDefault != "" {
 		e.asm("jmp %s\n", sw.LDefault)
@@ -359,27 +1042,56 @@ func (e *emitter) DoWhile(d *parse.DoWhile) {
 }
 
 func (e *emitter) For(fr *parse.For) {
-	if fr.Init != nil {
+	depth := len(e.scopes)
+	if decl, ok := fr.Init.(*parse.DeclList); ok {
+		e.DeclList(decl)
+	} else if fr.Init != nil {
 		e.Expr(fr.Init)
 	}
 	e.raw("%s:\n", fr.LStart)
 	if fr.Cond != nil {
 		e.Expr(fr.Cond)
+		e.asm("test %%rax, %%rax\n")
+		e.asm("jz %s\n", fr.LEnd)
 	}
-	e.asm("test %%rax, %%rax\n")
-	e.asm("jz %s\n", fr.LEnd)
 	e.Stmt(fr.Body)
+	e.raw("%s:\n", fr.LStep)
 	if fr.Step != nil {
 		e.Expr(fr.Step)
 	}
 	e.asm("jmp %s\n", fr.LStart)
 	e.raw("%s:\n", fr.LEnd)
+	e.popScopes(depth)
 }
 
 func (e *emitter) Block(c *parse.Block) {
+	depth := len(e.scopes)
 	for _, stmt := range c.Body {
 		e.Stmt(stmt)
 	}
+	e.popScopes(depth)
+}
+
+// The value of a statement expression is left in %rax by its last
+// statement, and kept there while leaving its scopes.
+func (e *emitter) StmtExpr(s *parse.StmtExpr) {
+	depth := len(e.scopes)
+	for _, stmt := range s.Body.Body {
+		e.Stmt(stmt)
+	}
+	if parse.IsStructType(s.Type) {
+		// The value of a struct is its address, which may be
+		// in the scope of the block, so copy it out.
+		e.asm("leaq %d(%%rbp), %%rcx\n", e.allocTemp(getSize(s.Type)))
+		e.copyMem("rcx", getSize(s.Type))
+	}
+	if depth == len(e.scopes) {
+		return
+	}
+	save := e.allocTemp(8)
+	e.asm("movq %%rax, %d(%%rbp)\n", save)
+	e.popScopes(depth)
+	e.asm("movq %d(%%rbp), %%rax\n", save)
 }
 
 func (e *emitter) If(i *parse.If) {
@@ -387,18 +1099,77 @@ func (e *emitter) If(i *parse.If) {
 	e.asm("test %%rax, %%rax\n")
 	e.asm("jz %s\n", i.LElse)
 	e.Stmt(i.Stmt)
-	e.raw("%s:\n", i.LElse)
-	if i.Else != nil {
-		e.Stmt(i.Else)
+	if i.Else == nil {
+		e.raw("%s:\n", i.LElse)
+		return
 	}
+	lafter := e.NextLabel()
+	e.asm("jmp %s\n", lafter)
+	e.raw("%s:\n", i.LElse)
+	e.Stmt(i.Else)
+	e.raw("%s:\n", lafter)
 }
 
 func (e *emitter) Return(r *parse.Return) {
+	if r.Ret == nil {
+		e.returnCleanups()
+		e.asm("leave\n")
+		e.asm("ret\n")
+		return
+	}
 	e.Expr(r.Ret)
+	ty := r.Ret.GetType()
+	if parse.IsStructType(ty) {
+		if isSret(ty) {
+			// Copy into the caller's buffer and return its address.
+			e.asm("movq %d(%%rbp), %%rcx\n", e.sretoffset)
+			e.copyMem("rcx", getSize(ty))
+			e.returnCleanups()
+		} else {
+			if e.hasCleanups() {
+				// The value may be a variable being cleaned up.
+				e.asm("leaq %d(%%rbp), %%rcx\n", e.allocTemp(getSize(ty)))
+				e.copyMem("rcx", getSize(ty))
+				e.returnCleanups()
+			}
+			e.asm("movq %%rax, %%rcx\n")
+			sz := getSize(ty)
+			for i, reg := range retLoc(ty).regs {
+				n := sz - 8*i
+				if n > 8 {
+					n = 8
+				}
+				e.loadEightbyte("rcx", 8*i, n, reg)
+			}
+		}
+	} else {
+		e.returnCleanups()
+	}
 	e.asm("leave\n")
 	e.asm("ret\n")
 }
 
+func (e *emitter) hasCleanups() bool {
+	for _, sc := range e.scopes {
+		if sc.cleanup != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Run the cleanups of every variable in scope before returning,
+// preserving the return value in %rax.
+func (e *emitter) returnCleanups() {
+	if !e.hasCleanups() {
+		return
+	}
+	save := e.allocTemp(8)
+	e.asm("movq %%rax, %d(%%rbp)\n", save)
+	e.exitScopes(0)
+	e.asm("movq %d(%%rbp), %%rax\n", save)
+}
+
 func (e *emitter) Expr(expr parse.Node) {
 	switch expr := expr.(type) {
 	case *parse.Ident:
@@ -406,91 +1177,322 @@ func (e *emitter) Expr(expr parse.Node) {
 	case *parse.Call:
 		e.Call(expr)
 	case *parse.Constant:
-		e.asm("movq $%v, %%rax\n", expr.Val)
+		if expr.Val != int64(int32(expr.Val)) {
+			e.asm("movabsq $%v, %%rax\n", expr.Val)
+		} else {
+			e.asm("movq $%v, %%rax\n", expr.Val)
+		}
 	case *parse.Unop:
 		e.Unop(expr)
 	case *parse.Binop:
 		e.emitBinop(expr)
+	case *parse.CompoundAssign:
+		e.CompoundAssign(expr)
+	case *parse.Cond:
+		e.Cond(expr)
+	case *parse.Comma:
+		for _, subexpr := range expr.Exprs {
+			e.Expr(subexpr)
+		}
+	case *parse.IncDec:
+		e.IncDec(expr)
 	case *parse.Index:
 		e.Index(expr)
 	case *parse.Cast:
 		e.Cast(expr)
+	case *parse.VaStart:
+		e.VaStart(expr)
+	case *parse.VaArg:
+		e.VaArg(expr)
 	case *parse.Selector:
 		e.Selector(expr)
 	case *parse.String:
 		e.asm("leaq %s(%%rip), %%rax\n", expr.Label)
+	case *parse.LabelAddr:
+		e.asm("leaq %s(%%rip), %%rax\n", expr.Label)
+	case *parse.StmtExpr:
+		e.StmtExpr(expr)
+	case *parse.CompoundLiteral:
+		e.GetAddr(expr)
+		e.LoadFromPtr("rax", expr.Type)
+	case *parse.Sizeof:
+		e.sizeToReg(expr.Of, "rax")
+	case *parse.Alloca:
+		e.Expr(expr.Size)
+		e.allocStack(16)
+	case *parse.BuiltinCall:
+		e.BuiltinCall(expr)
 	default:
 		panic(expr)
 	}
 }
 
-func getStructOffset(s *parse.CStruct, member string) int {
-	offset := 0
-	for idx, n := range s.Names {
-		if n == member {
-			return offset
-		}
-		offset += getSize(s.Types[idx])
-	}
-	// Error should have been caught in parse.
-	panic("internal error")
+func getStructOffset(sel *parse.Selector) int {
+	return x64SzDesc.GetLayout(sel.StructType()).Offsets[sel.Index]
 }
 
 func (e *emitter) Selector(s *parse.Selector) {
-	e.Expr(s.Operand)
-	ty := s.Operand.GetType().(*parse.CStruct)
-	offset := getStructOffset(ty, s.Sel)
-	if offset != 0 {
-		e.asm("add $%d, %%rax\n", offset)
+	e.GetAddr(s)
+	e.LoadFromLvalue("rax", s)
+}
+
+// Returns the bit position and width of n within its storage unit,
+// and the size of the unit, if n is a bit-field member.
+func bitfieldInfo(n parse.Expr) (int, int, int, bool) {
+	sel, ok := n.(*parse.Selector)
+	if !ok {
+		return 0, 0, 0, false
 	}
-	e.LoadFromPtr("rax", s.GetType())
+	width := sel.BitWidth()
+	if width < 0 {
+		return 0, 0, 0, false
+	}
+	l := x64SzDesc.GetLayout(sel.StructType())
+	return l.BitOffsets[sel.Index], width, l.UnitSizes[sel.Index], true
 }
 
-func (e *emitter) Ident(i *parse.Ident) {
-	e.GetAddr(i)
-	e.LoadFromPtr("rax", i.GetType())
+// Load the lvalue n whose address is in reg. A bit-field is read
+// by loading its whole storage unit and shifting the field out,
+// extending it according to the signedness of its type.
+//
+// Every read and write of an lvalue is a single access to memory
+// and values are never kept in registers between them, which is
+// all volatile requires, except for packed bit-fields, whose units
+// are accessed a byte at a time. Clobbers %r11 when loading one.
+func (e *emitter) LoadFromLvalue(reg string, n parse.Expr) {
+	ty := n.GetType()
+	pos, width, unit, ok := bitfieldInfo(n)
+	if !ok || unit == getSize(ty) {
+		e.LoadFromPtr(reg, ty)
+	} else if unit > 8 {
+		// The field starts in the first byte and ends in the ninth,
+		// so shift the bits of the ninth down into place.
+		e.asm("movzbq 8(%%%s), %%r11\n", reg)
+		e.asm("movq (%%%s), %%rax\n", reg)
+		e.asm("shrdq $%d, %%r11, %%rax\n", pos)
+		pos = 0
+	} else {
+		e.loadUnitBytes(reg, unit)
+		e.asm("movq %%r11, %%rax\n")
+	}
+	if !ok {
+		return
+	}
+	e.asm("shlq $%d, %%rax\n", 64-pos-width)
+	if parse.IsSignedIntType(ty) {
+		e.asm("sarq $%d, %%rax\n", 64-width)
+	} else {
+		e.asm("shrq $%d, %%rax\n", 64-width)
+	}
 }
 
-func isIntRegArg(t parse.CType) bool {
-	return parse.IsIntType(t) || parse.IsPtrType(t)
+// Store %rax to the lvalue n whose address is in reg. Bit-fields
+// are updated with a read-modify-write of their storage unit, and
+// %rax is left holding the value actually stored.
+// Clobbers %r10 and %r11 when storing to a bit-field.
+func (e *emitter) StoreToLvalue(reg string, n parse.Expr) {
+	ty := n.GetType()
+	pos, width, unit, ok := bitfieldInfo(n)
+	if !ok {
+		e.StoreToPtr(reg, ty)
+		return
+	}
+	e.storeBitfield(reg, ty, pos, width, unit)
 }
 
-func classifyArgs(args []parse.Expr) ([]parse.Expr, []parse.Expr) {
-	var intargs, memargs []parse.Expr
-	nintargs := 0
-	for _, arg := range args {
-		if nintargs < 6 && isIntRegArg(arg.GetType()) {
-			nintargs += 1
-			intargs = append(intargs, arg)
-		} else {
-			memargs = append(memargs, arg)
+// Store %rax to the bit-field of type ty at bit pos of the
+// storage unit of unit bytes at (%reg), as for StoreToLvalue.
+func (e *emitter) storeBitfield(reg string, ty parse.CType, pos, width, unit int) {
+	mask := uint64(1)<<uint(width) - 1
+	e.asm("movq %%rax, %%r10\n")
+	e.asm("movabsq $%d, %%r11\n", int64(mask))
+	e.asm("andq %%r11, %%r10\n")
+	switch {
+	case unit == getSize(ty):
+		e.asm("shlq $%d, %%r11\n", pos)
+		e.asm("notq %%r11\n")
+		e.LoadFromPtr(reg, ty)
+		e.asm("andq %%r11, %%rax\n")
+		e.asm("movq %%r10, %%r11\n")
+		e.asm("shlq $%d, %%r11\n", pos)
+		e.asm("orq %%r11, %%rax\n")
+		e.StoreToPtr(reg, ty)
+	case unit > 8:
+		// The field fills the first eightbyte from pos,
+		// and the rest of it starts the ninth byte.
+		e.asm("movq (%%%s), %%rax\n", reg)
+		e.asm("movabsq $%d, %%r11\n", int64(uint64(1)<<uint(pos)-1))
+		e.asm("andq %%r11, %%rax\n")
+		e.asm("movq %%r10, %%r11\n")
+		e.asm("shlq $%d, %%r11\n", pos)
+		e.asm("orq %%r11, %%rax\n")
+		e.asm("movq %%rax, (%%%s)\n", reg)
+		e.asm("movzbl 8(%%%s), %%eax\n", reg)
+		e.asm("andl $%d, %%eax\n", 0xff&^(1<<uint(pos+width-64)-1))
+		e.asm("movq %%r10, %%r11\n")
+		e.asm("shrq $%d, %%r11\n", 64-pos)
+		e.asm("orq %%r11, %%rax\n")
+		e.asm("movb %%al, 8(%%%s)\n", reg)
+	default:
+		e.loadUnitBytes(reg, unit)
+		e.asm("movq %%r11, %%rax\n")
+		e.asm("movabsq $%d, %%r11\n", int64(^(mask << uint(pos))))
+		e.asm("andq %%r11, %%rax\n")
+		e.asm("movq %%r10, %%r11\n")
+		e.asm("shlq $%d, %%r11\n", pos)
+		e.asm("orq %%r11, %%rax\n")
+		for i := 0; i < unit; i++ {
+			if i != 0 {
+				e.asm("shrq $8, %%rax\n")
+			}
+			e.asm("movb %%al, %d(%%%s)\n", i, reg)
 		}
 	}
-	return intargs, memargs
+	e.asm("movq %%r10, %%rax\n")
+	e.asm("shlq $%d, %%rax\n", 64-width)
+	if parse.IsSignedIntType(ty) {
+		e.asm("sarq $%d, %%rax\n", 64-width)
+	} else {
+		e.asm("shrq $%d, %%rax\n", 64-width)
+	}
 }
 
+// Load the unit bytes at (%reg), at most eight, into %r11
+// without reading past them.
+func (e *emitter) loadUnitBytes(reg string, unit int) {
+	e.asm("movzbl %d(%%%s), %%r11d\n", unit-1, reg)
+	for i := unit - 2; i >= 0; i-- {
+		e.asm("shlq $8, %%r11\n")
+		e.asm("movb %d(%%%s), %%r11b\n", i, reg)
+	}
+}
+
+func (e *emitter) Ident(i *parse.Ident) {
+	e.GetAddr(i)
+	e.LoadFromPtr("rax", i.GetType())
+}
+
+// Calls evaluate the function and arguments left to right, pushing
+// each value (the address for structs). Once everything is evaluated
+// the stack argument area is filled in and registers loaded from the
+// pushed values, so evaluating one argument can never clobber another.
 func (e *emitter) Call(c *parse.Call) {
-	intargs, memargs := classifyArgs(c.Args)
-	sz := 0
-	for i := len(memargs) - 1; i >= 0; i-- {
-		arg := memargs[i]
-		e.Expr(arg)
-		e.asm("push %%rax\n")
-		sz += 8
+	var argtys []parse.CType
+	for _, arg := range c.Args {
+		argtys = append(argtys, arg.GetType())
+	}
+	sret := isSret(c.Type)
+	locs, stacksz := argLocs(argtys, sret)
+	// Named functions are called directly so calls into
+	// shared libraries go through the PLT.
+	direct := ""
+	if ident, ok := c.FuncLike.(*parse.Ident); ok && parse.IsCFuncType(ident.GetType()) {
+		direct = ident.Sym.(*parse.GSymbol).Label
 	}
-	for i := len(intargs) - 1; i >= 0; i-- {
-		arg := intargs[i]
+	npushed := len(c.Args)
+	if direct == "" {
+		e.Expr(c.FuncLike)
+		e.push("rax")
+		npushed += 1
+	}
+	for _, arg := range c.Args {
 		e.Expr(arg)
-		e.asm("push %%rax\n")
+		e.push("rax")
+	}
+	// The stack argument area sits at the bottom of the stack, padded
+	// so that %rsp is 16 byte aligned at the call instruction.
+	area := stacksz
+	if (8*e.depth+area)%16 != 0 {
+		area += 8
 	}
-	for idx, _ := range intargs {
-		e.asm("pop %s\n", intParamLUT[idx])
+	if area != 0 {
+		e.asm("subq $%d, %%rsp\n", area)
 	}
-	e.Expr(c.FuncLike)
-	e.asm("call *%%rax\n")
-	if sz != 0 {
-		e.asm("add $%d, %%rsp\n", sz)
+	// Offset from %rsp of the pushed value of argument idx.
+	pushedOffset := func(idx int) int {
+		return area + 8*(len(c.Args)-1-idx)
 	}
+	for idx, arg := range c.Args {
+		loc := locs[idx]
+		ty := arg.GetType()
+		e.asm("movq %d(%%rsp), %%rax\n", pushedOffset(idx))
+		switch {
+		case loc.inMemory() && parse.IsStructType(ty):
+			e.asm("leaq %d(%%rsp), %%rcx\n", loc.stackOffset)
+			e.copyMem("rcx", getSize(ty))
+		case loc.inMemory():
+			e.asm("movq %%rax, %d(%%rsp)\n", loc.stackOffset)
+		}
+	}
+	for idx, arg := range c.Args {
+		loc := locs[idx]
+		ty := arg.GetType()
+		switch {
+		case loc.inMemory():
+		case parse.IsStructType(ty):
+			e.asm("movq %d(%%rsp), %%rax\n", pushedOffset(idx))
+			sz := getSize(ty)
+			for i, reg := range loc.regs {
+				n := sz - 8*i
+				if n > 8 {
+					n = 8
+				}
+				e.loadEightbyte("rax", 8*i, n, reg)
+			}
+		default:
+			e.asm("movq %d(%%rsp), %s\n", pushedOffset(idx), loc.regs[0])
+		}
+	}
+	ret := -1
+	if parse.IsStructType(c.Type) {
+		ret = e.allocTemp(getSize(c.Type))
+		if sret {
+			e.asm("leaq %d(%%rbp), %%rdi\n", ret)
+		}
+	}
+	if direct == "" {
+		e.asm("movq %d(%%rsp), %%r11\n", area+8*(npushed-1))
+	}
+	if isVarArgCall(c) {
+		// Variadic callees expect an upper bound on the
+		// number of vector registers used in %al.
+		nsse := 0
+		for _, loc := range locs {
+			for _, reg := range loc.regs {
+				if isSSEReg(reg) {
+					nsse += 1
+				}
+			}
+		}
+		e.asm("movl $%d, %%eax\n", nsse)
+	}
+	if direct != "" {
+		e.asm("call %s\n", direct)
+	} else {
+		e.asm("call *%%r11\n")
+	}
+	e.asm("addq $%d, %%rsp\n", area+8*npushed)
+	e.depth -= npushed
+	switch {
+	case sret:
+		// The callee returns the buffer address in %rax.
+	case parse.IsStructType(c.Type):
+		for i, reg := range retLoc(c.Type).regs {
+			e.storeEightbyte(reg, "rbp", ret+8*i)
+		}
+		e.asm("leaq %d(%%rbp), %%rax\n", ret)
+	default:
+		e.extendRax(c.Type)
+	}
+}
+
+func isVarArgCall(c *parse.Call) bool {
+	fty, ok := c.FuncLike.GetType().(*parse.CFuncT)
+	if !ok {
+		fty = parse.Unqual(c.FuncLike.GetType()).(*parse.Ptr).PointsTo.(*parse.CFuncT)
+	}
+	// The callee may be variadic if there is no prototype.
+	return fty.IsVarArg || fty.Unprototyped
 }
 
 func (e *emitter) Cast(c *parse.Cast) {
@@ -498,31 +1500,129 @@ func (e *emitter) Cast(c *parse.Cast) {
 	from := c.Operand.GetType()
 	to := c.Type
 	switch {
+	case to == parse.CVoid:
+		return
+	case parse.Unqual(from) == to:
+		// Only removes qualifiers.
+		return
 	case parse.IsPtrType(to):
 		if parse.IsPtrType(from) || parse.IsIntType(from) {
 			return
 		}
+		// Arrays and functions decay to pointers,
+		// and their value is already their address.
+		if parse.IsArrType(from) || parse.IsCFuncType(from) {
+			return
+		}
 	case parse.IsIntType(to):
 		if parse.IsPtrType(from) || parse.IsIntType(from) {
+			e.convertRax(to)
 			return
 		}
 	}
 	panic("unimplemented cast")
 }
 
+// Converts the value in %rax, which is of at least the width of ty,
+// to ty. Any nonzero value converts to a _Bool of 1.
+func (e *emitter) convertRax(ty parse.CType) {
+	if parse.Unqual(ty) != parse.CBool {
+		e.extendRax(ty)
+		return
+	}
+	e.asm("test %%rax, %%rax\n")
+	e.asm("setne %%al\n")
+	e.asm("movzbq %%al, %%rax\n")
+}
+
+// Values in %rax are kept sign or zero extended to 64 bits
+// according to their type. After an operation which may
+// overflow the width of ty, this restores that invariant.
+func (e *emitter) extendRax(ty parse.CType) {
+	if !parse.IsIntType(ty) {
+		return
+	}
+	signed := parse.IsSignedIntType(ty)
+	switch getSize(ty) {
+	case 8:
+	case 4:
+		if signed {
+			e.asm("movslq %%eax, %%rax\n")
+		} else {
+			e.asm("movl %%eax, %%eax\n")
+		}
+	case 2:
+		if signed {
+			e.asm("movswq %%ax, %%rax\n")
+		} else {
+			e.asm("movzwq %%ax, %%rax\n")
+		}
+	case 1:
+		if signed {
+			e.asm("movsbq %%al, %%rax\n")
+		} else {
+			e.asm("movzbq %%al, %%rax\n")
+		}
+	default:
+		panic("internal error")
+	}
+}
+
 func (e *emitter) emitBinop(b *parse.Binop) {
-	if b.Op == '=' {
+	switch b.Op {
+	case '=':
 		e.Assign(b)
 		return
+	case cpp.LAND, cpp.LOR:
+		e.LogicalBinop(b)
+		return
 	}
 	e.Expr(b.L)
-	e.asm("pushq %%rax\n")
+	e.push("rax")
 	e.Expr(b.R)
 	e.asm("movq %%rax, %%rcx\n")
-	e.asm("popq %%rax\n")
+	e.pop("rax")
+	switch b.Op {
+	case cpp.EQL, cpp.NEQ, '>', '<', cpp.LEQ, cpp.GEQ:
+		e.Compare(b)
+		return
+	}
+	e.Arith(b.Op, b.Type, b.L.GetType(), b.R.GetType())
+}
+
+// Apply the binary operator op to %rax and %rcx, leaving the result in %rax.
+// ty is the result type, lty and rty are the operand types.
+func (e *emitter) Arith(op cpp.TokenKind, ty, lty, rty parse.CType) {
 	switch {
-	case parse.IsIntType(b.Type):
-		switch b.Op {
+	case parse.IsPtrType(lty) && parse.IsPtrType(rty):
+		// Pointer subtraction gives the distance in elements.
+		e.asm("subq %%rcx, %%rax\n")
+		if elem := ptrElem(lty); parse.IsVariableSize(elem) {
+			e.sizeToReg(elem, "rcx")
+			e.asm("cqto\n")
+			e.asm("idivq %%rcx\n")
+		} else if sz := ptrElemSize(lty); sz != 1 {
+			e.asm("movq $%d, %%rcx\n", sz)
+			e.asm("cqto\n")
+			e.asm("idivq %%rcx\n")
+		}
+	case parse.IsPtrType(ty):
+		if parse.IsPtrType(lty) {
+			e.scalePtrOffset("rcx", lty)
+		} else {
+			e.scalePtrOffset("rax", rty)
+		}
+		switch op {
+		case '+':
+			e.asm("addq %%rcx, %%rax\n")
+		case '-':
+			e.asm("subq %%rcx, %%rax\n")
+		default:
+			panic("internal error")
+		}
+	case parse.IsIntType(ty):
+		signed := parse.IsSignedIntType(ty)
+		switch op {
 		case '+':
 			e.asm("addq %%rcx, %%rax\n")
 		case '-':
@@ -535,113 +1635,231 @@ func (e *emitter) emitBinop(b *parse.Binop) {
 			e.asm("and %%rcx, %%rax\n")
 		case '^':
 			e.asm("xor %%rcx, %%rax\n")
-		case '/':
-			e.asm("cqto\n")
-			e.asm("idiv %%rcx\n")
-		case '%':
-			e.asm("idiv %%rcx\n")
-			e.asm("mov %%rdx, %%rax\n")
+		case '/', '%':
+			if signed {
+				e.asm("cqto\n")
+				e.asm("idivq %%rcx\n")
+			} else {
+				e.asm("xorl %%edx, %%edx\n")
+				e.asm("divq %%rcx\n")
+			}
+			if op == '%' {
+				e.asm("movq %%rdx, %%rax\n")
+			}
 		case cpp.SHL:
-			e.asm("sal %%cl, %%rax\n")
+			e.asm("shlq %%cl, %%rax\n")
 		case cpp.SHR:
-			e.asm("sar %%cl, %%rax\n")
-		case cpp.EQL, cpp.NEQ, '>', '<':
-			lset := e.NextLabel()
-			lafter := e.NextLabel()
-			opc := ""
-			switch b.Op {
-			case cpp.EQL:
-				opc = "jz"
-			case cpp.NEQ:
-				opc = "jnz"
-			case '<':
-				opc = "jl"
-			case '>':
-				opc = "jg"
-			default:
-				panic("internal error")
+			if signed {
+				e.asm("sarq %%cl, %%rax\n")
+			} else {
+				e.asm("shrq %%cl, %%rax\n")
 			}
-			e.asm("cmp %%rcx, %%rax\n")
-			e.asm("%s %s\n", opc, lset)
-			e.asm("movq $0, %%rax\n")
-			e.asm("jmp %s\n", lafter)
-			e.asm("%s:\n", lset)
-			e.asm("movq $1, %%rax\n")
-			e.asm("%s:\n", lafter)
 		default:
-			panic("unimplemented " + b.Op.String())
+			panic("unimplemented " + op.String())
+		}
+		e.extendRax(ty)
+	default:
+		panic(ty)
+	}
+}
+
+// The scale factor for arithmetic on a pointer of type ty.
+// Arithmetic on void and function pointers is a GNU extension
+// which treats the pointed to type as having size 1.
+func ptrElemSize(ty parse.CType) int {
+	pointsTo := ptrElem(ty)
+	if parse.IsVoidType(pointsTo) || parse.IsCFuncType(pointsTo) {
+		return 1
+	}
+	return getSize(pointsTo)
+}
+
+func ptrElem(ty parse.CType) parse.CType {
+	return parse.Unqual(ty).(*parse.Ptr).PointsTo
+}
+
+// Scale the integer in reg to an offset for the pointer type ty.
+// Clobbers %rdx if the pointed to type has a variable size.
+func (e *emitter) scalePtrOffset(reg string, ty parse.CType) {
+	if elem := ptrElem(ty); parse.IsVariableSize(elem) {
+		e.scaleBySize(reg, elem, "rdx")
+	} else if sz := ptrElemSize(ty); sz != 1 {
+		e.asm("imul $%d, %%%s\n", sz, reg)
+	}
+}
+
+// Compare %rax with %rcx, leaving 0 or 1 in %rax.
+// Both operands have already been converted to a common type.
+func (e *emitter) Compare(b *parse.Binop) {
+	signed := parse.IsSignedIntType(b.L.GetType())
+	opc := ""
+	switch b.Op {
+	case cpp.EQL:
+		opc = "jz"
+	case cpp.NEQ:
+		opc = "jnz"
+	case '<':
+		opc = "jb"
+		if signed {
+			opc = "jl"
+		}
+	case '>':
+		opc = "ja"
+		if signed {
+			opc = "jg"
+		}
+	case cpp.LEQ:
+		opc = "jbe"
+		if signed {
+			opc = "jle"
+		}
+	case cpp.GEQ:
+		opc = "jae"
+		if signed {
+			opc = "jge"
 		}
 	default:
-		panic(b)
+		panic("internal error")
 	}
+	lset := e.NextLabel()
+	lafter := e.NextLabel()
+	e.asm("cmp %%rcx, %%rax\n")
+	e.asm("%s %s\n", opc, lset)
+	e.asm("movq $0, %%rax\n")
+	e.asm("jmp %s\n", lafter)
+	e.asm("%s:\n", lset)
+	e.asm("movq $1, %%rax\n")
+	e.asm("%s:\n", lafter)
+}
+
+// && and || only evaluate their right operand if the
+// result is not already known from the left operand.
+func (e *emitter) LogicalBinop(b *parse.Binop) {
+	lshort := e.NextLabel()
+	lafter := e.NextLabel()
+	jmp := "jz"
+	if b.Op == cpp.LOR {
+		jmp = "jnz"
+	}
+	e.Expr(b.L)
+	e.asm("test %%rax, %%rax\n")
+	e.asm("%s %s\n", jmp, lshort)
+	e.Expr(b.R)
+	e.asm("test %%rax, %%rax\n")
+	e.asm("%s %s\n", jmp, lshort)
+	if b.Op == cpp.LOR {
+		e.asm("movq $0, %%rax\n")
+	} else {
+		e.asm("movq $1, %%rax\n")
+	}
+	e.asm("jmp %s\n", lafter)
+	e.asm("%s:\n", lshort)
+	if b.Op == cpp.LOR {
+		e.asm("movq $1, %%rax\n")
+	} else {
+		e.asm("movq $0, %%rax\n")
+	}
+	e.asm("%s:\n", lafter)
 }
 
 func (e *emitter) Unop(u *parse.Unop) {
 	switch u.Op {
 	case '&':
-		switch operand := u.Operand.(type) {
-		case *parse.Unop:
-			if operand.Op != '*' {
-				panic("internal error")
-			}
-			e.Expr(operand.Operand)
-		case *parse.Ident:
-			sym := operand.Sym
-			switch sym := sym.(type) {
-			case *parse.GSymbol:
-				e.asm("leaq %s(%%rip), %%rax\n", sym.Label)
-			case *parse.LSymbol:
-				offset := e.loffsets[sym]
-				e.asm("leaq %d(%%rbp), %%rax\n", offset)
-			default:
-				panic("internal error")
-			}
-		case *parse.Index:
-			e.Expr(operand.Idx)
-			sz := getSize(operand.GetType())
-			if sz != 1 {
-				e.asm("imul $%d, %%rax\n", sz)
-			}
-			e.asm("pushq %%rax\n")
-			e.Expr(operand.Arr)
-			e.asm("popq %%rcx\n")
-			e.asm("addq %%rcx, %%rax\n")
-		default:
-			panic("internal error")
-		}
+		e.GetAddr(u.Operand.(parse.Expr))
 	case '!':
 		e.Expr(u.Operand)
 		e.asm("xor %%rcx, %%rcx\n")
 		e.asm("test %%rax, %%rax\n")
-		e.asm("setnz %%cl\n")
+		e.asm("setz %%cl\n")
 		e.asm("movq %%rcx, %%rax\n")
 	case '-':
 		e.Expr(u.Operand)
 		e.asm("neg %%rax\n")
+		e.extendRax(u.Type)
+	case '~':
+		e.Expr(u.Operand)
+		e.asm("not %%rax\n")
+		e.extendRax(u.Type)
+	case '+':
+		e.Expr(u.Operand)
 	case '*':
 		e.Expr(u.Operand)
-		e.asm("movq (%%rax), %%rax\n")
+		e.LoadFromPtr("rax", u.Type)
+	default:
+		panic("unimplemented " + u.Op.String())
 	}
 }
 
 func (e *emitter) Index(idx *parse.Index) {
 	e.Expr(idx.Idx)
-	sz := getSize(idx.GetType())
-	if sz != 1 {
-		e.asm("imul $%d, %%rax\n", sz)
-	}
-	e.asm("push %%rax\n")
+	e.scaleBySize("rax", idx.GetType(), "rcx")
+	e.push("rax")
 	e.Expr(idx.Arr)
-	e.asm("pop %%rcx\n")
+	e.pop("rcx")
 	e.asm("addq %%rcx, %%rax\n")
 	e.LoadFromPtr("rax", idx.GetType())
 }
 
+func (e *emitter) Cond(c *parse.Cond) {
+	lelse := e.NextLabel()
+	lafter := e.NextLabel()
+	e.Expr(c.Cond)
+	e.asm("test %%rax, %%rax\n")
+	e.asm("jz %s\n", lelse)
+	e.Expr(c.Then)
+	e.asm("jmp %s\n", lafter)
+	e.asm("%s:\n", lelse)
+	e.Expr(c.Else)
+	e.asm("%s:\n", lafter)
+}
+
+func (e *emitter) CompoundAssign(c *parse.CompoundAssign) {
+	e.GetAddr(c.L)
+	e.push("rax")
+	e.Expr(c.R)
+	e.asm("movq %%rax, %%rcx\n")
+	e.asm("movq (%%rsp), %%rdx\n")
+	e.LoadFromLvalue("rdx", c.L)
+	e.extendRax(c.OpType)
+	e.Arith(c.Op, c.OpType, c.OpType, c.R.GetType())
+	e.convertRax(c.Type)
+	e.pop("rcx")
+	e.StoreToLvalue("rcx", c.L)
+}
+
+func (e *emitter) IncDec(i *parse.IncDec) {
+	e.GetAddr(i.Operand)
+	e.asm("movq %%rax, %%rcx\n")
+	e.LoadFromLvalue("rcx", i.Operand)
+	if i.Post {
+		e.asm("movq %%rax, %%rdx\n")
+	}
+	op := "addq"
+	if i.Op == cpp.DEC {
+		op = "subq"
+	}
+	if parse.IsPtrType(i.Type) && parse.IsVariableSize(ptrElem(i.Type)) {
+		e.sizeToReg(ptrElem(i.Type), "r10")
+		e.asm("%s %%r10, %%rax\n", op)
+	} else {
+		amount := 1
+		if parse.IsPtrType(i.Type) {
+			amount = ptrElemSize(i.Type)
+		}
+		e.asm("%s $%d, %%rax\n", op, amount)
+	}
+	e.convertRax(i.Type)
+	e.StoreToLvalue("rcx", i.Operand)
+	if i.Post {
+		e.asm("movq %%rdx, %%rax\n")
+	}
+}
+
 func (e *emitter) Assign(b *parse.Binop) {
 	e.Expr(b.R)
-	e.asm("pushq %%rax\n")
+	e.push("rax")
 	e.GetAddr(b.L)
 	e.asm("movq %%rax, %%rcx\n")
-	e.asm("popq %%rax\n")
-	e.StoreToPtr("rcx", b.L.GetType())
+	e.pop("rax")
+	e.StoreToLvalue("rcx", b.L)
 }
@@ -29,7 +29,7 @@ func printUsage() {
 	fmt.Println("Software by Andrew Chambers 2014-2015 - andrewchamberss@gmail.com")
 }
 
-func compileFile(path string, out io.Writer) error {
+func compileFile(path string, opts parse.Options, out io.Writer) error {
 	f, err := os.Open(path)
 	if err != nil {
 		err = fmt.Errorf("Failed to open source file %s for parsing: %s\n", path, err)
@@ -37,7 +37,7 @@ func compileFile(path string, out io.Writer) error {
 	}
 	lexer := cpp.Lex(path, f)
 	pp := cpp.New(lexer, nil)
-	tu, err := parse.Parse(x64SzDesc, pp)
+	tu, err := parse.Parse(x64SzDesc, opts, pp)
 	if err != nil {
 		return err
 	}
@@ -48,6 +48,8 @@ func main() {
 	flag.Usage = printUsage
 	version := flag.Bool("version", false, "Print version info and exit.")
 	outputPath := flag.String("o", "-", "Write output to `file`, '-' for stdout.")
+	std := flag.String("std", "gnu11", "Language `standard` to accept, one of c11 or gnu11.")
+	werror := flag.Bool("Werror", false, "Treat warnings as errors.")
 	flag.Parse()
 	if *version {
 		printVersion()
@@ -61,6 +63,20 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Bad number of args, please specify a single source file.\n")
 		os.Exit(1)
 	}
+	var opts parse.Options
+	switch *std {
+	case "c11":
+	case "gnu11":
+		opts.GNU = true
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown language standard %s.\n", *std)
+		os.Exit(1)
+	}
+	nwarnings := 0
+	opts.Warn = func(err error) {
+		nwarnings += 1
+		report.ReportWarning(err)
+	}
 	input := flag.Args()[0]
 	var output io.WriteCloser
 	var err error
@@ -73,9 +89,13 @@ func main() {
 			os.Exit(1)
 		}
 	}
-	err = compileFile(input, output)
+	err = compileFile(input, opts, output)
 	if err != nil {
 		report.ReportError(err)
 		os.Exit(1)
 	}
+	if *werror && nwarnings != 0 {
+		fmt.Fprintf(os.Stderr, "Warnings treated as errors.\n")
+		os.Exit(1)
+	}
 }
@@ -6,6 +6,8 @@ import (
 
 var primSizeTab = [...]int{
 	parse.CVoid:   0,
+	parse.CEnum:   4,
+	parse.CBool:   1,
 	parse.CChar:   1,
 	parse.CUChar:  1,
 	parse.CShort:  2,
@@ -16,10 +18,13 @@ var primSizeTab = [...]int{
 	parse.CULong:  8,
 	parse.CLLong:  8,
 	parse.CULLong: 8,
+	parse.CFloat:  4,
+	parse.CDouble: 8,
 }
 
 var primAlignTab = [...]int{
 	parse.CVoid:   0,
+	parse.CEnum:   4,
 	parse.CBool:   1,
 	parse.CChar:   1,
 	parse.CUChar:  1,
@@ -31,39 +36,22 @@ var primAlignTab = [...]int{
 	parse.CULong:  8,
 	parse.CLLong:  8,
 	parse.CULLong: 8,
+	parse.CFloat:  4,
+	parse.CDouble: 8,
 }
 
-func getSize(t parse.CType) int {
-	switch t := t.(type) {
-	case *parse.CStruct:
-		sz := 0
-		for _, t := range t.Types {
-			sz += getSize(t)
-		}
-		return sz
-	case *parse.Array:
-		return t.Dim * getSize(t.MemberType)
-	case *parse.Ptr:
-		return 8
-	case parse.Primitive:
-		return primSizeTab[t]
-	}
-	panic(t)
+var x64SzDesc = parse.TargetSizeDesc{
+	GetPrimSize:  func(p parse.Primitive) int { return primSizeTab[p] },
+	GetPrimAlign: func(p parse.Primitive) int { return primAlignTab[p] },
+	PtrSize:      8,
+	PtrAlign:     8,
+	MaxAlign:     16,
 }
 
-func getAlign(t parse.CType) int {
-	switch t := t.(type) {
-	case *parse.Array:
-		return 8
-	case *parse.Ptr:
-		return 8
-	case parse.Primitive:
-		return primAlignTab[t]
-	}
-	panic(t)
+func getSize(t parse.CType) int {
+	return x64SzDesc.GetSize(t)
 }
 
-var x64SzDesc = parse.TargetSizeDesc{
-	GetSize:  getSize,
-	GetAlign: getAlign,
+func getAlign(t parse.CType) int {
+	return x64SzDesc.GetAlign(t)
 }
@@ -21,14 +21,29 @@ type Preprocessor struct {
 
 	//Stack of condContext about #ifdefs blocks
 	conditionalStack *list.List
+
+	// Reports whether a name is a builtin for __has_builtin, may be nil.
+	hasBuiltin func(string) bool
 }
 
 type condContext struct {
 	hasSucceeded bool
+	// Whether the #else of the conditional has been reached,
+	// after which no #else or #elif may follow.
+	seenElse bool
+	// Where the directive starting the conditional is.
+	pos FilePos
+}
+
+// Sets how __has_builtin in a conditional directive decides if a name
+// is a builtin. The compiler proper knows its builtins, not the
+// preprocessor.
+func (pp *Preprocessor) SetHasBuiltin(f func(string) bool) {
+	pp.hasBuiltin = f
 }
 
-func (pp *Preprocessor) pushCondContext() {
-	pp.conditionalStack.PushBack(&condContext{false})
+func (pp *Preprocessor) pushCondContext(pos FilePos) {
+	pp.conditionalStack.PushBack(&condContext{false, false, pos})
 }
 
 func (pp *Preprocessor) popCondContext() {
@@ -42,6 +57,22 @@ func (pp *Preprocessor) markCondContextSucceeded() {
 	pp.conditionalStack.Back().Value.(*condContext).hasSucceeded = true
 }
 
+func (pp *Preprocessor) condContextSucceeded() bool {
+	return pp.conditionalStack.Back().Value.(*condContext).hasSucceeded
+}
+
+// Checks an #else or #elif of the innermost conditional comes
+// before its #else.
+func (pp *Preprocessor) checkElse(dirTok *Token) {
+	ctx := pp.conditionalStack.Back().Value.(*condContext)
+	if ctx.seenElse {
+		pp.cppError(fmt.Sprintf("#%s after #else", dirTok.Val), dirTok.Pos)
+	}
+	if dirTok.Val == "else" {
+		ctx.seenElse = true
+	}
+}
+
 func (pp *Preprocessor) condDepth() int {
 	return pp.conditionalStack.Len()
 }
@@ -107,6 +138,9 @@ func (pp *Preprocessor) Next() (t *Token, err error) {
 		pp.handleDirective(t)
 		t = pp.nextNoExpand()
 	}
+	if t.Kind == EOF && pp.condDepth() > 0 {
+		pp.cppError("unclosed preprocessor conditional", pp.conditionalStack.Back().Value.(*condContext).pos)
+	}
 
 	if t.hs.contains(t.Val) {
 		return t, nil
@@ -122,7 +156,10 @@ func (pp *Preprocessor) Next() (t *Token, err error) {
 	fmacro, ok := pp.funcMacros[t.Val]
 	if ok {
 		opening := pp.nextNoExpand()
-		if opening.Kind == LPAREN {
+		if opening.Kind != LPAREN {
+			// Not an invocation, the name is an ordinary identifier.
+			pp.ungetToken(opening)
+		} else {
 			args, rparen, err := pp.readMacroInvokeArguments()
 			if len(args) != fmacro.nargs {
 				return &Token{}, fmt.Errorf("macro %s invoked with %d arguments but %d were expected at %s", t.Val, len(args), fmacro.nargs, t.Pos)
@@ -207,18 +244,98 @@ func (pp *Preprocessor) ungetToken(t *Token) {
 	pp.tl.prepend(t)
 }
 
-func (pp *Preprocessor) handleIf(pos FilePos) {
-	pp.pushCondContext()
-	//Pretend it fails...
-	pp.skipTillEndif(pos)
+// Reads the rest of the current directive line.
+func (pp *Preprocessor) directiveTokens() *tokenList {
+	tl := newTokenList()
+	for {
+		t := pp.nextNoExpand()
+		if t.Kind == END_DIRECTIVE || t.Kind == EOF {
+			return tl
+		}
+		tl.append(t)
+	}
+}
+
+// Reads the condition of an #if or #elif with macros expanded, except
+// in the operands of defined and __has_builtin, which are hidden from
+// expansion.
+func (pp *Preprocessor) condTokens() *tokenList {
+	line := newTokenList()
+	hide := false
+	for e := pp.directiveTokens().front(); e != nil; e = e.Next() {
+		t := e.Value.(*Token)
+		if hide && t.Kind == IDENT {
+			t.hs = t.hs.add(t.Val)
+			hide = false
+		}
+		if t.Kind == IDENT && (t.Val == "defined" || t.Val == "__has_builtin") {
+			hide = true
+		}
+		line.append(t)
+	}
+	// Ends the line, the lexer only produces them after a directive.
+	line.append(&Token{Kind: END_DIRECTIVE})
+	pp.ungetTokens(line)
+	expanded := newTokenList()
+	for {
+		t, err := pp.Next()
+		if err != nil {
+			panic(&cppbreakout{t, err})
+		}
+		if t.Kind == END_DIRECTIVE {
+			return expanded
+		}
+		expanded.append(t)
+	}
 }
 
-func (pp *Preprocessor) handleIfDef(pos FilePos) {
-	pp.pushCondContext()
-	//Pretend it fails...
+// Evaluates the condition of an #if or #elif.
+func (pp *Preprocessor) evalCond(pos FilePos) bool {
+	v, err := evalIfExpr(pp.isDefined, pp.hasBuiltin, pp.condTokens())
+	if err != nil {
+		pp.cppError(fmt.Sprintf("%s in preprocessor conditional", err), pos)
+	}
+	return v != 0
+}
+
+// Starts a conditional, including the group which follows
+// if cond is true and skipping it otherwise.
+func (pp *Preprocessor) startCond(pos FilePos, cond bool) {
+	pp.pushCondContext(pos)
+	if cond {
+		pp.markCondContextSucceeded()
+		return
+	}
 	pp.skipTillEndif(pos)
 }
 
+func (pp *Preprocessor) handleIf(pos FilePos) {
+	pp.startCond(pos, pp.evalCond(pos))
+}
+
+func (pp *Preprocessor) handleIfDef(pos FilePos, want bool) {
+	ident := pp.nextNoExpand()
+	if ident.Kind != IDENT {
+		pp.cppError("expected an identifier after #ifdef or #ifndef", ident.Pos)
+	}
+	endTok := pp.nextNoExpand()
+	if endTok.Kind != END_DIRECTIVE {
+		pp.cppError("unexpected token after #ifdef or #ifndef", endTok.Pos)
+	}
+	pp.startCond(pos, pp.isDefined(ident.Val) == want)
+}
+
+// An #else or #elif reached while including a group ends the
+// conditional, so the rest of it is skipped.
+func (pp *Preprocessor) handleElse(dirTok *Token) {
+	if pp.condDepth() <= 0 {
+		pp.cppError("#else or #elif without #if", dirTok.Pos)
+	}
+	pp.checkElse(dirTok)
+	pp.directiveTokens()
+	pp.skipTillEndif(dirTok.Pos)
+}
+
 func (pp *Preprocessor) handleEndif(pos FilePos) {
 	if pp.condDepth() <= 0 {
 		pp.cppError("stray #endif", pos)
@@ -230,27 +347,47 @@ func (pp *Preprocessor) handleEndif(pos FilePos) {
 	}
 }
 
-//XXX untested
+// Skips a group of the innermost conditional up to its #endif, or
+// up to an #else or true #elif if no earlier group was included.
 func (pp *Preprocessor) skipTillEndif(pos FilePos) {
 	depth := 1
 	for {
 		//Dont care about expands since we are skipping.
 		t := pp.nextNoExpand()
-		if t == nil {
+		if t == nil || t.Kind == EOF {
 			pp.cppError("unclosed preprocessor conditional", pos)
 		}
-
-		if t.Kind == DIRECTIVE && (t.Val == "if" || t.Val == "ifdef" || t.Val == "ifndef") {
-			depth += 1
+		if t.Kind != DIRECTIVE {
 			continue
 		}
-
-		if t.Kind == DIRECTIVE && t.Val == "endif" {
+		switch t.Val {
+		case "if", "ifdef", "ifndef":
+			depth += 1
+		case "else":
+			if depth != 1 {
+				continue
+			}
+			pp.checkElse(t)
+			if !pp.condContextSucceeded() {
+				pp.directiveTokens()
+				pp.markCondContextSucceeded()
+				return
+			}
+		case "elif":
+			if depth != 1 {
+				continue
+			}
+			pp.checkElse(t)
+			if !pp.condContextSucceeded() && pp.evalCond(t.Pos) {
+				pp.markCondContextSucceeded()
+				return
+			}
+		case "endif":
 			depth -= 1
-		}
-
-		if depth == 0 {
-			break
+			if depth == 0 {
+				pp.handleEndif(t.Pos)
+				return
+			}
 		}
 	}
 }
@@ -263,12 +400,13 @@ func (pp *Preprocessor) handleDirective(dirTok *Token) {
 	case "if":
 		pp.handleIf(dirTok.Pos)
 	case "ifdef":
-		pp.handleIfDef(dirTok.Pos)
+		pp.handleIfDef(dirTok.Pos, true)
+	case "ifndef":
+		pp.handleIfDef(dirTok.Pos, false)
+	case "elif", "else":
+		pp.handleElse(dirTok)
 	case "endif":
 		pp.handleEndif(dirTok.Pos)
-	//case "ifndef":
-	//case "elif":
-	//case "else":
 	case "undef":
 		pp.handleUndefine()
 	case "define":
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 /*
@@ -21,14 +22,20 @@ import (
 
    Character constants, which are interpreted as they would be in normal code.
 
-   Arithmetic operators for most of C
+   Arithmetic operators for most of C, computed in intmax_t, or in uintmax_t
+   when an operand is unsigned.
 
    Identifiers that are not macros, which are all considered to be the number zero.
+   Macros are expanded before the expression is evaluated, except for the
+   operands of defined and __has_builtin.
+
+   __has_builtin(name), which is one if name is a builtin of the compiler.
 */
 
 type cppExprCtx struct {
-	e         *list.Element
-	isDefined func(string) bool
+	e          *list.Element
+	isDefined  func(string) bool
+	hasBuiltin func(string) bool
 }
 
 func (ctx *cppExprCtx) nextToken() *Token {
@@ -47,183 +54,214 @@ func (ctx *cppExprCtx) peek() *Token {
 	return ctx.e.Value.(*Token)
 }
 
-func parseCPPExprAtom(ctx *cppExprCtx) (int64, error) {
+// A value in a #if expression, which has the type intmax_t or
+// uintmax_t, both 64 bits (C11 6.10.1p4).
+type cppValue struct {
+	v        int64
+	unsigned bool
+}
+
+func cppBool(b bool) cppValue {
+	if b {
+		return cppValue{v: 1}
+	}
+	return cppValue{v: 0}
+}
+
+func parseCPPExprAtom(ctx *cppExprCtx) (cppValue, error) {
 	toCheck := ctx.nextToken()
 	if toCheck == nil {
-		return 0, fmt.Errorf("expected integer, char, or defined but got nothing")
+		return cppValue{}, fmt.Errorf("expected integer, char, or defined but got nothing")
 	}
 	switch toCheck.Kind {
 	case NOT:
 		v, err := parseCPPExprAtom(ctx)
-		if v == 0 {
-			return 1, nil
+		if err != nil {
+			return cppValue{}, err
 		}
-		return 0, err
+		return cppBool(v.v == 0), nil
 	case BNOT:
 		v, err := parseCPPExprAtom(ctx)
 		if err != nil {
-			return 0, err
+			return cppValue{}, err
 		}
-		return ^v, nil
+		v.v = ^v.v
+		return v, nil
 	case SUB:
 		v, err := parseCPPExprAtom(ctx)
 		if err != nil {
-			return 0, err
-		}
-		return -v, nil
-	case ADD:
-		v, err := parseCPPExprAtom(ctx)
-		if err != nil {
-			return 0, err
+			return cppValue{}, err
 		}
+		v.v = -v.v
 		return v, nil
+	case ADD:
+		return parseCPPExprAtom(ctx)
 	case LPAREN:
 		v, err := parseCPPExpr(ctx)
 		if err != nil {
-			return 0, err
+			return cppValue{}, err
 		}
 		rparen := ctx.nextToken()
 		if rparen == nil || rparen.Kind != RPAREN {
-			return 0, fmt.Errorf("unclosed parenthesis")
+			return cppValue{}, fmt.Errorf("unclosed parenthesis")
 		}
 		return v, nil
 	case INT_CONSTANT:
-		v, err := strconv.ParseInt(toCheck.Val, 0, 64)
+		digits := strings.TrimRight(toCheck.Val, "uUlL")
+		uv, err := strconv.ParseUint(digits, 0, 64)
 		if err != nil {
-			return 0, fmt.Errorf("internal error parsing int constant")
+			return cppValue{}, fmt.Errorf("internal error parsing int constant")
 		}
-		return v, nil
+		// Constants too large for intmax_t are unsigned.
+		unsigned := strings.ContainsAny(toCheck.Val[len(digits):], "uU") || int64(uv) < 0
+		return cppValue{v: int64(uv), unsigned: unsigned}, nil
 	case CHAR_CONSTANT:
 
-		return 0, fmt.Errorf("unimplemented char literal in cpp expression")
+		return cppValue{}, fmt.Errorf("unimplemented char literal in cpp expression")
 	case IDENT:
+		if toCheck.Val == "__has_builtin" {
+			return parseHasBuiltin(ctx)
+		}
 		if toCheck.Val == "defined" {
 			toCheck = ctx.nextToken()
 			if toCheck == nil {
-				return 0, fmt.Errorf("expected ( or an identifier but got nothing")
+				return cppValue{}, fmt.Errorf("expected ( or an identifier but got nothing")
 			}
 			switch toCheck.Kind {
 			case LPAREN:
 				toCheck = ctx.nextToken()
 				rparen := ctx.nextToken()
 				if rparen == nil || rparen.Kind != RPAREN {
-					return 0, fmt.Errorf("malformed defined check, missing )")
+					return cppValue{}, fmt.Errorf("malformed defined check, missing )")
 				}
 			case IDENT:
 				//calls isDefined as intended
 			default:
-				return 0, fmt.Errorf("malformed defined statement at %s", toCheck.Pos)
+				return cppValue{}, fmt.Errorf("malformed defined statement at %s", toCheck.Pos)
 			}
+		} else {
+			// Macros are expanded before evaluation, so this
+			// is not one, or is function-like and not invoked.
+			return cppValue{}, nil
 		}
 	default:
-		return 0, fmt.Errorf("expected integer, char, or defined but got %s", toCheck.Val)
+		return cppValue{}, fmt.Errorf("expected integer, char, or defined but got %s", toCheck.Val)
 	}
 	if toCheck == nil {
-		return 0, fmt.Errorf("expected identifier but got nothing")
+		return cppValue{}, fmt.Errorf("expected identifier but got nothing")
 	}
-	if ctx.isDefined(toCheck.Val) {
-		return 1, nil
+	return cppBool(ctx.isDefined(toCheck.Val)), nil
+}
+
+func parseHasBuiltin(ctx *cppExprCtx) (cppValue, error) {
+	lparen := ctx.nextToken()
+	name := ctx.nextToken()
+	rparen := ctx.nextToken()
+	if lparen == nil || lparen.Kind != LPAREN || name == nil || name.Kind != IDENT || rparen == nil || rparen.Kind != RPAREN {
+		return cppValue{}, fmt.Errorf("malformed __has_builtin, expected (identifier)")
 	}
-	return 0, nil
+	return cppBool(ctx.hasBuiltin != nil && ctx.hasBuiltin(name.Val)), nil
 }
 
-func evalCPPBinop(ctx *cppExprCtx, k TokenKind, l int64, r int64) (int64, error) {
+// Evaluates l k r. The operands of arithmetic and comparisons are
+// converted to uintmax_t if either is unsigned, while a shift has
+// the type of its left operand.
+func evalCPPBinop(ctx *cppExprCtx, k TokenKind, l cppValue, r cppValue) (cppValue, error) {
+	unsigned := l.unsigned || r.unsigned
+	arith := func(v int64) (cppValue, error) {
+		return cppValue{v: v, unsigned: unsigned}, nil
+	}
+	less := func(a, b int64) bool {
+		if unsigned {
+			return uint64(a) < uint64(b)
+		}
+		return a < b
+	}
 	switch k {
 	case LOR:
-		if l != 0 || r != 0 {
-			return 1, nil
-		}
-		return 0, nil
+		return cppBool(l.v != 0 || r.v != 0), nil
 	case LAND:
-		if l != 0 && r != 0 {
-			return 1, nil
-		}
-		return 0, nil
+		return cppBool(l.v != 0 && r.v != 0), nil
 	case OR:
-		return l | r, nil
+		return arith(l.v | r.v)
 	case XOR:
-		return l ^ r, nil
+		return arith(l.v ^ r.v)
 	case AND:
-		return l & r, nil
+		return arith(l.v & r.v)
 	case ADD:
-		return l + r, nil
+		return arith(l.v + r.v)
 	case SUB:
-		return l - r, nil
+		return arith(l.v - r.v)
 	case MUL:
-		return l * r, nil
+		return arith(l.v * r.v)
 	case SHR:
-		return l >> uint64(r), nil
+		if l.unsigned {
+			l.v = int64(uint64(l.v) >> uint64(r.v))
+		} else {
+			l.v >>= uint64(r.v)
+		}
+		return l, nil
 	case SHL:
-		return l << uint64(r), nil
-	case QUO:
-		if r == 0 {
-			return 0, fmt.Errorf("divide by zero in expression")
+		l.v <<= uint64(r.v)
+		return l, nil
+	case QUO, REM:
+		if r.v == 0 {
+			return cppValue{}, fmt.Errorf("divide by zero in expression")
 		}
-		return l / r, nil
-	case REM:
-		if r == 0 {
-			return 0, fmt.Errorf("divide by zero in expression")
+		switch {
+		case unsigned && k == QUO:
+			return arith(int64(uint64(l.v) / uint64(r.v)))
+		case unsigned:
+			return arith(int64(uint64(l.v) % uint64(r.v)))
+		case k == QUO:
+			return arith(l.v / r.v)
 		}
-		return l % r, nil
+		return arith(l.v % r.v)
 	case EQL:
-		if l == r {
-			return 1, nil
-		}
-		return 0, nil
+		return cppBool(l.v == r.v), nil
 	case LSS:
-		if l < r {
-			return 1, nil
-		}
-		return 0, nil
+		return cppBool(less(l.v, r.v)), nil
 	case GTR:
-		if l > r {
-			return 1, nil
-		}
-		return 0, nil
+		return cppBool(less(r.v, l.v)), nil
 	case LEQ:
-		if l <= r {
-			return 1, nil
-		}
-		return 0, nil
+		return cppBool(!less(r.v, l.v)), nil
 	case GEQ:
-		if l >= r {
-			return 1, nil
-		}
-		return 0, nil
+		return cppBool(!less(l.v, r.v)), nil
 	case NEQ:
-		if l != r {
-			return 1, nil
-		}
-		return 0, nil
+		return cppBool(l.v != r.v), nil
 	case COMMA:
 		return r, nil
 	default:
-		return 0, fmt.Errorf("internal error %s", k)
+		return cppValue{}, fmt.Errorf("internal error %s", k)
 	}
 }
 
-func parseCPPTernary(ctx *cppExprCtx) (int64, error) {
+func parseCPPTernary(ctx *cppExprCtx) (cppValue, error) {
 	cond, err := parseCPPBinop(ctx)
 	if err != nil {
-		return 0, err
+		return cppValue{}, err
 	}
 	t := ctx.peek()
-	var a, b int64
+	var a, b cppValue
 	if t != nil && t.Kind == QUESTION {
 		ctx.nextToken()
 		a, err = parseCPPExpr(ctx)
 		if err != nil {
-			return 0, err
+			return cppValue{}, err
 		}
 		colon := ctx.nextToken()
 		if colon == nil || colon.Kind != COLON {
-			return 0, fmt.Errorf("ternary without :")
+			return cppValue{}, fmt.Errorf("ternary without :")
 		}
 		b, err = parseCPPExpr(ctx)
 		if err != nil {
-			return 0, err
+			return cppValue{}, err
 		}
-		if cond != 0 {
+		// The result has the type of both operands after
+		// the usual arithmetic conversions.
+		a.unsigned = a.unsigned || b.unsigned
+		b.unsigned = a.unsigned
+		if cond.v != 0 {
 			return a, nil
 		}
 		return b, nil
@@ -231,10 +269,10 @@ func parseCPPTernary(ctx *cppExprCtx) (int64, error) {
 	return cond, nil
 }
 
-func parseCPPComma(ctx *cppExprCtx) (int64, error) {
+func parseCPPComma(ctx *cppExprCtx) (cppValue, error) {
 	v, err := parseCPPTernary(ctx)
 	if err != nil {
-		return 0, err
+		return cppValue{}, err
 	}
 	for {
 		t := ctx.peek()
@@ -244,7 +282,7 @@ func parseCPPComma(ctx *cppExprCtx) (int64, error) {
 		ctx.nextToken()
 		v, err = parseCPPTernary(ctx)
 		if err != nil {
-			return 0, err
+			return cppValue{}, err
 		}
 	}
 	return v, nil
@@ -279,10 +317,10 @@ func getPrec(k TokenKind) int {
 // This is the precedence climbing algorithm, simplified because
 // all the operators are left associative. The CPP doesn't
 // deal with assignment operators.
-func parseCPPBinop_1(ctx *cppExprCtx, prec int) (int64, error) {
+func parseCPPBinop_1(ctx *cppExprCtx, prec int) (cppValue, error) {
 	l, err := parseCPPExprAtom(ctx)
 	if err != nil {
-		return 0, err
+		return cppValue{}, err
 	}
 	for {
 		t := ctx.peek()
@@ -299,26 +337,26 @@ func parseCPPBinop_1(ctx *cppExprCtx, prec int) (int64, error) {
 		ctx.nextToken()
 		r, err := parseCPPBinop_1(ctx, p+1)
 		if err != nil {
-			return 0, err
+			return cppValue{}, err
 		}
 		l, err = evalCPPBinop(ctx, t.Kind, l, r)
 		if err != nil {
-			return 0, err
+			return cppValue{}, err
 		}
 	}
 	return l, nil
 }
 
-func parseCPPBinop(ctx *cppExprCtx) (int64, error) {
+func parseCPPBinop(ctx *cppExprCtx) (cppValue, error) {
 	return parseCPPBinop_1(ctx, 0)
 }
 
-func parseCPPExpr(ctx *cppExprCtx) (int64, error) {
+func parseCPPExpr(ctx *cppExprCtx) (cppValue, error) {
 	return parseCPPComma(ctx)
 }
 
-func evalIfExpr(isDefined func(string) bool, tl *tokenList) (int64, error) {
-	ctx := &cppExprCtx{isDefined: isDefined, e: tl.l.Front()}
+func evalIfExpr(isDefined, hasBuiltin func(string) bool, tl *tokenList) (int64, error) {
+	ctx := &cppExprCtx{isDefined: isDefined, hasBuiltin: hasBuiltin, e: tl.l.Front()}
 	ret, err := parseCPPExpr(ctx)
 	if err != nil {
 		return 0, err
@@ -327,5 +365,5 @@ func evalIfExpr(isDefined func(string) bool, tl *tokenList) (int64, error) {
 	if t != nil {
 		return 0, fmt.Errorf("stray token %s", t.Val)
 	}
-	return ret, nil
+	return ret.v, nil
 }
@@ -19,7 +19,11 @@ var exprTestCases = []struct {
 	{"(2)", 2, false},
 	{"(-2)", -2, false},
 	{"0x1234", 0x1234, false},
-	{"foo", 1, false},
+	{"10UL", 10, false},
+	{"0x10ll", 16, false},
+	{"1u + 1", 2, false},
+	{"foo", 0, false},
+	{"foo || defined foo", 1, false},
 	{"bang", 0, false},
 	{"defined foo", 1, false},
 	{"defined bang", 0, false},
@@ -75,6 +79,34 @@ var exprTestCases = []struct {
 	{"(0 ? 1 ? 1337 : 1234 : 2) == 2", 1, false},
 	{"(0 ? 1 ? 1337 : 1234 : 2 ? 3 : 4) == 3", 1, false},
 	{"0 , 1 ? 1 , 0 : 2  ", 0, false},
+	{"__has_builtin(__builtin_foo)", 1, false},
+	{"__has_builtin(__builtin_bar) || 2", 1, false},
+	{"__has_builtin(foo)", 0, false},
+	{"!__has_builtin(foo)", 1, false},
+	{"__has_builtin(foo", 0, true},
+	{"-1 < 0u", 0, false},
+	{"-1 < 0", 1, false},
+	{"-1 > 0u", 1, false},
+	{"0u >= -1", 0, false},
+	{"-1 == 0xffffffffffffffff", 1, false},
+	{"0xffffffffffffffff > 0", 1, false},
+	{"18446744073709551615 > 0", 1, false},
+	{"0xffffffffffffffff / 2 > 0", 1, false},
+	{"0xffffffffffffffff / 2 == 0x7fffffffffffffff", 1, false},
+	{"-1 / 2u == 0x7fffffffffffffff", 1, false},
+	{"-7 / 2", -3, false},
+	{"-7 % 2", -1, false},
+	{"-7 % 2u == 1", 1, false},
+	{"-1 >> 63", -1, false},
+	{"-1u >> 63", 1, false},
+	{"(0u - 1) >> 63", 1, false},
+	{"-1 >> 63u", -1, false},
+	{"(1 ? -1 : 0u) > 0", 1, false},
+	{"(0 ? 0u : -1) > 0", 1, false},
+	{"(1 ? -1 : 0) > 0", 0, false},
+	{"!0u - 2 < 0", 1, false},
+	{"(1u < 2) - 2 < 0", 1, false},
+	{"__has_builtin foo", 0, true},
 }
 
 var testExprPredefined = map[string]struct{}{
@@ -92,6 +124,9 @@ func TestExprEval(t *testing.T) {
 			_, ok := testExprPredefined[s]
 			return ok
 		}
+		hasBuiltin := func(s string) bool {
+			return s == "__builtin_foo"
+		}
 		tl := newTokenList()
 		for {
 			tok, err := lexer.Next()
@@ -103,7 +138,7 @@ func TestExprEval(t *testing.T) {
 			}
 			tl.append(tok)
 		}
-		result, err := evalIfExpr(isDefined, tl)
+		result, err := evalIfExpr(isDefined, hasBuiltin, tl)
 		if err != nil {
 			if !tc.expectErr {
 				t.Errorf("test %s failed - got error <%s>", tc.expr, err)
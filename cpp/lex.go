@@ -198,6 +198,12 @@ func (lx *Lexer) lex() {
 				second, _ := lx.readRune()
 				switch second {
 				case '<':
+					third, _ := lx.readRune()
+					if third == '=' {
+						lx.sendTok(SHL_ASSIGN, "<<=")
+						break
+					}
+					lx.unreadRune()
 					lx.sendTok(SHL, "<<")
 				case '=':
 					lx.sendTok(LEQ, "<=")
@@ -209,6 +215,12 @@ func (lx *Lexer) lex() {
 				second, _ := lx.readRune()
 				switch second {
 				case '>':
+					third, _ := lx.readRune()
+					if third == '=' {
+						lx.sendTok(SHR_ASSIGN, ">>=")
+						break
+					}
+					lx.unreadRune()
 					lx.sendTok(SHR, ">>")
 				case '=':
 					lx.sendTok(GEQ, ">=")
@@ -228,6 +240,12 @@ func (lx *Lexer) lex() {
 					lx.sendTok(ADD, "+")
 				}
 			case '.':
+				if next, err := lx.brdr.Peek(2); err == nil && string(next) == ".." {
+					lx.readRune()
+					lx.readRune()
+					lx.sendTok(ELLIPSIS, "...")
+					break
+				}
 				second, _ := lx.readRune()
 				lx.unreadRune()
 				if isNumeric(second) {
@@ -241,7 +259,7 @@ func (lx *Lexer) lex() {
 				second, _ := lx.readRune()
 				switch second {
 				case '=':
-					lx.sendTok(XOR_ASSIGN, "-=")
+					lx.sendTok(XOR_ASSIGN, "^=")
 				default:
 					lx.unreadRune()
 					lx.sendTok(XOR, "^")
@@ -305,7 +323,7 @@ func (lx *Lexer) lex() {
 						}
 					}
 				case '=':
-					lx.sendTok(QUO_ASSIGN, "/")
+					lx.sendTok(QUO_ASSIGN, "/=")
 				default:
 					lx.unreadRune()
 					lx.sendTok(QUO, "/")
@@ -550,7 +568,13 @@ func (lx *Lexer) readConstantIntOrFloat(startedWithPeriod bool) {
 				state = FLOAT_START
 				tokType = FLOAT_CONSTANT
 				buff.WriteRune(r)
+			} else if r == 'l' || r == 'L' || r == 'u' || r == 'U' {
+				state = INT_TAIL
+				buff.WriteRune(r)
 			} else {
+				if isValidIdentStart(r) {
+					lx.Error("invalid constant int")
+				}
 				state = END
 			}
 		case DEC:
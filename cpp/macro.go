@@ -40,7 +40,7 @@ func newFuncMacro(args *tokenList, tokens *tokenList) (*funcMacro, error) {
 		tok := e.Value.(*Token)
 		_, ok := ret.args[tok.Val]
 		if ok {
-			return nil, fmt.Errorf("error duplicate argument " + tok.Val)
+			return nil, fmt.Errorf("error duplicate argument %s", tok.Val)
 		}
 		ret.args[tok.Val] = idx
 		ret.nargs += 1
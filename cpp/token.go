@@ -73,6 +73,7 @@ const (
 	ELLIPSIS   // ...
 
 	// Keywords
+	AUTO
 	REGISTER
 	EXTERN
 	STATIC
@@ -90,11 +91,25 @@ const (
 	IF
 	RETURN
 	STRUCT
+	ENUM
+	BOOL
+	INLINE
 	UNION
 	VOLATILE
+	RESTRICT
+	ATOMIC
+	ALIGNAS
+	NORETURN
+	STATIC_ASSERT
+	GENERIC
+	ATTRIBUTE
+	TYPEOF
+	AUTO_TYPE
+	ASM
 	SWITCH
 	TYPEDEF
 	SIZEOF
+	ALIGNOF
 	VOID
 	CHAR
 	INT
@@ -172,10 +187,22 @@ var tokenKindToStr = [...]string{
 	COLON:           "':'",
 	QUESTION:        "'?'",
 	SIZEOF:          "sizeof",
+	ALIGNOF:         "_Alignof",
 	TYPEDEF:         "typedef",
 	BREAK:           "break",
 	CASE:            "case",
 	CONST:           "const",
+	VOLATILE:        "volatile",
+	RESTRICT:        "restrict",
+	ATOMIC:          "_Atomic",
+	ALIGNAS:         "_Alignas",
+	NORETURN:        "_Noreturn",
+	STATIC_ASSERT:   "_Static_assert",
+	GENERIC:         "_Generic",
+	ATTRIBUTE:       "__attribute__",
+	TYPEOF:          "typeof",
+	AUTO_TYPE:       "__auto_type",
+	ASM:             "asm",
 	CONTINUE:        "continue",
 	DEFAULT:         "default",
 	ELSE:            "else",
@@ -186,40 +213,86 @@ var tokenKindToStr = [...]string{
 	IF:              "if",
 	RETURN:          "return",
 	STRUCT:          "struct",
+	ENUM:            "enum",
+	BOOL:            "_Bool",
+	INLINE:          "inline",
+	UNION:           "union",
 	SWITCH:          "switch",
 	STATIC:          "static",
+	EXTERN:          "extern",
+	REGISTER:        "register",
+	AUTO:            "auto",
 }
 
 var keywordLUT = map[string]TokenKind{
-	"for":      FOR,
-	"while":    WHILE,
-	"do":       DO,
-	"if":       IF,
-	"else":     ELSE,
-	"goto":     GOTO,
-	"break":    BREAK,
-	"continue": CONTINUE,
-	"case":     CASE,
-	"default":  DEFAULT,
-	"switch":   SWITCH,
-	"struct":   STRUCT,
-	"signed":   SIGNED,
-	"unsigned": UNSIGNED,
-	"typedef":  TYPEDEF,
-	"return":   RETURN,
-	"void":     VOID,
-	"char":     CHAR,
-	"int":      INT,
-	"short":    SHORT,
-	"long":     LONG,
-	"float":    FLOAT,
-	"double":   DOUBLE,
-	"sizeof":   SIZEOF,
-	"static":   STATIC,
+	"for":            FOR,
+	"while":          WHILE,
+	"do":             DO,
+	"if":             IF,
+	"else":           ELSE,
+	"goto":           GOTO,
+	"break":          BREAK,
+	"continue":       CONTINUE,
+	"case":           CASE,
+	"default":        DEFAULT,
+	"switch":         SWITCH,
+	"struct":         STRUCT,
+	"enum":           ENUM,
+	"_Bool":          BOOL,
+	"inline":         INLINE,
+	"__inline":       INLINE,
+	"__inline__":     INLINE,
+	"union":          UNION,
+	"signed":         SIGNED,
+	"unsigned":       UNSIGNED,
+	"typedef":        TYPEDEF,
+	"return":         RETURN,
+	"void":           VOID,
+	"char":           CHAR,
+	"int":            INT,
+	"short":          SHORT,
+	"long":           LONG,
+	"float":          FLOAT,
+	"double":         DOUBLE,
+	"sizeof":         SIZEOF,
+	"_Alignof":       ALIGNOF,
+	"__alignof__":    ALIGNOF,
+	"static":         STATIC,
+	"extern":         EXTERN,
+	"register":       REGISTER,
+	"auto":           AUTO,
+	"const":          CONST,
+	"__const":        CONST,
+	"__const__":      CONST,
+	"volatile":       VOLATILE,
+	"__volatile":     VOLATILE,
+	"__volatile__":   VOLATILE,
+	"restrict":       RESTRICT,
+	"__restrict":     RESTRICT,
+	"__restrict__":   RESTRICT,
+	"_Atomic":        ATOMIC,
+	"_Alignas":       ALIGNAS,
+	"_Noreturn":      NORETURN,
+	"_Static_assert": STATIC_ASSERT,
+	"_Generic":       GENERIC,
+	"__attribute__":  ATTRIBUTE,
+	"__attribute":    ATTRIBUTE,
+	"typeof":         TYPEOF,
+	"__typeof":       TYPEOF,
+	"__typeof__":     TYPEOF,
+	"__auto_type":    AUTO_TYPE,
+	"asm":            ASM,
+	"__asm":          ASM,
+	"__asm__":        ASM,
 }
 
 type TokenKind uint32
 
+// Reports whether tk is a keyword.
+func (tk TokenKind) IsKeyword() bool {
+	return tk >= AUTO && tk <= LONG
+}
+
 func (tk TokenKind) String() string {
 	if uint32(tk) >= uint32(len(tokenKindToStr)) {
 		return "Unknown"
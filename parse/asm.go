@@ -0,0 +1,168 @@
+package parse
+
+import (
+	"strings"
+
+	"github.com/andrewchambers/cc/cpp"
+)
+
+// Parses an asm statement, or an asm block at file scope, which
+// must be basic.
+//
+// asm [volatile] [inline] ( template [: outputs [: inputs [: clobbers]]] ) ;
+func (p *parser) Asm() *Asm {
+	pos := p.curt.Pos
+	p.gnuExtension(pos, "asm")
+	p.next()
+	a := &Asm{
+		Pos: pos,
+	}
+loop:
+	for {
+		switch p.curt.Kind {
+		case cpp.VOLATILE:
+			a.Volatile = true
+			p.next()
+		case cpp.INLINE:
+			p.next()
+		case cpp.GOTO:
+			p.errorPos(p.curt.Pos, "asm goto is not supported")
+		default:
+			break loop
+		}
+	}
+	p.expect('(')
+	a.Template = p.asmString()
+	a.Basic = p.curt.Kind != ':'
+	if !a.Basic && p.curFunc == nil {
+		p.errorPos(pos, "asm at file scope cannot have operands")
+	}
+	if p.curt.Kind == ':' {
+		p.next()
+		a.Outputs = p.asmOperands(true, 0)
+	}
+	if p.curt.Kind == ':' {
+		p.next()
+		a.Inputs = p.asmOperands(false, len(a.Outputs))
+	}
+	if p.curt.Kind == ':' {
+		p.next()
+		for p.curt.Kind == cpp.STRING {
+			a.Clobbers = append(a.Clobbers, p.asmString())
+			if p.curt.Kind != ',' {
+				break
+			}
+			p.next()
+		}
+	}
+	p.expect(')')
+	p.expect(';')
+	for _, in := range a.Inputs {
+		if n, ok := in.Matching(); ok && n >= len(a.Outputs) {
+			p.errorPos(in.Pos, "matching constraint references invalid operand number")
+		}
+	}
+	return a
+}
+
+// Parses adjacent string literals, returning their concatenated chars.
+func (p *parser) asmString() string {
+	if p.curt.Kind != cpp.STRING {
+		p.errorPos(p.curt.Pos, "expected a string literal in asm")
+	}
+	var b []byte
+	for p.curt.Kind == cpp.STRING {
+		s, err := unquoteString(p.curt.Val)
+		if err != nil {
+			p.errorPos(p.curt.Pos, "%s", err)
+		}
+		b = append(b, s...)
+		p.next()
+	}
+	return string(b)
+}
+
+// Parses a possibly empty list of operands, [name] "constraint" (expr),
+// numbered from first.
+func (p *parser) asmOperands(outputs bool, first int) []AsmOperand {
+	var ops []AsmOperand
+	for p.curt.Kind == '[' || p.curt.Kind == cpp.STRING {
+		op := AsmOperand{
+			Pos: p.curt.Pos,
+		}
+		if p.curt.Kind == '[' {
+			p.next()
+			op.Name = p.curt.Val
+			p.expect(cpp.IDENT)
+			p.expect(']')
+		}
+		op.Constraint = p.asmString()
+		p.expect('(')
+		expr := p.Expr()
+		p.expect(')')
+		if outputs {
+			op.Expr = p.asmOutput(op.Pos, op.Constraint, expr)
+		} else {
+			op.Expr = p.asmInput(op.Pos, first+len(ops), op.Constraint, expr)
+		}
+		ops = append(ops, op)
+		if p.curt.Kind != ',' {
+			break
+		}
+		p.next()
+	}
+	return ops
+}
+
+// Outputs are stored to after the asm, so must be modifiable lvalues.
+func (p *parser) asmOutput(pos cpp.FilePos, constraint string, expr Expr) Expr {
+	if !strings.HasPrefix(constraint, "=") && !strings.HasPrefix(constraint, "+") {
+		p.errorPos(pos, "output operand constraint lacks '='")
+	}
+	p.ensureModifiableLvalue(pos, expr)
+	if isBitfield(expr) {
+		p.errorPos(pos, "asm output is a bit-field")
+	}
+	return expr
+}
+
+// Inputs used as values are converted as if they were used as a
+// value, and those which may be immediates are folded when constant.
+// Inputs which can only be in memory are left as objects, so must
+// be lvalues.
+func (p *parser) asmInput(pos cpp.FilePos, n int, constraint string, expr Expr) Expr {
+	if strings.ContainsAny(constraint, "=+") {
+		p.errorPos(pos, "input operand constraint contains '%c'", constraint[strings.IndexAny(constraint, "=+")])
+	}
+	if strings.Trim(constraint, "&%moV") == "" {
+		if !isLvalue(expr) {
+			p.errorPos(pos, "memory input %d is not directly addressable", n)
+		}
+		if isBitfield(expr) {
+			p.errorPos(pos, "asm memory input is a bit-field")
+		}
+		return expr
+	}
+	expr = p.decay(expr)
+	if strings.ContainsAny(constraint, "ingX") {
+		if c, err := p.fold(expr); err == nil {
+			if c, ok := c.(*Constant); ok {
+				return c
+			}
+		}
+	}
+	return expr
+}
+
+// Returns the output an input must share a place with, if its
+// constraint is the number of an output.
+func (op *AsmOperand) Matching() (int, bool) {
+	n := 0
+	for _, c := range op.Constraint {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, op.Constraint != ""
+}
@@ -14,6 +14,11 @@ type Expr interface {
 type TranslationUnit struct {
 	TopLevels      []Node
 	AnonymousInits []Node
+	// Objects with static storage duration, in the order
+	// they were first defined.
+	Objects []*GSymbol
+	// Symbols with linkage, in the order they were first declared.
+	Symbols []*GSymbol
 }
 
 type Constant struct {
@@ -25,13 +30,44 @@ type Constant struct {
 func (c *Constant) GetType() CType      { return c.Type }
 func (c *Constant) GetPos() cpp.FilePos { return c.Pos }
 
+// The brace enclosed initializer of an array, struct or union,
+// flattened to the scalars it sets in order. Anything not set is zero.
 type Initializer struct {
-	Pos   cpp.FilePos
-	Inits []Node
+	Pos     cpp.FilePos
+	Type    CType
+	Members []InitMember
 }
 
+func (i *Initializer) GetType() CType      { return i.Type }
 func (i *Initializer) GetPos() cpp.FilePos { return i.Pos }
 
+// A scalar, or a struct copied whole, within an aggregate.
+type InitMember struct {
+	// Byte offset from the start of the aggregate. For a bit-field
+	// this is the offset of its storage unit.
+	Offset int
+	// Position and width of a bit-field within its storage
+	// unit, and the size of the unit in bytes. BitWidth is -1
+	// if the member is not a bit-field.
+	BitOffset int
+	BitWidth  int
+	UnitSize  int
+	// Already converted to the type of the member.
+	Init Expr
+}
+
+// An unnamed object created by a compound literal. In a function
+// Sym is an LSymbol, at file scope a GSymbol with static storage.
+type CompoundLiteral struct {
+	Pos  cpp.FilePos
+	Sym  Symbol
+	Init Expr
+	Type CType
+}
+
+func (c *CompoundLiteral) GetType() CType      { return c.Type }
+func (c *CompoundLiteral) GetPos() cpp.FilePos { return c.Pos }
+
 type Return struct {
 	Pos cpp.FilePos
 	Ret Expr
@@ -83,10 +119,40 @@ type Goto struct {
 	IsCont  bool
 	Pos     cpp.FilePos
 	Label   string
+	// The address jumped to by a computed goto, goto *Target,
+	// which has no Label.
+	Target Expr
 }
 
 func (g *Goto) GetPos() cpp.FilePos { return g.Pos }
 
+// A GNU asm statement, or an asm block at file scope. The Template
+// of a basic asm statement, which has no operands, is emitted as is.
+// Otherwise %n in it refers to operand n, counting the outputs first,
+// %[name] to the operand with that name, and %% is a single %.
+type Asm struct {
+	Pos      cpp.FilePos
+	Template string
+	Basic    bool
+	Volatile bool
+	Outputs  []AsmOperand
+	Inputs   []AsmOperand
+	Clobbers []string
+}
+
+func (a *Asm) GetPos() cpp.FilePos { return a.Pos }
+
+// An operand of an asm statement. The Constraint says where it may
+// be placed. Those of outputs start with = or + if the asm also
+// reads the output, and those of inputs may be the number of an
+// output which the input must share a place with.
+type AsmOperand struct {
+	Pos        cpp.FilePos
+	Name       string
+	Constraint string
+	Expr       Expr
+}
+
 type LabeledStmt struct {
 	Pos       cpp.FilePos
 	AnonLabel string
@@ -108,14 +174,17 @@ type If struct {
 
 func (i *If) GetPos() cpp.FilePos { return i.Pos }
 
+// A case label matching the values from V to Hi inclusive. Hi is
+// only above V for a GNU case range, case V ... Hi.
 type SwitchCase struct {
 	V     int64
+	Hi    int64
 	Label string
 }
 
 type Switch struct {
 	Pos      cpp.FilePos
-	Expr     Node
+	Expr     Expr
 	Stmt     Node
 	Cases    []SwitchCase
 	LDefault string
@@ -131,7 +200,9 @@ type For struct {
 	Step   Node
 	Body   Node
 	LStart string
-	LEnd   string
+	// Where continue jumps to, before the step expression.
+	LStep string
+	LEnd  string
 }
 
 func (f *For) GetPos() cpp.FilePos { return f.Pos }
@@ -157,6 +228,49 @@ type DoWhile struct {
 
 func (d *DoWhile) GetPos() cpp.FilePos { return d.Pos }
 
+// The ternary operator Cond ? Then : Else.
+type Cond struct {
+	Pos  cpp.FilePos
+	Cond Expr
+	Then Expr
+	Else Expr
+	Type CType
+}
+
+func (c *Cond) GetType() CType      { return c.Type }
+func (c *Cond) GetPos() cpp.FilePos { return c.Pos }
+
+// A GNU statement expression, ({ ... }). Its value is the value of
+// the expression statement ending Body, if Type is not void.
+type StmtExpr struct {
+	Pos  cpp.FilePos
+	Body *Block
+	Type CType
+}
+
+func (s *StmtExpr) GetType() CType      { return s.Type }
+func (s *StmtExpr) GetPos() cpp.FilePos { return s.Pos }
+
+// The address of a label in the current function, &&label,
+// a GNU extension for use with computed goto.
+type LabelAddr struct {
+	Pos   cpp.FilePos
+	Label string
+}
+
+func (l *LabelAddr) GetType() CType      { return &Ptr{CVoid} }
+func (l *LabelAddr) GetPos() cpp.FilePos { return l.Pos }
+
+// Evaluates each expression in order, the value is that of the last.
+type Comma struct {
+	Pos   cpp.FilePos
+	Exprs []Expr
+	Type  CType
+}
+
+func (c *Comma) GetType() CType      { return c.Type }
+func (c *Comma) GetPos() cpp.FilePos { return c.Pos }
+
 type Unop struct {
 	Op      cpp.TokenKind
 	Pos     cpp.FilePos
@@ -167,17 +281,62 @@ type Unop struct {
 func (u *Unop) GetType() CType      { return u.Type }
 func (u *Unop) GetPos() cpp.FilePos { return u.Pos }
 
+// Prefix or postfix ++ and --.
+type IncDec struct {
+	Op      cpp.TokenKind
+	Pos     cpp.FilePos
+	Post    bool
+	Operand Expr
+	Type    CType
+}
+
+func (i *IncDec) GetType() CType      { return i.Type }
+func (i *IncDec) GetPos() cpp.FilePos { return i.Pos }
+
+// Assignment operators such as += and <<=.
+// Op is the underlying binary operator, and the operation
+// is performed in OpType before converting back to the type of L.
+// L is only evaluated once.
+type CompoundAssign struct {
+	Op     cpp.TokenKind
+	Pos    cpp.FilePos
+	L      Expr
+	R      Expr
+	OpType CType
+	Type   CType
+}
+
+func (c *CompoundAssign) GetType() CType      { return c.Type }
+func (c *CompoundAssign) GetPos() cpp.FilePos { return c.Pos }
+
 type Selector struct {
 	Op      cpp.TokenKind
 	Pos     cpp.FilePos
 	Type    CType
 	Operand Expr
-	Sel     string
+	// The name of the selected member, empty for a member
+	// which is an anonymous struct or union.
+	Sel string
+	// The index of the selected member in StructType().
+	Index int
 }
 
 func (s *Selector) GetType() CType      { return s.Type }
 func (s *Selector) GetPos() cpp.FilePos { return s.Pos }
 
+// The struct or union the member is selected from.
+func (s *Selector) StructType() *CStruct {
+	if s.Op == cpp.ARROW {
+		return Unqual(Unqual(s.Operand.GetType()).(*Ptr).PointsTo).(*CStruct)
+	}
+	return Unqual(s.Operand.GetType()).(*CStruct)
+}
+
+// Returns the width of the selected member if it is a bit-field, else -1.
+func (s *Selector) BitWidth() int {
+	return s.StructType().BitWidth(s.Index)
+}
+
 type Binop struct {
 	Op   cpp.TokenKind
 	Pos  cpp.FilePos
@@ -195,6 +354,20 @@ type CFunc struct {
 	FuncType     *CFuncT
 	ParamSymbols []*LSymbol
 	Body         []Node
+	// Objects created by compound literals, and the lengths of
+	// variable length arrays. They are kept for the whole
+	// function rather than their block.
+	Anonymous []*LSymbol
+	// Declared static, so not visible outside the translation unit.
+	Internal bool
+	// An inline definition. It is emitted as a weak symbol, so an
+	// external definition in another translation unit takes priority.
+	Weak bool
+	// Declared _Noreturn, so it should never return to its caller.
+	NoReturn bool
+	// The symbol of the function, with the attributes
+	// given in all its declarations.
+	Sym *GSymbol
 }
 
 func (f *CFunc) GetType() CType      { return f.FuncType }
@@ -215,6 +388,9 @@ type DeclList struct {
 	Storage SClass
 	Symbols []Symbol
 	Inits   []Expr
+	// Computes the lengths of any variable length arrays in the
+	// type of each symbol, evaluated before its initializer. May be nil.
+	Dims []Expr
 }
 
 func (d *DeclList) GetPos() cpp.FilePos { return d.Pos }
@@ -244,3 +420,55 @@ func (i *Ident) GetType() CType {
 }
 
 func (i *Ident) GetPos() cpp.FilePos { return i.Pos }
+
+// sizeof applied to a variably modified type, which is computed
+// at run time from the lengths of its variable length arrays.
+type Sizeof struct {
+	Pos cpp.FilePos
+	Of  CType
+}
+
+func (s *Sizeof) GetType() CType      { return CULong }
+func (s *Sizeof) GetPos() cpp.FilePos { return s.Pos }
+
+// __builtin_alloca(size), which allocates in the frame of the
+// calling function.
+type Alloca struct {
+	Pos  cpp.FilePos
+	Size Expr
+}
+
+func (a *Alloca) GetType() CType      { return &Ptr{CVoid} }
+func (a *Alloca) GetPos() cpp.FilePos { return a.Pos }
+
+// A call to a builtin which the backend lowers to instructions
+// rather than a call, such as __builtin_clz. The arguments are
+// already converted to the types of its parameters.
+type BuiltinCall struct {
+	Pos  cpp.FilePos
+	Name string
+	Args []Expr
+	Type CType
+}
+
+func (b *BuiltinCall) GetType() CType      { return b.Type }
+func (b *BuiltinCall) GetPos() cpp.FilePos { return b.Pos }
+
+// __builtin_va_start(ap, last)
+type VaStart struct {
+	Pos cpp.FilePos
+	Ap  Expr
+}
+
+func (v *VaStart) GetType() CType      { return CVoid }
+func (v *VaStart) GetPos() cpp.FilePos { return v.Pos }
+
+// __builtin_va_arg(ap, type)
+type VaArg struct {
+	Pos  cpp.FilePos
+	Ap   Expr
+	Type CType
+}
+
+func (v *VaArg) GetType() CType      { return v.Type }
+func (v *VaArg) GetPos() cpp.FilePos { return v.Pos }
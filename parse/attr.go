@@ -0,0 +1,542 @@
+package parse
+
+import (
+	"strings"
+
+	"github.com/andrewchambers/cc/cpp"
+)
+
+// The attributes given in a declaration with GNU __attribute__ lists,
+// the alignment given with _Alignas, and whether the type is given
+// by __auto_type. Those in the declaration specifiers apply to every
+// declarator, those after a declarator only to it.
+type attrs struct {
+	// Alignment requested with _Alignas, or 0.
+	alignas  int
+	autoType bool
+	// Alignment requested with aligned, or 0.
+	aligned int
+	packed  bool
+	// Given used or unused, so the symbol is never reported as unused.
+	used        bool
+	weak        bool
+	noreturn    bool
+	section     string
+	alias       string
+	visibility  string
+	constructor bool
+	destructor  bool
+	// Priorities of a constructor or destructor, 0 for the default.
+	ctorPriority int
+	dtorPriority int
+	format       *formatAttr
+	// The function named by cleanup.
+	cleanup *cpp.Token
+	// The size in bytes of the integer type given by mode, or 0.
+	mode int
+}
+
+// The format(archetype, string-index, first-to-check) attribute of a
+// function taking a printf or scanf style format. Indexes count from 1,
+// and first-to-check is 0 if the arguments are passed as a va_list.
+type formatAttr struct {
+	archetype string
+	fmtArg    int
+	firstArg  int
+}
+
+// Attributes which only guide optimizations, or diagnostics this
+// compiler does not make. They are accepted without effect.
+var noEffectAttrs = map[string]bool{
+	"access":                 true,
+	"alloc_align":            true,
+	"alloc_size":             true,
+	"always_inline":          true,
+	"artificial":             true,
+	"cold":                   true,
+	"const":                  true,
+	"deprecated":             true,
+	"error":                  true,
+	"externally_visible":     true,
+	"fallthrough":            true,
+	"flatten":                true,
+	"format_arg":             true,
+	"gnu_inline":             true,
+	"hot":                    true,
+	"leaf":                   true,
+	"malloc":                 true,
+	"may_alias":              true,
+	"no_instrument_function": true,
+	"noinline":               true,
+	"nonnull":                true,
+	"nonstring":              true,
+	"nothrow":                true,
+	"pure":                   true,
+	"returns_nonnull":        true,
+	"returns_twice":          true,
+	"sentinel":               true,
+	"unavailable":            true,
+	"warn_unused_result":     true,
+	"warning":                true,
+}
+
+// Integer modes of the mode attribute with fixed sizes in bytes.
+// The word and pointer modes depend on the target.
+var intModes = map[string]int{
+	"QI":   1,
+	"HI":   2,
+	"SI":   4,
+	"DI":   8,
+	"byte": 1,
+}
+
+// Returns the size in bytes of the integer mode named mode.
+// A word is the size of a long, which is the register size on
+// the targets supported.
+func (p *parser) modeSize(mode string) (int, bool) {
+	switch mode {
+	case "word":
+		return p.szdesc.GetSize(CLong), true
+	case "pointer":
+		return p.szdesc.PtrSize, true
+	}
+	sz, ok := intModes[mode]
+	return sz, ok
+}
+
+// Attribute names may be given as __name__ as well as name,
+// so headers can use them whatever macros are defined.
+func attrName(s string) string {
+	if strings.HasPrefix(s, "__") && strings.HasSuffix(s, "__") && len(s) > 4 {
+		return s[2 : len(s)-2]
+	}
+	return s
+}
+
+// Parses any number of attribute lists,
+// __attribute__((name, name(arguments), ...)), adding them to a.
+func (p *parser) Attributes(a *attrs) {
+	for p.curt.Kind == cpp.ATTRIBUTE {
+		p.next()
+		p.expect('(')
+		p.expect('(')
+		for p.curt.Kind != ')' {
+			// Empty entries in the list are allowed.
+			if p.curt.Kind != ',' {
+				p.attribute(a)
+			}
+			if p.curt.Kind != ',' {
+				break
+			}
+			p.next()
+		}
+		p.expect(')')
+		p.expect(')')
+	}
+}
+
+func (p *parser) attribute(a *attrs) {
+	pos := p.curt.Pos
+	if p.curt.Kind != cpp.IDENT && !p.curt.Kind.IsKeyword() {
+		p.errorPos(pos, "expected an attribute name")
+	}
+	name := attrName(p.curt.Val)
+	p.next()
+	switch name {
+	case "packed":
+		p.noAttrArgs(name)
+		a.packed = true
+	case "aligned":
+		// Without an argument, the largest alignment ever required.
+		align := int64(p.szdesc.MaxAlign)
+		if p.curt.Kind == '(' {
+			p.next()
+			align = p.attrInt(name)
+			p.expect(')')
+		}
+		if align <= 0 || align&(align-1) != 0 {
+			p.errorPos(pos, "requested alignment is not a power of 2")
+		}
+		if int(align) > a.aligned {
+			a.aligned = int(align)
+		}
+	case "used", "unused":
+		p.noAttrArgs(name)
+		a.used = true
+	case "weak":
+		p.noAttrArgs(name)
+		a.weak = true
+	case "noreturn":
+		p.noAttrArgs(name)
+		a.noreturn = true
+	case "section":
+		a.section = p.attrString(name)
+	case "alias":
+		a.alias = p.attrString(name)
+	case "visibility":
+		a.visibility = p.attrString(name)
+		switch a.visibility {
+		case "default", "hidden", "protected", "internal":
+		default:
+			p.errorPos(pos, "visibility argument must be one of default, hidden, protected or internal")
+		}
+	case "constructor", "destructor":
+		priority := 0
+		if p.curt.Kind == '(' {
+			p.next()
+			v := p.attrInt(name)
+			p.expect(')')
+			if v < 0 || v > 65535 {
+				p.errorPos(pos, "%s priorities must be integers from 0 to 65535 inclusive", name)
+			}
+			priority = int(v)
+		}
+		if name == "constructor" {
+			a.constructor = true
+			a.ctorPriority = priority
+		} else {
+			a.destructor = true
+			a.dtorPriority = priority
+		}
+	case "format":
+		p.expect('(')
+		archetype := attrName(p.attrIdent(name).Val)
+		p.expect(',')
+		fmtArg := p.attrInt(name)
+		p.expect(',')
+		firstArg := p.attrInt(name)
+		p.expect(')')
+		a.format = &formatAttr{
+			archetype: archetype,
+			fmtArg:    int(fmtArg),
+			firstArg:  int(firstArg),
+		}
+	case "cleanup":
+		p.expect('(')
+		a.cleanup = p.attrIdent(name)
+		p.expect(')')
+	case "mode":
+		p.expect('(')
+		mode := p.attrIdent(name)
+		p.expect(')')
+		sz, ok := p.modeSize(attrName(mode.Val))
+		if !ok {
+			p.errorPos(mode.Pos, "unknown machine mode %s", mode.Val)
+		}
+		a.mode = sz
+	default:
+		if !noEffectAttrs[name] {
+			p.warnPos(pos, "%s attribute ignored", name)
+		}
+		p.skipAttrArgs()
+	}
+}
+
+func (p *parser) noAttrArgs(name string) {
+	if p.curt.Kind == '(' {
+		p.errorPos(p.curt.Pos, "wrong number of arguments specified for %s attribute", name)
+	}
+}
+
+// Parses the string literal argument of an attribute in parentheses.
+func (p *parser) attrString(name string) string {
+	p.expect('(')
+	t := p.curt
+	if t.Kind != cpp.STRING {
+		p.errorPos(t.Pos, "%s attribute argument is not a string", name)
+	}
+	p.next()
+	p.expect(')')
+	s, err := unquoteString(t.Val)
+	if err != nil {
+		p.errorPos(t.Pos, "%s", err)
+	}
+	return string(s)
+}
+
+func (p *parser) attrInt(name string) int64 {
+	e := p.CondExpr()
+	c, err := p.fold(e)
+	if err != nil {
+		p.errorPos(e.GetPos(), "%s", err)
+	}
+	v, ok := c.(*Constant)
+	if !ok || !IsIntType(v.Type) {
+		p.errorPos(e.GetPos(), "%s attribute argument is not an integer constant", name)
+	}
+	return v.Val
+}
+
+func (p *parser) attrIdent(name string) *cpp.Token {
+	t := p.curt
+	if t.Kind != cpp.IDENT && !t.Kind.IsKeyword() {
+		p.errorPos(t.Pos, "%s attribute argument is not an identifier", name)
+	}
+	p.next()
+	return t
+}
+
+// Skips the arguments of an attribute without effect, whatever they are.
+func (p *parser) skipAttrArgs() {
+	if p.curt.Kind != '(' {
+		return
+	}
+	depth := 0
+	for {
+		switch p.curt.Kind {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case cpp.EOF:
+			p.errorPos(p.curt.Pos, "unexpected end of file in attribute")
+		}
+		p.next()
+		if depth == 0 {
+			return
+		}
+	}
+}
+
+// Returns the names of the attributes given in a which only
+// apply to some kinds of declarations.
+func (a *attrs) given() []string {
+	var names []string
+	add := func(set bool, name string) {
+		if set {
+			names = append(names, name)
+		}
+	}
+	add(a.aligned != 0, "aligned")
+	add(a.packed, "packed")
+	add(a.weak, "weak")
+	add(a.noreturn, "noreturn")
+	add(a.section != "", "section")
+	add(a.alias != "", "alias")
+	add(a.visibility != "", "visibility")
+	add(a.constructor, "constructor")
+	add(a.destructor, "destructor")
+	add(a.format != nil, "format")
+	add(a.cleanup != nil, "cleanup")
+	add(a.mode != 0, "mode")
+	return names
+}
+
+// Warns about any attributes in a which do not apply to
+// a declaration allowing only those named.
+func (p *parser) checkAttrs(pos cpp.FilePos, a *attrs, allowed ...string) {
+	for _, name := range a.given() {
+		ok := false
+		for _, n := range allowed {
+			if n == name {
+				ok = true
+			}
+		}
+		if !ok {
+			p.warnPos(pos, "%s attribute ignored", name)
+		}
+	}
+}
+
+// Gives the integer type ty the size of the mode attribute,
+// keeping its signedness.
+func (p *parser) modeType(pos cpp.FilePos, ty CType, a *attrs) CType {
+	if a.mode == 0 {
+		return ty
+	}
+	prim, ok := Unqual(ty).(Primitive)
+	if !ok || !IsIntType(prim) || prim == CBool {
+		p.errorPos(pos, "invalid type for mode attribute")
+	}
+	var types []Primitive
+	if IsSignedIntType(prim) {
+		types = []Primitive{CChar, CShort, CInt, CLong}
+	} else {
+		types = []Primitive{CUChar, CUShort, CUInt, CULong}
+	}
+	for _, t := range types {
+		if p.szdesc.GetSize(t) == a.mode {
+			return Qualify(t, QualsOf(ty))
+		}
+	}
+	panic("internal error")
+}
+
+// Returns the alignment of a struct member of type ty with attributes
+// a, or 0 if it has its natural alignment. Packed members are byte
+// aligned, and only theirs may be reduced with aligned.
+func (p *parser) memberAlign(ty CType, a *attrs) int {
+	natural := p.szdesc.GetAlign(ty)
+	align := natural
+	if a.packed {
+		align = 1
+	}
+	if a.aligned != 0 && (a.aligned > align || a.packed) {
+		align = a.aligned
+	}
+	if a.alignas > align {
+		align = a.alignas
+	}
+	if align == natural && !a.packed {
+		return 0
+	}
+	return align
+}
+
+// Applies the attributes of a struct or union definition to s.
+func (p *parser) structAttrs(pos cpp.FilePos, s *CStruct, a *attrs) {
+	p.checkAttrs(pos, a, "packed", "aligned")
+	if !a.packed && a.aligned == 0 {
+		return
+	}
+	s.Packed = a.packed
+	s.Align = a.aligned
+}
+
+// Applies the attributes of a declaration of name, which has linkage
+// or is a static local, to its symbol gsym.
+func (p *parser) symbolAttrs(name *cpp.Token, gsym *GSymbol, a *attrs) {
+	fty, isFunc := gsym.Type.(*CFuncT)
+	switch {
+	case isFunc:
+		p.checkAttrs(name.Pos, a, "aligned", "weak", "noreturn", "section", "alias", "visibility", "constructor", "destructor", "format")
+	case p.linked[name.Val] == gsym:
+		p.checkAttrs(name.Pos, a, "aligned", "weak", "section", "alias", "visibility", "mode")
+	default:
+		p.checkAttrs(name.Pos, a, "aligned", "section", "mode")
+	}
+	if a.aligned > gsym.Align {
+		gsym.Align = a.aligned
+	}
+	if a.used {
+		gsym.used = true
+	}
+	if a.weak {
+		if gsym.Internal {
+			p.errorPos(name.Pos, "weak declaration of %s must be public", name.Val)
+		}
+		gsym.Weak = true
+	}
+	if a.section != "" {
+		if gsym.Section != "" && gsym.Section != a.section {
+			p.errorPos(name.Pos, "section of %s conflicts with previous declaration", name.Val)
+		}
+		gsym.Section = a.section
+	}
+	if a.visibility != "" {
+		gsym.Visibility = a.visibility
+	}
+	if a.alias != "" {
+		_, isObject := p.objects[gsym]
+		if p.funcDefs[name.Val] || isObject {
+			p.errorPos(name.Pos, "%s defined both normally and as an alias", name.Val)
+		}
+		if isFunc {
+			p.funcDefs[name.Val] = true
+		}
+		gsym.Alias = a.alias
+		p.aliases = append(p.aliases, name)
+	}
+	if !isFunc {
+		return
+	}
+	if a.noreturn {
+		gsym.NoReturn = true
+	}
+	if a.constructor || a.destructor {
+		// Called from .init_array and .fini_array.
+		gsym.used = true
+	}
+	if a.constructor {
+		gsym.Constructor = true
+		gsym.CtorPriority = a.ctorPriority
+	}
+	if a.destructor {
+		gsym.Destructor = true
+		gsym.DtorPriority = a.dtorPriority
+	}
+	if a.format != nil {
+		p.checkFormatAttr(name.Pos, fty, a.format)
+		gsym.format = a.format
+	}
+}
+
+// The format string must be a parameter of type char *, and unless
+// the arguments are passed as a va_list, followed by '...'.
+func (p *parser) checkFormatAttr(pos cpp.FilePos, fty *CFuncT, f *formatAttr) {
+	if _, ok := formatArchetypes[f.archetype]; !ok {
+		p.warnPos(pos, "%s is an unrecognized format function type", f.archetype)
+	}
+	if f.fmtArg < 1 || f.fmtArg > len(fty.ArgTypes) {
+		p.errorPos(pos, "format string argument out of range")
+	}
+	ptr, ok := Unqual(fty.ArgTypes[f.fmtArg-1]).(*Ptr)
+	if !ok || !IsCharType(Unqual(ptr.PointsTo)) {
+		p.errorPos(pos, "format string argument is not a string type")
+	}
+	switch {
+	case f.firstArg == 0:
+	case f.firstArg <= f.fmtArg:
+		p.errorPos(pos, "format string argument follows the arguments to be formatted")
+	case !fty.IsVarArg || f.firstArg != len(fty.ArgTypes)+1:
+		p.errorPos(pos, "arguments to be formatted are not '...'")
+	}
+}
+
+// Applies the attributes of the declaration of name, a local
+// variable with automatic storage, to its symbol.
+func (p *parser) localAttrs(name *cpp.Token, lsym *LSymbol, a *attrs) {
+	p.checkAttrs(name.Pos, a, "aligned", "cleanup", "mode")
+	if a.aligned > lsym.Align {
+		lsym.Align = a.aligned
+	}
+	if a.cleanup == nil {
+		return
+	}
+	// The cleanup function is called with the address of the
+	// variable when it goes out of scope.
+	sym, err := p.decls.lookup(a.cleanup.Val)
+	gsym, ok := sym.(*GSymbol)
+	if err != nil || !ok || !IsCFuncType(gsym.Type) {
+		p.errorPos(a.cleanup.Pos, "cleanup argument %s is not a function", a.cleanup.Val)
+	}
+	fty := gsym.Type.(*CFuncT)
+	if !fty.Unprototyped {
+		if len(fty.ArgTypes) != 1 {
+			p.errorPos(a.cleanup.Pos, "cleanup function %s must take exactly one argument", a.cleanup.Val)
+		}
+		arg := &Ptr{lsym.Type}
+		param, ok := Unqual(fty.ArgTypes[0]).(*Ptr)
+		if !ok || !(IsVoidType(param.PointsTo) || TypesCompatible(Unqual(param.PointsTo), Unqual(arg.PointsTo))) {
+			p.errorPos(a.cleanup.Pos, "cleanup function %s does not take a pointer to %s", a.cleanup.Val, name.Val)
+		}
+	}
+	gsym.used = true
+	lsym.Cleanup = gsym
+}
+
+// Checks the symbols declared as aliases refer to a definition in
+// this translation unit, and warns about static functions and
+// objects which are defined but never used.
+func (p *parser) checkSymbols() {
+	for _, name := range p.aliases {
+		gsym := p.linked[name.Val]
+		target, ok := p.linked[gsym.Alias]
+		_, isObject := p.objects[target]
+		if !ok || !(p.funcDefs[gsym.Alias] || isObject) {
+			p.errorPos(name.Pos, "%s aliased to undefined symbol %s", name.Val, gsym.Alias)
+		}
+		target.used = true
+	}
+	for _, tl := range p.tu.TopLevels {
+		if f, ok := tl.(*CFunc); ok && f.Internal && !f.Sym.used {
+			p.warnPos(f.Pos, "%s defined but not used", f.Name)
+		}
+	}
+	for _, gsym := range p.tu.Objects {
+		// Static locals are left to the function using them.
+		if gsym.Internal && !gsym.used && p.linked[gsym.Label] == gsym {
+			p.warnPos(p.objects[gsym], "%s defined but not used", gsym.Label)
+		}
+	}
+}
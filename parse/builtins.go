@@ -0,0 +1,317 @@
+package parse
+
+// The SysV x86-64 va_list, an array of one element so that it
+// decays to a pointer when passed to another function:
+//
+//	typedef struct {
+//	    unsigned int gp_offset;
+//	    unsigned int fp_offset;
+//	    void *overflow_arg_area;
+//	    void *reg_save_area;
+//	} __builtin_va_list[1];
+var vaListStruct = &CStruct{
+	Names: []string{"gp_offset", "fp_offset", "overflow_arg_area", "reg_save_area"},
+	Types: []CType{CUInt, CUInt, &Ptr{CVoid}, &Ptr{CVoid}},
+}
+
+var vaListType = &Array{
+	MemberType: vaListStruct,
+	Dim:        1,
+}
+
+// Define the types the compiler provides without any headers.
+func (p *parser) defineBuiltinTypes() {
+	err := p.types.define("__builtin_va_list", &TSymbol{
+		Type: vaListType,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Builtins which are called like functions with these prototypes,
+// and which the backend lowers to instructions rather than calls.
+var builtinFuncs = map[string]*CFuncT{
+	"__builtin_trap":        {RetType: CVoid},
+	"__builtin_unreachable": {RetType: CVoid},
+	"__builtin_memcpy": {
+		RetType:  &Ptr{CVoid},
+		ArgTypes: []CType{&Ptr{CVoid}, &Ptr{Qualify(CVoid, QualConst)}, CULong},
+	},
+	"__builtin_memset": {
+		RetType:  &Ptr{CVoid},
+		ArgTypes: []CType{&Ptr{CVoid}, CInt, CULong},
+	},
+	"__builtin_clz":        {RetType: CInt, ArgTypes: []CType{CUInt}},
+	"__builtin_clzl":       {RetType: CInt, ArgTypes: []CType{CULong}},
+	"__builtin_clzll":      {RetType: CInt, ArgTypes: []CType{CULLong}},
+	"__builtin_ctz":        {RetType: CInt, ArgTypes: []CType{CUInt}},
+	"__builtin_ctzl":       {RetType: CInt, ArgTypes: []CType{CULong}},
+	"__builtin_ctzll":      {RetType: CInt, ArgTypes: []CType{CULLong}},
+	"__builtin_popcount":   {RetType: CInt, ArgTypes: []CType{CUInt}},
+	"__builtin_popcountl":  {RetType: CInt, ArgTypes: []CType{CULong}},
+	"__builtin_popcountll": {RetType: CInt, ArgTypes: []CType{CULLong}},
+	"__builtin_bswap16":    {RetType: CUShort, ArgTypes: []CType{CUShort}},
+	"__builtin_bswap32":    {RetType: CUInt, ArgTypes: []CType{CUInt}},
+	"__builtin_bswap64":    {RetType: CULong, ArgTypes: []CType{CULong}},
+}
+
+// Builtins which need parsing of their own, because they take types,
+// or are checked or typed in ways a prototype cannot express.
+var builtinParsers map[string]func(p *parser) Expr
+
+func init() {
+	builtinParsers = map[string]func(p *parser) Expr{
+		"__builtin_offsetof":   (*parser).Offsetof,
+		"__builtin_va_start":   (*parser).VaStart,
+		"__builtin_va_arg":     (*parser).VaArg,
+		"__builtin_va_end":     (*parser).VaEnd,
+		"__builtin_va_copy":    (*parser).VaCopy,
+		"__builtin_alloca":     (*parser).Alloca,
+		"__builtin_expect":     (*parser).Expect,
+		"__builtin_constant_p": (*parser).ConstantP,
+	}
+	// The generic overflow builtins take the type of the result from
+	// their third argument, the others fix it by their prefix and suffix.
+	for _, op := range []string{"add", "sub", "mul"} {
+		generic := "__builtin_" + op + "_overflow"
+		builtinParsers[generic] = func(p *parser) Expr {
+			return p.Overflow(generic, nil)
+		}
+		fixed := map[string][]CType{
+			"s": {CInt, CLong, CLLong},
+			"u": {CUInt, CULong, CULLong},
+		}
+		for sign, types := range fixed {
+			for i, suffix := range []string{"", "l", "ll"} {
+				ty := types[i]
+				builtinParsers["__builtin_"+sign+op+suffix+"_overflow"] = func(p *parser) Expr {
+					return p.Overflow(generic, ty)
+				}
+			}
+		}
+	}
+}
+
+// Reports whether name is a builtin, for __has_builtin.
+func HasBuiltin(name string) bool {
+	_, parsed := builtinParsers[name]
+	_, called := builtinFuncs[name]
+	return parsed || called
+}
+
+// Parses a call to a compiler builtin if the current token names one,
+// otherwise returns nil.
+func (p *parser) Builtin() Expr {
+	if parse, ok := builtinParsers[p.curt.Val]; ok {
+		return parse(p)
+	}
+	if fty, ok := builtinFuncs[p.curt.Val]; ok {
+		return p.builtinCall(fty)
+	}
+	return nil
+}
+
+// Parses the arguments of a builtin up to the closing paren.
+func (p *parser) builtinArgs() []Expr {
+	var args []Expr
+	p.expect('(')
+	for p.curt.Kind != ')' {
+		args = append(args, p.decay(p.AssignmentExpr()))
+		if p.curt.Kind != ',' {
+			break
+		}
+		p.next()
+	}
+	p.expect(')')
+	return args
+}
+
+func (p *parser) builtinCall(fty *CFuncT) Expr {
+	pos := p.curt.Pos
+	name := p.curt.Val
+	p.next()
+	args := p.builtinArgs()
+	return &BuiltinCall{
+		Pos:  pos,
+		Name: name,
+		Args: p.callArgs(pos, fty, args),
+		Type: fty.RetType,
+	}
+}
+
+// __builtin_expect(exp, c) is exp converted to long, c only tells
+// which value exp is likely to have.
+func (p *parser) Expect() Expr {
+	pos := p.curt.Pos
+	p.next()
+	args := p.builtinArgs()
+	if len(args) != 2 {
+		p.errorPos(pos, "__builtin_expect expects 2 arguments, have %d", len(args))
+	}
+	p.ensureInt(args[0])
+	p.ensureInt(args[1])
+	exp := p.convert(args[0], CLong)
+	if _, err := p.fold(args[1]); err == nil {
+		return exp
+	}
+	return &Comma{
+		Pos:   pos,
+		Exprs: []Expr{p.convert(args[1], CVoid), exp},
+		Type:  CLong,
+	}
+}
+
+// __builtin_constant_p(exp) is 1 if exp is an integer constant, which
+// it may be after folding even if it is not a constant expression.
+// exp is not evaluated.
+func (p *parser) ConstantP() Expr {
+	pos := p.curt.Pos
+	p.next()
+	args := p.builtinArgs()
+	if len(args) != 1 {
+		p.errorPos(pos, "__builtin_constant_p expects 1 argument, have %d", len(args))
+	}
+	v, err := p.fold(args[0])
+	_, isConst := v.(*Constant)
+	return &Constant{
+		Pos:  pos,
+		Val:  boolToInt(err == nil && isConst),
+		Type: CInt,
+	}
+}
+
+// __builtin_add_overflow(a, b, res) and its relatives store a op b
+// to *res and are true if the result did not fit. The operation is on
+// the mathematical values of the operands, so they keep their types,
+// except that a typed variant converts them to ty, as its prototype
+// would. All of them become a BuiltinCall of the generic builtin.
+func (p *parser) Overflow(generic string, ty CType) Expr {
+	pos := p.curt.Pos
+	name := p.curt.Val
+	p.next()
+	args := p.builtinArgs()
+	if len(args) != 3 {
+		p.errorPos(pos, "%s expects 3 arguments, have %d", name, len(args))
+	}
+	p.ensureInt(args[0])
+	p.ensureInt(args[1])
+	res := args[2]
+	if ty != nil {
+		args[0] = p.convert(args[0], ty)
+		args[1] = p.convert(args[1], ty)
+		res = p.assignConv(res.GetPos(), &Ptr{ty}, res, "argument 3")
+	}
+	pty, ok := Unqual(res.GetType()).(*Ptr)
+	if !ok || !IsIntType(pty.PointsTo) || Unqual(pty.PointsTo) == CBool {
+		p.errorPos(res.GetPos(), "argument 3 of %s is not a pointer to a non-boolean integer", name)
+	}
+	if QualsOf(pty.PointsTo)&QualConst != 0 {
+		p.errorPos(res.GetPos(), "argument 3 of %s points to a const integer", name)
+	}
+	return &BuiltinCall{
+		Pos:  pos,
+		Name: generic,
+		Args: []Expr{args[0], args[1], res},
+		Type: CBool,
+	}
+}
+
+// Parse a va_list argument, which is passed by reference.
+func (p *parser) vaListArg(builtin string) Expr {
+	ap := p.decay(p.AssignmentExpr())
+	if !TypesCompatible(ap.GetType(), &Ptr{vaListStruct}) {
+		p.errorPos(ap.GetPos(), "%s expects a va_list", builtin)
+	}
+	return ap
+}
+
+func (p *parser) VaStart() Expr {
+	pos := p.curt.Pos
+	p.next()
+	p.expect('(')
+	ap := p.vaListArg("va_start")
+	p.expect(',')
+	p.AssignmentExpr()
+	p.expect(')')
+	if p.curFunc == nil || !p.curFunc.FuncType.IsVarArg {
+		p.errorPos(pos, "va_start used in function with fixed arguments")
+	}
+	return &VaStart{
+		Pos: pos,
+		Ap:  ap,
+	}
+}
+
+// __builtin_alloca(size) allocates size bytes in the frame of the
+// calling function. They are freed when it returns, or when leaving
+// the scope of a variable length array declared before the call.
+func (p *parser) Alloca() Expr {
+	pos := p.curt.Pos
+	p.next()
+	p.expect('(')
+	size := p.decay(p.AssignmentExpr())
+	p.ensureInt(size)
+	p.expect(')')
+	if p.curFunc == nil {
+		p.errorPos(pos, "__builtin_alloca used outside a function")
+	}
+	return &Alloca{
+		Pos:  pos,
+		Size: p.convert(size, CULong),
+	}
+}
+
+func (p *parser) VaArg() Expr {
+	pos := p.curt.Pos
+	p.next()
+	p.expect('(')
+	ap := p.vaListArg("va_arg")
+	p.expect(',')
+	ty := p.TypeName()
+	p.expect(')')
+	if !IsScalarType(ty) && !IsStructType(ty) {
+		p.errorPos(pos, "invalid type for va_arg")
+	}
+	return &VaArg{
+		Pos:  pos,
+		Ap:   ap,
+		Type: ty,
+	}
+}
+
+func (p *parser) VaEnd() Expr {
+	pos := p.curt.Pos
+	p.next()
+	p.expect('(')
+	ap := p.vaListArg("va_end")
+	p.expect(')')
+	// Nothing needs cleaning up on this target.
+	return &Cast{
+		Pos:     pos,
+		Operand: ap,
+		Type:    CVoid,
+	}
+}
+
+// va_copy(dst, src) is the struct assignment *dst = *src.
+func (p *parser) VaCopy() Expr {
+	pos := p.curt.Pos
+	p.next()
+	p.expect('(')
+	dst := p.vaListArg("va_copy")
+	p.expect(',')
+	src := p.vaListArg("va_copy")
+	p.expect(')')
+	return &Cast{
+		Pos: pos,
+		Operand: &Binop{
+			Pos:  pos,
+			Op:   '=',
+			L:    &Unop{Op: '*', Pos: pos, Operand: dst, Type: vaListStruct},
+			R:    &Unop{Op: '*', Pos: pos, Operand: src, Type: vaListStruct},
+			Type: vaListStruct,
+		},
+		Type: CVoid,
+	}
+}
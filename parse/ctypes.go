@@ -1,8 +1,16 @@
 package parse
 
+// Describes the sizes and alignments of the basic types of a target.
+// The size, alignment and layout of derived types are computed from
+// these (see layout.go) so the frontend and backends always agree.
 type TargetSizeDesc struct {
-	GetSize  func(CType) int
-	GetAlign func(CType) int
+	GetPrimSize  func(Primitive) int
+	GetPrimAlign func(Primitive) int
+	PtrSize      int
+	PtrAlign     int
+	// The largest alignment any type requires, which is what
+	// __attribute__((aligned)) without an argument gives.
+	MaxAlign int
 }
 
 type CType interface{}
@@ -10,6 +18,11 @@ type CType interface{}
 type Array struct {
 	MemberType CType
 	Dim        int
+	// Declared without a size, e.g. int a[].
+	Incomplete bool
+	// For a variable length array, the hidden local holding its
+	// length, which is computed when the declaration is reached.
+	DimSym *LSymbol
 }
 
 type Ptr struct {
@@ -21,6 +34,96 @@ type CStruct struct {
 	Names   []string
 	Types   []CType
 	IsUnion bool
+	// Width in bits of each member, or -1 if the member is not
+	// a bit-field. May be nil if there are no bit-fields.
+	BitWidths []int
+	// Alignment of each member set by _Alignas or attributes,
+	// replacing its natural alignment, or 0. For a bit-field it is
+	// the alignment of the first bit given by the aligned attribute.
+	// May be nil if no member has one.
+	Aligns []int
+	// Whether each bit-field was declared with the packed attribute,
+	// so it may straddle storage units. May be nil if none was.
+	PackedFields []bool
+	// Members are byte aligned unless they have an alignment
+	// in Aligns.
+	Packed bool
+	// Alignment of the whole struct set by attributes, or 0.
+	Align int
+	// Declared but not yet defined. Completed in place
+	// when the definition is seen.
+	Incomplete bool
+	// Cached result of TargetSizeDesc.GetLayout.
+	layout *StructLayout
+}
+
+// Returns the index of the named member, or -1.
+func (s *CStruct) FieldIndex(n string) int {
+	for idx, v := range s.Names {
+		if v == n {
+			return idx
+		}
+	}
+	return -1
+}
+
+// Returns the width of the member at idx if it is a bit-field, else -1.
+func (s *CStruct) BitWidth(idx int) int {
+	if s.BitWidths == nil {
+		return -1
+	}
+	return s.BitWidths[idx]
+}
+
+// Reports whether the last member of s is an array without a size,
+// which takes up no space but may be accessed past the end of s.
+func (s *CStruct) HasFlexibleArray() bool {
+	if s.IsUnion || len(s.Types) == 0 {
+		return false
+	}
+	arr, ok := s.Types[len(s.Types)-1].(*Array)
+	return ok && arr.Incomplete
+}
+
+// Returns the alignment set for the member at idx, or 0.
+func (s *CStruct) MemberAlign(idx int) int {
+	if s.Aligns == nil {
+		return 0
+	}
+	return s.Aligns[idx]
+}
+
+// Reports whether the member at idx is a bit-field declared packed.
+func (s *CStruct) FieldPacked(idx int) bool {
+	if s.PackedFields == nil {
+		return false
+	}
+	return s.PackedFields[idx]
+}
+
+// Reports whether the member at idx is an anonymous struct or union,
+// whose members are accessed as if they were members of s.
+func (s *CStruct) IsAnonymous(idx int) bool {
+	return s.Names[idx] == "" && s.BitWidth(idx) < 0
+}
+
+// Returns the indices of the members leading to the member named n,
+// which may be a member of an anonymous struct or union, or nil if
+// there is no such member.
+func (s *CStruct) FieldPath(n string) []int {
+	for idx, v := range s.Names {
+		if v == n {
+			return []int{idx}
+		}
+		if !s.IsAnonymous(idx) {
+			continue
+		}
+		anon := Unqual(s.Types[idx]).(*CStruct)
+		if path := anon.FieldPath(n); path != nil {
+			return append([]int{idx}, path...)
+		}
+	}
+	return nil
 }
 
 func (s *CStruct) FieldType(n string) CType {
@@ -37,6 +140,107 @@ type CFuncT struct {
 	ArgTypes []CType
 	ArgNames []string
 	IsVarArg bool
+	// Declared without a prototype, as in int f() or an old style
+	// definition with an identifier list. Calls are not checked
+	// against ArgTypes and their arguments are promoted instead.
+	Unprototyped bool
+	// The parameters declared by a prototype, and the lengths of
+	// variable length arrays in their types, which may refer to
+	// them. A definition uses these rather than new symbols.
+	params []*LSymbol
+	dims   []vlaDim
+}
+
+// Type qualifiers, which may be combined.
+type Qualifiers int
+
+const (
+	QualConst Qualifiers = 1 << iota
+	QualVolatile
+	QualRestrict
+	QualAtomic
+)
+
+// A qualified version of an unqualified type.
+// Qualified types are never nested, and an array is never qualified,
+// its member type carries the qualifiers instead (C11 6.7.3p9).
+//
+// A typedef given an alignment with the aligned attribute is also
+// represented as a Qualified type, which may have no qualifiers.
+type Qualified struct {
+	Type  CType
+	Quals Qualifiers
+	// Alignment replacing that of Type, or 0.
+	Align int
+}
+
+// Add the qualifiers q to t.
+func Qualify(t CType, q Qualifiers) CType {
+	if q == 0 {
+		return t
+	}
+	switch t := t.(type) {
+	case *Qualified:
+		return &Qualified{Type: t.Type, Quals: t.Quals | q, Align: t.Align}
+	case *Array:
+		ret := *t
+		ret.MemberType = Qualify(t.MemberType, q)
+		return &ret
+	}
+	return &Qualified{Type: t, Quals: q}
+}
+
+// Give t the alignment align, as the aligned attribute does for a
+// typedef. An array type has the alignment of its member type.
+func AlignType(t CType, align int) CType {
+	switch t := t.(type) {
+	case *Qualified:
+		return &Qualified{Type: t.Type, Quals: t.Quals, Align: align}
+	case *Array:
+		ret := *t
+		ret.MemberType = AlignType(t.MemberType, align)
+		return &ret
+	}
+	return &Qualified{Type: t, Align: align}
+}
+
+// Remove any top level qualifiers from t.
+func Unqual(t CType) CType {
+	if q, ok := t.(*Qualified); ok {
+		return q.Type
+	}
+	return t
+}
+
+// Returns the qualifiers of t. An array has the
+// qualifiers of its member type.
+func QualsOf(t CType) Qualifiers {
+	switch t := t.(type) {
+	case *Qualified:
+		return t.Quals
+	case *Array:
+		return QualsOf(t.MemberType)
+	}
+	return 0
+}
+
+// Reports whether an object of type t, or any member of it, is const,
+// in which case it cannot be assigned to as a whole.
+func HasConstMember(t CType) bool {
+	if QualsOf(t)&QualConst != 0 {
+		return true
+	}
+	switch t := Unqual(t).(type) {
+	case *CStruct:
+		for _, mty := range t.Types {
+			if HasConstMember(mty) {
+				return true
+			}
+		}
+	case *Array:
+		return HasConstMember(t.MemberType)
+	}
+	return false
 }
 
 type ForwardedType struct {
@@ -70,13 +274,83 @@ const (
 	CLDouble
 )
 
+// Reports whether two types are compatible as described in C11 6.2.7.
+func TypesCompatible(a, b CType) bool {
+	if a == b {
+		return true
+	}
+	if QualsOf(a) != QualsOf(b) && !IsArrType(a) {
+		return false
+	}
+	a = Unqual(a)
+	b = Unqual(b)
+	if a == b {
+		return true
+	}
+	switch a := a.(type) {
+	case *Ptr:
+		b, ok := b.(*Ptr)
+		return ok && TypesCompatible(a.PointsTo, b.PointsTo)
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || !TypesCompatible(a.MemberType, b.MemberType) {
+			return false
+		}
+		if a.DimSym != nil || b.DimSym != nil {
+			return true
+		}
+		return a.Incomplete || b.Incomplete || a.Dim == b.Dim
+	case *CFuncT:
+		b, ok := b.(*CFuncT)
+		if !ok || !TypesCompatible(a.RetType, b.RetType) {
+			return false
+		}
+		// Without a prototype nothing is known about the parameters.
+		if a.Unprototyped || b.Unprototyped {
+			return !a.IsVarArg && !b.IsVarArg
+		}
+		if a.IsVarArg != b.IsVarArg || len(a.ArgTypes) != len(b.ArgTypes) {
+			return false
+		}
+		for idx := range a.ArgTypes {
+			// Qualifiers on parameters do not affect the function type.
+			if !TypesCompatible(Unqual(a.ArgTypes[idx]), Unqual(b.ArgTypes[idx])) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Incomplete types have an unknown size, so objects of these
+// types cannot be created or accessed.
+func IsIncomplete(t CType) bool {
+	switch t := Unqual(t).(type) {
+	case *CStruct:
+		return t.Incomplete
+	case *Array:
+		return t.Incomplete || IsIncomplete(t.MemberType)
+	}
+	return IsVoidType(t)
+}
+
+func IsVoidType(t CType) bool {
+	return Unqual(t) == CVoid
+}
+
+func IsFloatType(t CType) bool {
+	prim, ok := Unqual(t).(Primitive)
+	return ok && prim >= CFloat
+}
+
 func IsPtrType(t CType) bool {
-	_, ok := t.(*Ptr)
+	_, ok := Unqual(t).(*Ptr)
 	return ok
 }
 
 func IsIntType(t CType) bool {
-	prim, ok := t.(Primitive)
+	prim, ok := Unqual(t).(Primitive)
 	if !ok {
 		return false
 	}
@@ -84,40 +358,95 @@ func IsIntType(t CType) bool {
 }
 
 func IsSignedIntType(t CType) bool {
-	prim, ok := t.(Primitive)
+	prim, ok := Unqual(t).(Primitive)
 	if !ok {
 		return false
 	}
 	return prim >= CEnum && prim <= CLLong
 }
 
+// Integer conversion rank as described in C11 6.3.1.1.
+func IntRank(t CType) int {
+	switch Unqual(t) {
+	case CBool:
+		return 0
+	case CChar, CUChar:
+		return 1
+	case CShort, CUShort:
+		return 2
+	case CInt, CUInt, CEnum:
+		return 3
+	case CLong, CULong:
+		return 4
+	case CLLong, CULLong:
+		return 5
+	}
+	panic(t)
+}
+
+// Returns the unsigned type corresponding to a signed integer type.
+func UnsignedOf(t CType) CType {
+	switch Unqual(t) {
+	case CChar:
+		return CUChar
+	case CShort:
+		return CUShort
+	case CInt, CEnum:
+		return CUInt
+	case CLong:
+		return CULong
+	case CLLong:
+		return CULLong
+	}
+	return t
+}
+
 func IsScalarType(t CType) bool {
 	return IsPtrType(t) || IsIntType(t)
 }
 
+// Reports whether t is an array with a size only known at run time,
+// because it or one of its member types is a variable length array.
+func IsVariableSize(t CType) bool {
+	arr, ok := t.(*Array)
+	return ok && (arr.DimSym != nil || IsVariableSize(arr.MemberType))
+}
+
+// Variably modified types are arrays of variable
+// size, or pointers to them.
+func IsVariablyModified(t CType) bool {
+	if ptr, ok := Unqual(t).(*Ptr); ok {
+		return IsVariablyModified(ptr.PointsTo)
+	}
+	return IsVariableSize(t)
+}
+
 func IsArrType(t CType) bool {
-	_, ok := t.(*Array)
+	_, ok := Unqual(t).(*Array)
 	return ok
 }
 
 func IsCFuncType(t CType) bool {
-	_, ok := t.(*CFuncT)
+	_, ok := Unqual(t).(*CFuncT)
 	return ok
 }
 
 func IsStructType(t CType) bool {
-	_, ok := t.(*CStruct)
+	_, ok := Unqual(t).(*CStruct)
 	return ok
 }
 
 func IsCharType(t CType) bool {
-	prim, ok := t.(Primitive)
+	prim, ok := Unqual(t).(Primitive)
 	if !ok {
 		return false
 	}
 	return prim == CChar
 }
 
+// Arrays of char or unsigned char, which may be
+// initialized by a string literal.
 func IsCharArr(t CType) bool {
-	return IsArrType(t) && IsCharType(t)
+	arr, ok := t.(*Array)
+	return ok && (IsCharType(arr.MemberType) || Unqual(arr.MemberType) == CUChar)
 }
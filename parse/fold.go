@@ -3,6 +3,8 @@ package parse
 import (
 	"fmt"
 	"github.com/andrewchambers/cc/cpp"
+	"math/big"
+	"math/bits"
 )
 
 type ConstantGPtr struct {
@@ -21,33 +23,478 @@ func (c *ConstantGPtr) GetType() CType {
 }
 
 // To fold a node means to compute the simplified form which can replace it without
-// changing the meaning of the program.
+// changing the meaning of the program. Integer constant expressions fold to a
+// Constant, and address constants to a ConstantGPtr.
 func (p *parser) fold(n Expr) (Expr, error) {
 	switch n := n.(type) {
 	case *Constant:
 		return n, nil
 	case *String:
+		return &ConstantGPtr{Pos: n.Pos, PtrLabel: n.Label, Type: n.GetType()}, nil
+	case *ConstantGPtr:
 		return n, nil
+	case *LabelAddr:
+		return &ConstantGPtr{Pos: n.Pos, PtrLabel: n.Label, Type: n.GetType()}, nil
+	case *Cast:
+		return p.foldCast(n)
 	case *Unop:
+		return p.foldUnop(n)
+	case *Binop:
+		return p.foldBinop(n)
+	case *Cond:
+		c, err := p.foldTruth(n.Cond)
+		if err != nil {
+			return nil, err
+		}
+		if c {
+			return p.fold(n.Then)
+		}
+		return p.fold(n.Else)
+	case *Comma:
+		var v Expr
+		for _, e := range n.Exprs {
+			var err error
+			v, err = p.fold(e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case *BuiltinCall:
+		return p.foldBuiltin(n)
+	}
+	return nil, fmt.Errorf("not a valid constant value")
+}
+
+// Folds the builtins which count or reorder the bits of a constant.
+// __builtin_clz and __builtin_ctz of zero are undefined so do not fold.
+func (p *parser) foldBuiltin(n *BuiltinCall) (Expr, error) {
+	if len(n.Args) != 1 {
+		return nil, fmt.Errorf("not a valid constant value")
+	}
+	arg, err := p.fold(n.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	c, ok := arg.(*Constant)
+	if !ok {
+		return nil, fmt.Errorf("not a valid constant value")
+	}
+	size := p.szdesc.GetSize(n.Args[0].GetType())
+	v := uint64(c.Val)
+	var r int64
+	switch n.Name {
+	case "__builtin_clz", "__builtin_clzl", "__builtin_clzll":
+		if v == 0 {
+			return nil, fmt.Errorf("not a valid constant value")
+		}
+		r = int64(bits.LeadingZeros64(v) - (64 - size*8))
+	case "__builtin_ctz", "__builtin_ctzl", "__builtin_ctzll":
+		if v == 0 {
+			return nil, fmt.Errorf("not a valid constant value")
+		}
+		r = int64(bits.TrailingZeros64(v))
+	case "__builtin_popcount", "__builtin_popcountl", "__builtin_popcountll":
+		r = int64(bits.OnesCount64(v))
+	case "__builtin_bswap16":
+		r = int64(bits.ReverseBytes16(uint16(v)))
+	case "__builtin_bswap32":
+		r = int64(bits.ReverseBytes32(uint32(v)))
+	case "__builtin_bswap64":
+		r = int64(bits.ReverseBytes64(v))
+	default:
+		return nil, fmt.Errorf("not a valid constant value")
+	}
+	return &Constant{Pos: n.Pos, Val: r, Type: n.Type}, nil
+}
+
+// Truncates v to the width of the integer type ty, sign or zero
+// extending the result back to 64 bits.
+func (p *parser) wrapInt(v int64, ty CType) int64 {
+	shift := uint(64 - p.szdesc.GetSize(ty)*8)
+	if IsSignedIntType(ty) {
+		return v << shift >> shift
+	}
+	return int64(uint64(v) << shift >> shift)
+}
+
+// Folds n to a truth value. The address of an object is never null.
+func (p *parser) foldTruth(n Expr) (bool, error) {
+	v, err := p.fold(n)
+	if err != nil {
+		return false, err
+	}
+	switch v := v.(type) {
+	case *Constant:
+		return v.Val != 0, nil
+	case *ConstantGPtr:
+		return true, nil
+	}
+	return false, fmt.Errorf("not a valid constant value")
+}
+
+func (p *parser) foldCast(c *Cast) (Expr, error) {
+	var v Expr
+	var err error
+	switch c.Operand.GetType().(type) {
+	case *Array, *CFuncT:
+		// Arrays and functions decay to their address.
+		v, err = p.foldAddr(c.Operand)
+	default:
+		v, err = p.fold(c.Operand)
+	}
+	if err != nil {
+		return nil, err
+	}
+	to := Unqual(c.Type)
+	switch v := v.(type) {
+	case *Constant:
+		switch {
+		case to == CBool:
+			return &Constant{Pos: c.Pos, Val: boolToInt(v.Val != 0), Type: c.Type}, nil
+		case IsIntType(to):
+			return &Constant{Pos: c.Pos, Val: p.wrapInt(v.Val, to), Type: c.Type}, nil
+		case IsPtrType(to):
+			return &Constant{Pos: c.Pos, Val: v.Val, Type: c.Type}, nil
+		}
+	case *ConstantGPtr:
+		if to == CBool {
+			return &Constant{Pos: c.Pos, Val: 1, Type: c.Type}, nil
+		}
+		// The linker can only relocate addresses of full width.
+		if IsPtrType(to) || (IsIntType(to) && p.szdesc.GetSize(to) == p.szdesc.GetSize(v.Type)) {
+			return &ConstantGPtr{Pos: c.Pos, PtrLabel: v.PtrLabel, Offset: v.Offset, Type: c.Type}, nil
+		}
+		return nil, fmt.Errorf("initializer element is not computable at load time")
+	}
+	return nil, fmt.Errorf("not a valid constant value")
+}
+
+// Folds the address of the lvalue n, which must designate
+// an object or function with static storage.
+func (p *parser) foldAddr(n Expr) (Expr, error) {
+	ptr := &Ptr{n.GetType()}
+	switch n := n.(type) {
+	case *Ident:
+		gsym, ok := n.Sym.(*GSymbol)
+		if !ok {
+			return nil, fmt.Errorf("'&' requires a static or global identifier")
+		}
+		return &ConstantGPtr{Pos: n.Pos, PtrLabel: gsym.Label, Type: ptr}, nil
+	case *CompoundLiteral:
+		gsym, ok := n.Sym.(*GSymbol)
+		if !ok {
+			return nil, fmt.Errorf("compound literal in a constant expression must be at file scope")
+		}
+		return &ConstantGPtr{Pos: n.Pos, PtrLabel: gsym.Label, Type: ptr}, nil
+	case *Index:
+		arr := n.Arr.(Expr)
+		var base Expr
+		var err error
+		if IsArrType(arr.GetType()) {
+			base, err = p.foldAddr(arr)
+		} else {
+			base, err = p.fold(arr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		idx, err := p.fold(n.Idx.(Expr))
+		if err != nil {
+			return nil, err
+		}
+		i, ok := idx.(*Constant)
+		if !ok {
+			return nil, fmt.Errorf("array index is not an integer constant")
+		}
+		return p.offsetAddr(base, i.Val*int64(p.szdesc.GetSize(n.Type)), ptr)
+	case *Selector:
+		var base Expr
+		var err error
+		if n.Op == '.' {
+			base, err = p.foldAddr(n.Operand)
+		} else {
+			base, err = p.fold(n.Operand)
+		}
+		if err != nil {
+			return nil, err
+		}
+		off := p.szdesc.GetLayout(n.StructType()).Offsets[n.Index]
+		return p.offsetAddr(base, int64(off), ptr)
+	case *Unop:
+		if n.Op == '*' {
+			base, err := p.fold(n.Operand.(Expr))
+			if err != nil {
+				return nil, err
+			}
+			return p.offsetAddr(base, 0, ptr)
+		}
+	}
+	return nil, fmt.Errorf("'&' requires a valid identifier")
+}
+
+// Offsets the folded address base by off bytes. Integer addresses
+// allow the offsetof idiom, &((struct s *)0)->member.
+func (p *parser) offsetAddr(base Expr, off int64, ty CType) (Expr, error) {
+	switch base := base.(type) {
+	case *ConstantGPtr:
+		return &ConstantGPtr{Pos: base.Pos, PtrLabel: base.PtrLabel, Offset: base.Offset + off, Type: ty}, nil
+	case *Constant:
+		return &Constant{Pos: base.Pos, Val: base.Val + off, Type: ty}, nil
+	}
+	return nil, fmt.Errorf("not a valid constant address")
+}
+
+func (p *parser) foldUnop(n *Unop) (Expr, error) {
+	switch n.Op {
+	case '&':
+		v, err := p.foldAddr(n.Operand.(Expr))
+		if err != nil {
+			return nil, err
+		}
+		return p.offsetAddr(v, 0, n.Type)
+	case '!':
+		c, err := p.foldTruth(n.Operand.(Expr))
+		if err != nil {
+			return nil, err
+		}
+		v := int64(1)
+		if c {
+			v = 0
+		}
+		return &Constant{Pos: n.Pos, Val: v, Type: n.Type}, nil
+	case '+', '-', '~':
+		v, err := p.fold(n.Operand.(Expr))
+		if err != nil {
+			return nil, err
+		}
+		c, ok := v.(*Constant)
+		if !ok {
+			return nil, fmt.Errorf("not a valid constant value")
+		}
+		r := c.Val
 		switch n.Op {
-		case '&':
-			ident, ok := n.Operand.(*Ident)
-			if !ok {
-				// XXX &foo[CONST] is valid.
-				return nil, fmt.Errorf("'&' requires a valid identifier")
+		case '-':
+			r = p.foldIntOp(n.Pos, '-', n.Type, 0, c.Val)
+		case '~':
+			r = p.wrapInt(^c.Val, n.Type)
+		}
+		return &Constant{Pos: n.Pos, Val: r, Type: n.Type}, nil
+	}
+	return nil, fmt.Errorf("not a valid constant value")
+}
+
+func (p *parser) foldBinop(n *Binop) (Expr, error) {
+	switch n.Op {
+	case cpp.LAND, cpp.LOR:
+		// The right operand is not evaluated if the left decides
+		// the result, so it may contain, for example, a division by zero.
+		l, err := p.foldTruth(n.L)
+		if err != nil {
+			return nil, err
+		}
+		v := l
+		if l == (n.Op == cpp.LAND) {
+			v, err = p.foldTruth(n.R)
+			if err != nil {
+				return nil, err
 			}
-			gsym, ok := ident.Sym.(*GSymbol)
-			if !ok {
-				return nil, fmt.Errorf("'&' requires a static or global identifier")
+		}
+		return &Constant{Pos: n.Pos, Val: boolToInt(v), Type: n.Type}, nil
+	}
+	l, err := p.fold(n.L)
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.fold(n.R)
+	if err != nil {
+		return nil, err
+	}
+	lc, lisconst := l.(*Constant)
+	rc, risconst := r.(*Constant)
+	if IsPtrType(n.L.GetType()) || IsPtrType(n.R.GetType()) {
+		return p.foldPtrBinop(n, l, r)
+	}
+	if !lisconst || !risconst {
+		return nil, fmt.Errorf("not a valid constant value")
+	}
+	switch n.Op {
+	case cpp.EQL, cpp.NEQ, '<', '>', cpp.LEQ, cpp.GEQ:
+		v := compareInts(n.Op, lc.Val, rc.Val, IsSignedIntType(n.L.GetType()))
+		return &Constant{Pos: n.Pos, Val: boolToInt(v), Type: n.Type}, nil
+	case '/', '%':
+		if rc.Val == 0 {
+			return nil, fmt.Errorf("division by zero in constant expression")
+		}
+	}
+	v := p.foldIntOp(n.Pos, n.Op, n.Type, lc.Val, rc.Val)
+	return &Constant{Pos: n.Pos, Val: v, Type: n.Type}, nil
+}
+
+// Folds arithmetic and comparisons involving pointers. Addresses
+// can only be compared or subtracted within the same object.
+func (p *parser) foldPtrBinop(n *Binop, l, r Expr) (Expr, error) {
+	lptr, lisptr := Unqual(n.L.GetType()).(*Ptr)
+	rptr, risptr := Unqual(n.R.GetType()).(*Ptr)
+	addrOf := func(v Expr) (string, int64) {
+		switch v := v.(type) {
+		case *ConstantGPtr:
+			return v.PtrLabel, v.Offset
+		case *Constant:
+			return "", v.Val
+		}
+		return "", 0
+	}
+	elemSize := func(ptr *Ptr) int64 {
+		if IsVoidType(ptr.PointsTo) || IsCFuncType(ptr.PointsTo) {
+			return 1
+		}
+		return int64(p.szdesc.GetSize(ptr.PointsTo))
+	}
+	switch n.Op {
+	case '+', '-':
+		if lisptr && risptr {
+			llabel, loff := addrOf(l)
+			rlabel, roff := addrOf(r)
+			if llabel != rlabel {
+				break
 			}
-			return &ConstantGPtr{
-				Pos:      n.GetPos(),
-				Offset:   0,
-				PtrLabel: gsym.Label,
-				Type:     n.Type,
-			}, nil
+			return &Constant{Pos: n.Pos, Val: (loff - roff) / elemSize(lptr), Type: n.Type}, nil
 		}
-	default:
+		base, idx, ptr := l, r, lptr
+		if risptr {
+			base, idx, ptr = r, l, rptr
+		}
+		i, ok := idx.(*Constant)
+		if !ok {
+			break
+		}
+		off := i.Val * elemSize(ptr)
+		if n.Op == '-' {
+			off = -off
+		}
+		return p.offsetAddr(base, off, n.Type)
+	case cpp.EQL, cpp.NEQ, '<', '>', cpp.LEQ, cpp.GEQ:
+		llabel, loff := addrOf(l)
+		rlabel, roff := addrOf(r)
+		if llabel != rlabel {
+			// Only equality against a null pointer is known.
+			_, lnull := l.(*Constant)
+			_, rnull := r.(*Constant)
+			if (n.Op != cpp.EQL && n.Op != cpp.NEQ) || !(lnull && loff == 0 || rnull && roff == 0) {
+				break
+			}
+			return &Constant{Pos: n.Pos, Val: boolToInt(n.Op == cpp.NEQ), Type: n.Type}, nil
+		}
+		v := compareInts(n.Op, loff, roff, false)
+		return &Constant{Pos: n.Pos, Val: boolToInt(v), Type: n.Type}, nil
 	}
 	return nil, fmt.Errorf("not a valid constant value")
 }
+
+// Computes a binary integer operation in type ty, wrapping the result
+// to the width of ty. Signed overflow is undefined, so it is reported.
+func (p *parser) foldIntOp(pos cpp.FilePos, op cpp.TokenKind, ty CType, a, b int64) int64 {
+	signed := IsSignedIntType(ty)
+	bits := int64(p.szdesc.GetSize(ty) * 8)
+	var r int64
+	switch op {
+	case '+':
+		r = a + b
+	case '-':
+		r = a - b
+	case '*':
+		r = a * b
+	case '/':
+		if signed {
+			r = a / b
+		} else {
+			r = int64(uint64(a) / uint64(b))
+		}
+	case '%':
+		if signed {
+			r = a % b
+		} else {
+			r = int64(uint64(a) % uint64(b))
+		}
+	case '&':
+		r = a & b
+	case '|':
+		r = a | b
+	case '^':
+		r = a ^ b
+	case cpp.SHL, cpp.SHR:
+		if b < 0 || b >= bits {
+			p.warnPos(pos, "shift count out of range in constant expression")
+			b &= bits - 1
+		}
+		switch {
+		case op == cpp.SHL:
+			r = a << uint(b)
+		case signed:
+			r = a >> uint(b)
+		default:
+			r = int64(uint64(a) >> uint(b))
+		}
+	default:
+		panic("internal error")
+	}
+	wrapped := p.wrapInt(r, ty)
+	if signed && signedOverflows(op, a, b, bits) {
+		p.warnPos(pos, "integer overflow in constant expression")
+	}
+	return wrapped
+}
+
+// Reports whether a op b overflows a signed integer of the given width.
+func signedOverflows(op cpp.TokenKind, a, b, bits int64) bool {
+	x, y := big.NewInt(a), big.NewInt(b)
+	r := new(big.Int)
+	switch op {
+	case '+':
+		r.Add(x, y)
+	case '-':
+		r.Sub(x, y)
+	case '*':
+		r.Mul(x, y)
+	case '/':
+		r.Quo(x, y)
+	default:
+		return false
+	}
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	min := new(big.Int).Neg(max)
+	return r.Cmp(min) < 0 || r.Cmp(max) >= 0
+}
+
+func compareInts(op cpp.TokenKind, a, b int64, signed bool) bool {
+	var lt, eq bool
+	if signed {
+		lt = a < b
+	} else {
+		lt = uint64(a) < uint64(b)
+	}
+	eq = a == b
+	switch op {
+	case cpp.EQL:
+		return eq
+	case cpp.NEQ:
+		return !eq
+	case '<':
+		return lt
+	case '>':
+		return !lt && !eq
+	case cpp.LEQ:
+		return lt || eq
+	case cpp.GEQ:
+		return !lt
+	}
+	panic("internal error")
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
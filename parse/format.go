@@ -0,0 +1,252 @@
+package parse
+
+import (
+	"strings"
+
+	"github.com/andrewchambers/cc/cpp"
+)
+
+// Format archetypes of the format attribute, and whether calls
+// to functions with them are checked.
+var formatArchetypes = map[string]bool{
+	"printf":       true,
+	"gnu_printf":   true,
+	"scanf":        false,
+	"gnu_scanf":    false,
+	"strftime":     false,
+	"gnu_strftime": false,
+	"strfmon":      false,
+}
+
+// Checks the arguments of a call to a function with the format
+// attribute f against its format string, if it is a string literal
+// and the arguments are not passed as a va_list.
+func (p *parser) checkFormat(call *Call, f *formatAttr) {
+	if !formatArchetypes[f.archetype] || f.firstArg == 0 || len(call.Args) < f.fmtArg {
+		return
+	}
+	arg := call.Args[f.fmtArg-1]
+	for {
+		c, ok := arg.(*Cast)
+		if !ok {
+			break
+		}
+		arg = c.Operand
+	}
+	s, ok := arg.(*String)
+	if !ok {
+		return
+	}
+	format, err := unquoteString(s.Val)
+	if err != nil {
+		return
+	}
+	var args []Expr
+	if f.firstArg <= len(call.Args) {
+		args = call.Args[f.firstArg-1:]
+	}
+	argn := f.firstArg
+	// Checks the next argument is of type want, returning false
+	// if there are no arguments left.
+	check := func(spec string, want CType, ok func(CType) bool) bool {
+		if len(args) == 0 {
+			p.warnPos(s.Pos, "format '%s' expects a matching '%s' argument", spec, typeName(want))
+			return false
+		}
+		if ty := args[0].GetType(); !ok(ty) {
+			p.warnPos(args[0].GetPos(), "format '%s' expects argument of type '%s', but argument %d has type '%s'", spec, typeName(want), argn, typeName(ty))
+		}
+		args = args[1:]
+		argn++
+		return true
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		if i < len(format) && format[i] == '%' {
+			continue
+		}
+		for i < len(format) && strings.IndexByte("-+ #0'", format[i]) >= 0 {
+			i++
+		}
+		// The width and precision may be given by int arguments.
+		for _, prefix := range []string{"", "."} {
+			if !strings.HasPrefix(string(format[i:]), prefix) {
+				continue
+			}
+			i += len(prefix)
+			if i < len(format) && format[i] == '*' {
+				i++
+				if !check(string(format[start:i]), CInt, isIntOf(CInt)) {
+					return
+				}
+				continue
+			}
+			for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+				i++
+			}
+		}
+		length := ""
+		for _, l := range []string{"hh", "h", "ll", "l", "L", "q", "j", "z", "t"} {
+			if strings.HasPrefix(string(format[i:]), l) {
+				length = l
+				i += len(l)
+				break
+			}
+		}
+		if i == len(format) {
+			p.warnPos(s.Pos, "spurious trailing '%%' in format")
+			return
+		}
+		spec := string(format[start : i+1])
+		var want CType
+		var ok func(CType) bool
+		switch c := format[i]; c {
+		case 'd', 'i', 'o', 'u', 'x', 'X':
+			want = fmtIntType(length)
+			ok = isIntOf(want)
+		case 'c':
+			want = CInt
+			ok = isIntOf(CInt)
+		case 's':
+			want = &Ptr{CChar}
+			ok = func(ty CType) bool {
+				ptr, isPtr := Unqual(ty).(*Ptr)
+				return isPtr && IsCharType(Unqual(ptr.PointsTo))
+			}
+			if length == "l" {
+				want = &Ptr{CInt}
+				ok = isPtrTo(isIntOf(CInt))
+			}
+		case 'p':
+			want = &Ptr{CVoid}
+			ok = IsPtrType
+		case 'n':
+			want = &Ptr{fmtIntType(length)}
+			ok = isPtrTo(isIntOf(fmtIntType(length)))
+		case 'f', 'F', 'e', 'E', 'g', 'G', 'a', 'A':
+			want = CDouble
+			if length == "L" {
+				want = CLDouble
+			}
+			ok = func(ty CType) bool {
+				return Unqual(ty) == want
+			}
+		case 'm':
+			// glibc prints strerror(errno) without an argument.
+			continue
+		default:
+			p.warnPos(s.Pos, "unknown conversion type character %q in format", c)
+			return
+		}
+		if !check(spec, want, ok) {
+			return
+		}
+	}
+	if len(args) != 0 {
+		p.warnPos(args[0].GetPos(), "too many arguments for format")
+	}
+}
+
+// Returns the promoted integer type expected by a conversion
+// with the length modifier l.
+func fmtIntType(l string) CType {
+	switch l {
+	case "l", "j", "t":
+		return CLong
+	case "ll", "q", "L":
+		return CLLong
+	case "z":
+		return CULong
+	}
+	return CInt
+}
+
+// Returns a function reporting whether a type is want, or the
+// integer type of the other signedness, which gives the same result.
+func isIntOf(want CType) func(CType) bool {
+	return func(ty CType) bool {
+		prim, ok := Unqual(ty).(Primitive)
+		return ok && IsIntType(prim) && toSigned(prim) == toSigned(want.(Primitive))
+	}
+}
+
+func isPtrTo(ok func(CType) bool) func(CType) bool {
+	return func(ty CType) bool {
+		ptr, isPtr := Unqual(ty).(*Ptr)
+		return isPtr && ok(ptr.PointsTo)
+	}
+}
+
+func toSigned(t Primitive) Primitive {
+	switch t {
+	case CUChar:
+		return CChar
+	case CUShort:
+		return CShort
+	case CUInt:
+		return CInt
+	case CULong:
+		return CLong
+	case CULLong:
+		return CLLong
+	}
+	return t
+}
+
+var primNames = map[Primitive]string{
+	CVoid:    "void",
+	CEnum:    "enum",
+	CChar:    "char",
+	CShort:   "short",
+	CInt:     "int",
+	CLong:    "long",
+	CLLong:   "long long",
+	CBool:    "_Bool",
+	CUChar:   "unsigned char",
+	CUShort:  "unsigned short",
+	CUInt:    "unsigned int",
+	CULong:   "unsigned long",
+	CULLong:  "unsigned long long",
+	CFloat:   "float",
+	CDouble:  "double",
+	CLDouble: "long double",
+}
+
+// Describes ty for diagnostics, as it is written in C.
+func typeName(ty CType) string {
+	switch t := ty.(type) {
+	case Primitive:
+		return primNames[t]
+	case *Qualified:
+		var quals []string
+		for _, k := range []cpp.TokenKind{cpp.CONST, cpp.VOLATILE, cpp.RESTRICT, cpp.ATOMIC} {
+			if t.Quals&qualifier(k) != 0 {
+				quals = append(quals, k.String())
+			}
+		}
+		if len(quals) == 0 {
+			return typeName(t.Type)
+		}
+		return strings.Join(quals, " ") + " " + typeName(t.Type)
+	case *Ptr:
+		name := typeName(t.PointsTo)
+		if !strings.HasSuffix(name, "*") {
+			name += " "
+		}
+		return name + "*"
+	case *Array:
+		return typeName(t.MemberType) + "[]"
+	case *CStruct:
+		if t.IsUnion {
+			return "union"
+		}
+		return "struct"
+	case *CFuncT:
+		return "function"
+	}
+	return "unknown type"
+}
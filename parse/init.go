@@ -0,0 +1,358 @@
+package parse
+
+import "github.com/andrewchambers/cc/cpp"
+
+// State while parsing the initializer of a single object.
+type initState struct {
+	init *Initializer
+	// Objects with static storage need constant initializers.
+	constant bool
+	// An expression already parsed for a struct member, which turned
+	// out to be the first scalar of the struct with its braces elided.
+	pending Expr
+	// The rest of the path to a member of an anonymous struct or
+	// union named by the last designator, which continues the
+	// designation into each anonymous member in turn.
+	path []int
+}
+
+// Parses the initializer of an object of type ty. Scalars, and structs
+// initialized from an expression, give an expression converted to ty.
+// Anything else gives an Initializer, whose type completes ty if it is
+// an array without a size.
+func (p *parser) Initializer(ty CType, constant bool) Expr {
+	pos := p.curt.Pos
+	switch {
+	case IsScalarType(ty):
+		braced := p.curt.Kind == '{'
+		if braced {
+			p.next()
+		}
+		init := p.exprInit(ty, constant, nil)
+		if braced {
+			if p.curt.Kind == ',' {
+				p.next()
+			}
+			p.expect('}')
+		}
+		return init
+	case IsStructType(ty) && p.curt.Kind != '{':
+		return p.exprInit(ty, constant, nil)
+	}
+	st := &initState{
+		init: &Initializer{
+			Pos:  pos,
+			Type: ty,
+		},
+		constant: constant,
+	}
+	n := 0
+	switch {
+	case p.isStringInit(st, ty):
+		n = p.stringInit(st, ty, 0)
+	case p.curt.Kind == '{':
+		p.next()
+		n = p.initAggregate(st, ty, 0, true, false)
+		p.expect('}')
+	default:
+		p.errorPos(pos, "invalid initializer")
+	}
+	if arr, ok := ty.(*Array); ok && arr.Incomplete {
+		st.init.Type = &Array{
+			MemberType: arr.MemberType,
+			Dim:        n,
+		}
+	}
+	return st.init
+}
+
+// Parses an initializer expression for an object of type ty, unless
+// init has already been parsed, folding it if it must be constant.
+func (p *parser) exprInit(ty CType, constant bool, init Expr) Expr {
+	if init == nil {
+		init = p.AssignmentExpr()
+	}
+	conv := p.assignConv(init.GetPos(), ty, p.decay(init), "initialization")
+	if !constant {
+		return conv
+	}
+	c, err := p.fold(conv)
+	if err != nil {
+		p.errorPos(init.GetPos(), "%s", err)
+	}
+	return c
+}
+
+// Initializes the members of the aggregate ty at offset off in order,
+// returning one more than the index of the last member initialized.
+//
+// If braced is false the braces around ty were elided, so it stops
+// once ty is full or at a designator, which belongs to an enclosing
+// list. If designated is true a designation continues into ty, with
+// the rest of st.path or else with the current token.
+func (p *parser) initAggregate(st *initState, ty CType, off int, braced, designated bool) int {
+	ty = Unqual(ty)
+	idx := nextMember(ty, -1)
+	end := 0
+	for st.pending != nil || p.curt.Kind != '}' {
+		if st.pending == nil && (designated || isDesignator(p.curt.Kind)) {
+			if !braced && !designated {
+				break
+			}
+			if designated && len(st.path) > 0 {
+				idx = st.path[0]
+				st.path = st.path[1:]
+			} else {
+				idx = p.designator(st, ty)
+			}
+			designated = false
+			if len(st.path) > 0 || isDesignator(p.curt.Kind) {
+				mty, moff, _, _, _ := p.memberAt(ty, idx)
+				if IsScalarType(mty) {
+					p.errorPos(p.curt.Pos, "designator for a member that is not an aggregate")
+				}
+				p.initAggregate(st, mty, off+moff, false, true)
+			} else {
+				p.expect('=')
+				p.initMember(st, ty, idx, off)
+			}
+		} else {
+			if isFull(ty, idx) {
+				if !braced {
+					break
+				}
+				p.errorPos(p.curt.Pos, "excess elements in initializer")
+			}
+			p.initMember(st, ty, idx, off)
+		}
+		idx = nextMember(ty, idx)
+		if idx > end {
+			end = idx
+		}
+		if p.curt.Kind != ',' {
+			break
+		}
+		// Leave the comma for the enclosing list.
+		if !braced && (isFull(ty, idx) || p.nextt.Kind == '}' || isDesignator(p.nextt.Kind)) {
+			break
+		}
+		p.next()
+	}
+	return end
+}
+
+// Initializes the member at idx of the aggregate ty at offset off.
+func (p *parser) initMember(st *initState, ty CType, idx, off int) {
+	mty, moff, bitoff, width, unit := p.memberAt(ty, idx)
+	off += moff
+	if arr, ok := mty.(*Array); ok && arr.Incomplete {
+		p.checkFlexibleInit(st, ty)
+	}
+	switch {
+	case p.isStringInit(st, mty):
+		p.stringInit(st, mty, off)
+	case st.pending == nil && p.curt.Kind == '{':
+		p.next()
+		if IsScalarType(mty) {
+			p.addInit(st, off, bitoff, width, unit, p.exprInit(mty, st.constant, nil))
+			if p.curt.Kind == ',' {
+				p.next()
+			}
+		} else {
+			p.initAggregate(st, mty, off, true, false)
+		}
+		p.expect('}')
+	case IsScalarType(mty):
+		init := p.exprInit(mty, st.constant, st.pending)
+		st.pending = nil
+		p.addInit(st, off, bitoff, width, unit, init)
+	case IsStructType(mty):
+		// A struct may be initialized by an expression of the same
+		// type, otherwise the expression starts its elided list.
+		init := st.pending
+		if init == nil {
+			init = p.AssignmentExpr()
+		}
+		st.pending = nil
+		if TypesCompatible(Unqual(init.GetType()), Unqual(mty)) {
+			p.addInit(st, off, 0, -1, 0, p.exprInit(mty, st.constant, init))
+			break
+		}
+		st.pending = init
+		p.initAggregate(st, mty, off, false, false)
+	default:
+		p.initAggregate(st, mty, off, false, false)
+	}
+}
+
+// Flexible array members may only be initialized in objects with
+// static storage, and only at the top level, as a GNU extension.
+func (p *parser) checkFlexibleInit(st *initState, ty CType) {
+	pos := p.curt.Pos
+	switch {
+	case !p.opts.GNU:
+		p.errorPos(pos, "initialization of a flexible array member")
+	case !st.constant:
+		p.errorPos(pos, "non-static initialization of a flexible array member")
+	case Unqual(st.init.Type) != ty:
+		p.errorPos(pos, "initialization of a flexible array member in a nested context")
+	}
+}
+
+func (p *parser) addInit(st *initState, off, bitoff, width, unit int, init Expr) {
+	st.init.Members = append(st.init.Members, InitMember{
+		Offset:    off,
+		BitOffset: bitoff,
+		BitWidth:  width,
+		UnitSize:  unit,
+		Init:      init,
+	})
+}
+
+// Reports whether a char array of type ty is initialized by a string
+// literal, which may be enclosed in braces.
+func (p *parser) isStringInit(st *initState, ty CType) bool {
+	if !IsCharArr(ty) {
+		return false
+	}
+	if st.pending != nil {
+		_, ok := st.pending.(*String)
+		return ok
+	}
+	return p.curt.Kind == cpp.STRING || (p.curt.Kind == '{' && p.nextt.Kind == cpp.STRING)
+}
+
+// Initializes the char array ty at offset off from a string literal,
+// returning the number of chars initialized. The terminating null is
+// dropped if the array has exactly enough room for the other chars.
+func (p *parser) stringInit(st *initState, ty CType, off int) int {
+	var lit string
+	pos := p.curt.Pos
+	if s, ok := st.pending.(*String); ok {
+		lit = s.Val
+		pos = s.Pos
+		st.pending = nil
+	} else {
+		braced := p.curt.Kind == '{'
+		if braced {
+			p.next()
+		}
+		pos = p.curt.Pos
+		lit = p.curt.Val
+		p.expect(cpp.STRING)
+		if braced {
+			if p.curt.Kind == ',' {
+				p.next()
+			}
+			p.expect('}')
+		}
+	}
+	b, err := unquoteString(lit)
+	if err != nil {
+		p.errorPos(pos, "%s", err)
+	}
+	b = append(b, 0)
+	arr := ty.(*Array)
+	if !arr.Incomplete {
+		if len(b)-1 > arr.Dim {
+			p.errorPos(pos, "initializer-string for array of chars is too long")
+		}
+		if len(b) > arr.Dim {
+			b = b[:arr.Dim]
+		}
+	}
+	cty := Unqual(arr.MemberType)
+	for i, c := range b {
+		p.addInit(st, off+i, 0, -1, 0, &Constant{
+			Pos:  pos,
+			Val:  p.wrapInt(int64(c), cty),
+			Type: cty,
+		})
+	}
+	return len(b)
+}
+
+func isDesignator(k cpp.TokenKind) bool {
+	return k == '.' || k == '['
+}
+
+// Parses a single designator for a member of ty, returning its index.
+// If it names a member of an anonymous struct or union, it returns the
+// index of the anonymous member and leaves the rest of the path in st.
+func (p *parser) designator(st *initState, ty CType) int {
+	pos := p.curt.Pos
+	if p.curt.Kind == '[' {
+		arr, ok := ty.(*Array)
+		if !ok {
+			p.errorPos(pos, "array index in non-array initializer")
+		}
+		p.next()
+		iexpr := p.CondExpr()
+		c, err := p.fold(iexpr)
+		if err != nil {
+			p.errorPos(iexpr.GetPos(), "%s", err)
+		}
+		i, ok := c.(*Constant)
+		if !ok || !IsIntType(i.Type) {
+			p.errorPos(iexpr.GetPos(), "array index in initializer is not an integer constant")
+		}
+		p.expect(']')
+		if i.Val < 0 || (!arr.Incomplete && i.Val >= int64(arr.Dim)) {
+			p.errorPos(iexpr.GetPos(), "array index in initializer exceeds array bounds")
+		}
+		return int(i.Val)
+	}
+	p.expect('.')
+	s, ok := ty.(*CStruct)
+	if !ok {
+		p.errorPos(pos, "field name not in struct or union initializer")
+	}
+	name := p.curt
+	p.expect(cpp.IDENT)
+	path := s.FieldPath(name.Val)
+	if path == nil {
+		p.errorPos(name.Pos, "unknown field %s specified in initializer", name.Val)
+	}
+	st.path = path[1:]
+	return path[0]
+}
+
+// Returns the type, byte offset and any bit-field position, width
+// and storage unit size of the member at idx of the aggregate ty.
+func (p *parser) memberAt(ty CType, idx int) (CType, int, int, int, int) {
+	switch ty := ty.(type) {
+	case *Array:
+		return ty.MemberType, idx * p.szdesc.GetSize(ty.MemberType), 0, -1, 0
+	case *CStruct:
+		l := p.szdesc.GetLayout(ty)
+		return ty.Types[idx], l.Offsets[idx], l.BitOffsets[idx], ty.BitWidth(idx), l.UnitSizes[idx]
+	}
+	panic("internal error")
+}
+
+// Returns the index of the member initialized after the one at idx,
+// or of the first member if idx is -1. Unnamed bit-fields are never
+// initialized, and only one member of a union is.
+func nextMember(ty CType, idx int) int {
+	s, ok := ty.(*CStruct)
+	if !ok {
+		return idx + 1
+	}
+	if s.IsUnion && idx >= 0 {
+		return len(s.Names)
+	}
+	for idx++; idx < len(s.Names) && s.Names[idx] == "" && !s.IsAnonymous(idx); idx++ {
+	}
+	return idx
+}
+
+// Reports whether idx is past the last member of ty.
+func isFull(ty CType, idx int) bool {
+	switch ty := ty.(type) {
+	case *Array:
+		return !ty.Incomplete && idx >= ty.Dim
+	case *CStruct:
+		return idx >= len(ty.Names)
+	}
+	panic("internal error")
+}
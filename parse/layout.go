@@ -0,0 +1,171 @@
+package parse
+
+// The memory layout of a struct or union as computed for a target.
+type StructLayout struct {
+	// Byte offset of each member, indexed like CStruct.Names.
+	// For a bit-field this is the offset of its storage unit.
+	Offsets []int
+	// Offset in bits of each bit-field within its storage unit.
+	BitOffsets []int
+	// Size in bytes of the storage unit of each bit-field. It is the
+	// size of the declared type, except that the unit of a packed
+	// bit-field is only the bytes it covers.
+	UnitSizes []int
+	Size      int
+	Align     int
+}
+
+func alignUp(v, align int) int {
+	if align <= 1 {
+		return v
+	}
+	return (v + align - 1) / align * align
+}
+
+func (d TargetSizeDesc) GetSize(t CType) int {
+	switch t := t.(type) {
+	case *CStruct:
+		return d.GetLayout(t).Size
+	case *Array:
+		return t.Dim * d.GetSize(t.MemberType)
+	case *Ptr:
+		return d.PtrSize
+	case Primitive:
+		return d.GetPrimSize(t)
+	case *Qualified:
+		return d.GetSize(t.Type)
+	}
+	panic(t)
+}
+
+func (d TargetSizeDesc) GetAlign(t CType) int {
+	switch t := t.(type) {
+	case *CStruct:
+		return d.GetLayout(t).Align
+	case *Array:
+		return d.GetAlign(t.MemberType)
+	case *Ptr:
+		return d.PtrAlign
+	case Primitive:
+		return d.GetPrimAlign(t)
+	case *Qualified:
+		if t.Align != 0 {
+			return t.Align
+		}
+		return d.GetAlign(t.Type)
+	}
+	panic(t)
+}
+
+// Compute the layout of a struct or union following the SysV rules:
+// each member is placed at the next offset that satisfies its alignment,
+// the aggregate is aligned to its most strictly aligned member, and
+// the size is padded to a multiple of that alignment so arrays of the
+// aggregate keep every element aligned.
+// Union members all live at offset 0.
+//
+// Bit-fields are packed into the next free bits, moving to the next
+// storage unit of their declared type only if they would otherwise
+// straddle one. Packed bit-fields may straddle units, and always
+// start at the next free bit. A zero width bit-field pads to the
+// next unit, and unnamed bit-fields do not affect the alignment of
+// the aggregate.
+//
+// Members of a packed struct are byte aligned, and any member or the
+// whole aggregate may be given a different alignment with attributes.
+func (d TargetSizeDesc) GetLayout(s *CStruct) *StructLayout {
+	if s.layout != nil {
+		return s.layout
+	}
+	l := &StructLayout{
+		Offsets:    make([]int, len(s.Types)),
+		BitOffsets: make([]int, len(s.Types)),
+		UnitSizes:  make([]int, len(s.Types)),
+		Align:      1,
+	}
+	// Work in bits so bit-fields can share bytes.
+	bitoff := 0
+	for idx, ty := range s.Types {
+		sz := d.GetSize(ty)
+		natural := d.GetAlign(ty)
+		align := natural
+		width := s.BitWidth(idx)
+		packed := width > 0 && (s.Packed || s.FieldPacked(idx))
+		if s.Packed || packed {
+			align = 1
+		}
+		// The aligned attribute of a bit-field aligns its first bit,
+		// and only ever raises the alignment of the struct.
+		fieldAlign := 0
+		if a := s.MemberAlign(idx); a != 0 {
+			if width < 0 || a > align {
+				align = a
+			}
+			if width >= 0 {
+				fieldAlign = a
+			}
+		}
+		if width >= 0 {
+			l.UnitSizes[idx] = sz
+		}
+		if width < 0 || s.Names[idx] != "" {
+			if align > l.Align {
+				l.Align = align
+			}
+		}
+		if s.IsUnion {
+			end := sz * 8
+			if width >= 0 {
+				end = width
+			}
+			if packed {
+				l.UnitSizes[idx] = (width + 7) / 8
+			}
+			if end > bitoff {
+				bitoff = end
+			}
+			continue
+		}
+		if fieldAlign != 0 {
+			bitoff = alignUp(bitoff, fieldAlign*8)
+		}
+		switch {
+		case width < 0:
+			offset := alignUp((bitoff+7)/8, align)
+			l.Offsets[idx] = offset
+			bitoff = (offset + sz) * 8
+		case width == 0:
+			bitoff = alignUp(bitoff, natural*8)
+		case packed:
+			l.Offsets[idx] = bitoff / 8
+			l.BitOffsets[idx] = bitoff % 8
+			l.UnitSizes[idx] = (bitoff%8 + width + 7) / 8
+			bitoff += width
+		default:
+			unit := sz * 8
+			if bitoff/unit != (bitoff+width-1)/unit {
+				bitoff = alignUp(bitoff, unit)
+			}
+			l.Offsets[idx] = bitoff / unit * sz
+			l.BitOffsets[idx] = bitoff % unit
+			bitoff += width
+		}
+	}
+	if s.Align > l.Align {
+		l.Align = s.Align
+	}
+	l.Size = alignUp((bitoff+7)/8, l.Align)
+	s.layout = l
+	return l
+}
+
+// Returns the byte offset of the named member of s.
+// The member must exist.
+func (d TargetSizeDesc) GetOffset(s *CStruct, member string) int {
+	for idx, n := range s.Names {
+		if n == member {
+			return d.GetLayout(s).Offsets[idx]
+		}
+	}
+	panic("internal error")
+}
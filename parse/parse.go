@@ -6,6 +6,7 @@ import (
 	"os"
 	"runtime/debug"
 	"strconv"
+	"strings"
 )
 
 // Storage class
@@ -16,20 +17,41 @@ const (
 	SC_REGISTER
 	SC_STATIC
 	SC_TYPEDEF
-	SC_GLOBAL
+	SC_EXTERN
+)
+
+// Function specifiers, which may only appear in the
+// declaration of a function.
+type FuncSpec int
+
+const (
+	FS_INLINE FuncSpec = 1 << iota
+	FS_NORETURN
 )
 
 type parseErrorBreakOut struct {
 	err error
 }
 
-type gotoFixup struct {
-	actualLabel string
-	g           *Goto
+// The length of a variable length array, which is
+// computed into sym by evaluating the assignment set.
+type vlaDim struct {
+	sym *LSymbol
+	set Expr
+}
+
+// Options controlling which language dialect the parser accepts.
+type Options struct {
+	// Accept GNU extensions, such as arithmetic on void pointers.
+	GNU bool
+	// Called with each warning. Like errors, warnings
+	// carry a source location. May be nil.
+	Warn func(error)
 }
 
 type parser struct {
 	szdesc TargetSizeDesc
+	opts   Options
 
 	types   *scope
 	structs *scope
@@ -49,27 +71,84 @@ type parser struct {
 	switchCounter int
 	switchs       [2048]*Switch
 
-	// Map of goto labels to anonymous labels.
+	// Map of goto labels to anonymous labels, assigned on first
+	// use so &&label can refer to a label defined later.
 	labels map[string]string
-	// All gotos found in the current function.
-	// Needed so we can fix up forward references.
-	gotos []gotoFixup
+	// Labels defined in the current function.
+	labelDefs map[string]bool
+	// All uses of labels in the current function, checked
+	// once every label in it is known.
+	labelRefs []*cpp.Token
+	// The innermost declaration in scope of an identifier with a
+	// variably modified type, the one in scope at each label and
+	// each switch being parsed, and the gotos to be checked against
+	// them once every label is known, as none may jump into the
+	// scope of such an identifier (C11 6.8.6.1p1, 6.8.4.2p2).
+	vm        *vmDecl
+	vmSaved   []*vmDecl
+	labelVMs  map[string]*vmDecl
+	switchVMs [2048]*vmDecl
+	gotos     []vmJump
+	// The function currently being parsed, nil at file scope.
+	curFunc *CFunc
+	// Names of the functions defined so far.
+	funcDefs map[string]bool
+	// Symbols with linkage, shared by every declaration of a name.
+	linked map[string]*GSymbol
+	// Objects defined so far, mapped to their first definition.
+	objects map[*GSymbol]cpp.FilePos
+	// Lengths of the variable length arrays in the declarators
+	// parsed so far, until they are collected with takeDims.
+	dims []vlaDim
+	// Number of prototypes being parsed.
+	protoDepth int
+	// The last struct or union defined without a tag, which
+	// may be an anonymous member of an enclosing one.
+	untagged *CStruct
+	// Names declared as aliases, checked at the end of the file.
+	aliases []*cpp.Token
 }
 
 func (p *parser) pushScope() {
 	p.decls = newScope(p.decls)
 	p.structs = newScope(p.structs)
 	p.types = newScope(p.types)
+	p.vmSaved = append(p.vmSaved, p.vm)
 }
 
 func (p *parser) popScope() {
 	p.decls = p.decls.parent
 	p.structs = p.structs.parent
 	p.types = p.types.parent
+	p.vm = p.vmSaved[len(p.vmSaved)-1]
+	p.vmSaved = p.vmSaved[:len(p.vmSaved)-1]
+}
+
+// A declaration of an identifier with a variably modified type,
+// linked to the one in scope before it.
+type vmDecl struct {
+	outer *vmDecl
+}
+
+// Reports whether the declaration inner, or nil, is in scope
+// wherever outer is.
+func vmInScope(inner, outer *vmDecl) bool {
+	for d := outer; d != nil; d = d.outer {
+		if d == inner {
+			return true
+		}
+	}
+	return inner == nil
+}
+
+type vmJump struct {
+	label *cpp.Token
+	vm    *vmDecl
 }
 
 func (p *parser) pushSwitch(s *Switch) {
 	p.switchs[p.switchCounter] = s
+	p.switchVMs[p.switchCounter] = p.vm
 	p.switchCounter += 1
 }
 
@@ -141,14 +220,20 @@ func (p *parser) addAnonymousString(s *String) {
 	p.tu.AnonymousInits = append(p.tu.AnonymousInits, s)
 }
 
-func Parse(szdesc TargetSizeDesc, pp *cpp.Preprocessor) (tu *TranslationUnit, errRet error) {
+func Parse(szdesc TargetSizeDesc, opts Options, pp *cpp.Preprocessor) (tu *TranslationUnit, errRet error) {
 	p := &parser{}
 	p.szdesc = szdesc
+	p.opts = opts
 	p.pp = pp
+	pp.SetHasBuiltin(HasBuiltin)
 	p.types = newScope(nil)
 	p.decls = newScope(nil)
 	p.structs = newScope(nil)
 	p.tu = &TranslationUnit{}
+	p.funcDefs = make(map[string]bool)
+	p.linked = make(map[string]*GSymbol)
+	p.objects = make(map[*GSymbol]cpp.FilePos)
+	p.defineBuiltinTypes()
 
 	defer func() {
 		if e := recover(); e != nil {
@@ -171,6 +256,22 @@ func (p *parser) errorPos(pos cpp.FilePos, m string, vals ...interface{}) {
 	panic(parseErrorBreakOut{err})
 }
 
+func (p *parser) warnPos(pos cpp.FilePos, m string, vals ...interface{}) {
+	if p.opts.Warn == nil {
+		return
+	}
+	err := fmt.Errorf("warning: "+m, vals...)
+	p.opts.Warn(cpp.ErrWithLoc(err, pos))
+}
+
+// Reports use of a GNU extension, which is an error unless
+// GNU C is accepted.
+func (p *parser) gnuExtension(pos cpp.FilePos, what string) {
+	if !p.opts.GNU {
+		p.errorPos(pos, "%s is a GNU extension", what)
+	}
+}
+
 func (p *parser) error(m string, vals ...interface{}) {
 	err := fmt.Errorf(m, vals...)
 	if os.Getenv("CCDEBUG") == "true" {
@@ -186,11 +287,23 @@ func (p *parser) expect(k cpp.TokenKind) {
 	p.next()
 }
 
+// Keywords which are identifiers in ISO C unless spelled with
+// underscores, as __typeof__ and __asm__.
+var gnuKeywords = map[string]bool{
+	"typeof": true,
+	"asm":    true,
+}
+
 func (p *parser) next() {
 	p.curt = p.nextt
 	t, err := p.pp.Next()
 	if err != nil {
-		p.error(err.Error())
+		p.error("%s", err)
+	}
+	if !p.opts.GNU && t.Kind != cpp.IDENT && gnuKeywords[t.Val] {
+		ident := *t
+		ident.Kind = cpp.IDENT
+		t = &ident
 	}
 	p.nextt = t
 }
@@ -201,21 +314,280 @@ func (p *parser) ensureScalar(n Expr) {
 	}
 }
 
+func (p *parser) ensureInt(n Expr) {
+	if !IsIntType(n.GetType()) {
+		p.errorPos(n.GetPos(), "expected integer type")
+	}
+}
+
+// Wrap n in a cast if it is not already of type ty.
+func (p *parser) convert(n Expr, ty CType) Expr {
+	if n.GetType() == ty {
+		return n
+	}
+	return &Cast{
+		Pos:     n.GetPos(),
+		Operand: n,
+		Type:    ty,
+	}
+}
+
+// Arrays and functions used as values are converted to
+// pointers to their first element or to the function.
+// Other values lose the qualifiers of the lvalue they were read from.
+func (p *parser) decay(n Expr) Expr {
+	switch ty := n.GetType().(type) {
+	case *Array:
+		return p.convert(n, &Ptr{ty.MemberType})
+	case *CFuncT:
+		return p.convert(n, &Ptr{ty})
+	case *Qualified:
+		return p.convert(n, ty.Type)
+	}
+	return n
+}
+
+func isNullPtrConstant(n Expr) bool {
+	if c, ok := n.(*Cast); ok {
+		ptr, ok := Unqual(c.Type).(*Ptr)
+		if !ok || !IsVoidType(ptr.PointsTo) {
+			return false
+		}
+		n = c.Operand
+	}
+	c, ok := n.(*Constant)
+	return ok && IsIntType(c.Type) && c.Val == 0
+}
+
+// Pointer arithmetic requires the size of the pointed to type.
+func (p *parser) ensurePtrArith(pos cpp.FilePos, ptr *Ptr) {
+	if IsVoidType(ptr.PointsTo) || IsCFuncType(ptr.PointsTo) {
+		if !p.opts.GNU {
+			p.errorPos(pos, "arithmetic on a pointer to void or function type")
+		}
+		return
+	}
+	if IsIncomplete(ptr.PointsTo) {
+		p.errorPos(pos, "arithmetic on a pointer to an incomplete type")
+	}
+}
+
+// Returns the width of n if it is a bit-field member, else -1.
+func bitWidth(n Expr) int {
+	// Look through the conversion removing qualifiers.
+	if c, ok := n.(*Cast); ok && Unqual(c.Operand.GetType()) == c.Type {
+		n = c.Operand
+	}
+	sel, ok := n.(*Selector)
+	if !ok {
+		return -1
+	}
+	return sel.BitWidth()
+}
+
+func isBitfield(n Expr) bool {
+	return bitWidth(n) >= 0
+}
+
+// Integer promotions as described in C11 6.3.1.1.
+func (p *parser) intPromote(n Expr) Expr {
+	ty := n.GetType()
+	if !IsIntType(ty) {
+		return n
+	}
+	// Bit-fields narrower than int promote to int, whatever their type.
+	if w := bitWidth(n); w >= 0 && w < p.szdesc.GetSize(CInt)*8 && IntRank(ty) <= IntRank(CInt) {
+		return p.convert(n, CInt)
+	}
+	if IntRank(ty) >= IntRank(CInt) {
+		return n
+	}
+	if p.szdesc.GetSize(ty) == p.szdesc.GetSize(CInt) && !IsSignedIntType(ty) {
+		return p.convert(n, CUInt)
+	}
+	return p.convert(n, CInt)
+}
+
+// Default argument promotions, applied to arguments
+// matching the ellipsis of a variadic function.
+func (p *parser) argPromote(n Expr) Expr {
+	if Unqual(n.GetType()) == CFloat {
+		return p.convert(n, CDouble)
+	}
+	return p.intPromote(n)
+}
+
+// Usual arithmetic conversions as described in C11 6.3.1.8.
+// Returns the converted operands and their common type.
+func (p *parser) arithConv(l, r Expr) (Expr, Expr, CType) {
+	l = p.intPromote(l)
+	r = p.intPromote(r)
+	lty := l.GetType()
+	rty := r.GetType()
+	if lty == rty {
+		return l, r, lty
+	}
+	var ty CType
+	lsigned := IsSignedIntType(lty)
+	rsigned := IsSignedIntType(rty)
+	switch {
+	case lsigned == rsigned:
+		ty = lty
+		if IntRank(rty) > IntRank(lty) {
+			ty = rty
+		}
+	default:
+		sty, uty := lty, rty
+		if !lsigned {
+			sty, uty = rty, lty
+		}
+		switch {
+		case IntRank(uty) >= IntRank(sty):
+			ty = uty
+		case p.szdesc.GetSize(sty) > p.szdesc.GetSize(uty):
+			ty = sty
+		default:
+			ty = UnsignedOf(sty)
+		}
+	}
+	return p.convert(l, ty), p.convert(r, ty), ty
+}
+
+// Type check a binary operator and construct the node,
+// inserting any conversions the operator requires.
+func (p *parser) binop(pos cpp.FilePos, op cpp.TokenKind, l, r Expr) Expr {
+	var ty CType
+	l = p.decay(l)
+	r = p.decay(r)
+	lptr, lisptr := l.GetType().(*Ptr)
+	rptr, risptr := r.GetType().(*Ptr)
+	switch op {
+	case cpp.LOR, cpp.LAND:
+		p.ensureScalar(l)
+		p.ensureScalar(r)
+		ty = CInt
+	case cpp.SHL, cpp.SHR:
+		p.ensureInt(l)
+		p.ensureInt(r)
+		l = p.intPromote(l)
+		r = p.intPromote(r)
+		ty = l.GetType()
+	case cpp.EQL, cpp.NEQ, '<', '>', cpp.LEQ, cpp.GEQ:
+		p.ensureScalar(l)
+		p.ensureScalar(r)
+		iseq := op == cpp.EQL || op == cpp.NEQ
+		switch {
+		case lisptr && risptr:
+			if TypesCompatible(lptr.PointsTo, rptr.PointsTo) {
+				break
+			}
+			if iseq && (IsVoidType(lptr.PointsTo) || IsVoidType(rptr.PointsTo)) {
+				break
+			}
+			p.errorPos(pos, "comparison of incompatible pointer types")
+		case lisptr:
+			if !iseq || !isNullPtrConstant(r) {
+				p.errorPos(pos, "comparison between pointer and integer")
+			}
+			r = p.convert(r, lptr)
+		case risptr:
+			if !iseq || !isNullPtrConstant(l) {
+				p.errorPos(pos, "comparison between pointer and integer")
+			}
+			l = p.convert(l, rptr)
+		default:
+			l, r, _ = p.arithConv(l, r)
+		}
+		ty = CInt
+	case '+':
+		switch {
+		case lisptr && risptr:
+			p.errorPos(pos, "invalid operands to binary +")
+		case lisptr:
+			p.ensureInt(r)
+			p.ensurePtrArith(pos, lptr)
+			ty = lptr
+		case risptr:
+			p.ensureInt(l)
+			p.ensurePtrArith(pos, rptr)
+			ty = rptr
+		default:
+			p.ensureInt(l)
+			p.ensureInt(r)
+			l, r, ty = p.arithConv(l, r)
+		}
+	case '-':
+		switch {
+		case lisptr && risptr:
+			if !TypesCompatible(lptr.PointsTo, rptr.PointsTo) {
+				p.errorPos(pos, "subtraction of incompatible pointer types")
+			}
+			p.ensurePtrArith(pos, lptr)
+			// ptrdiff_t
+			ty = CLong
+		case lisptr:
+			p.ensureInt(r)
+			p.ensurePtrArith(pos, lptr)
+			ty = lptr
+		default:
+			p.ensureInt(l)
+			p.ensureInt(r)
+			l, r, ty = p.arithConv(l, r)
+		}
+	default:
+		p.ensureInt(l)
+		p.ensureInt(r)
+		l, r, ty = p.arithConv(l, r)
+	}
+	return &Binop{
+		Pos:  pos,
+		Op:   op,
+		L:    l,
+		R:    r,
+		Type: ty,
+	}
+}
+
 func (p *parser) TUnit() {
 	for p.curt.Kind != cpp.EOF {
-		toplevel := p.Decl(true)
+		var toplevel Node
+		if p.curt.Kind == cpp.ASM {
+			toplevel = p.Asm()
+		} else {
+			toplevel = p.Decl(true)
+		}
 		p.tu.TopLevels = append(p.tu.TopLevels, toplevel)
 	}
+	// A global may be declared with an incomplete type as long
+	// as the type is completed by the end of the file. Tentative
+	// definitions of arrays without a size get a single element.
+	for _, gsym := range p.tu.Objects {
+		if arr, ok := gsym.Type.(*Array); ok && arr.Incomplete {
+			p.warnPos(p.objects[gsym], "array %s assumed to have one element", gsym.Label)
+			gsym.Type = &Array{MemberType: arr.MemberType, Dim: 1}
+		}
+		if IsIncomplete(gsym.Type) {
+			p.errorPos(p.objects[gsym], "storage size of %s is not known", gsym.Label)
+		}
+	}
+	// Later declarations may make an inline definition external.
+	for _, tl := range p.tu.TopLevels {
+		if f, ok := tl.(*CFunc); ok {
+			f.Weak = (f.Sym.InlineOnly && !f.Internal) || f.Sym.Weak
+		}
+	}
+	p.checkSymbols()
 }
 
 func (p *parser) isDeclStart(t *cpp.Token) bool {
 	switch t.Kind {
 	case cpp.IDENT:
-		_, err := p.decls.lookup(t.Val)
-		if err != nil {
+		// Only typedef names can start a declaration.
+		_, err := p.types.lookup(t.Val)
+		if err == nil {
 			return true
 		}
-	case cpp.STATIC, cpp.VOLATILE, cpp.STRUCT, cpp.CHAR, cpp.INT, cpp.SHORT, cpp.LONG,
+	case cpp.AUTO, cpp.STATIC, cpp.EXTERN, cpp.TYPEDEF, cpp.REGISTER, cpp.CONST, cpp.VOLATILE, cpp.RESTRICT, cpp.ATOMIC, cpp.STRUCT, cpp.UNION, cpp.ENUM, cpp.INLINE, cpp.NORETURN, cpp.ALIGNAS, cpp.STATIC_ASSERT, cpp.ATTRIBUTE, cpp.TYPEOF, cpp.AUTO_TYPE, cpp.VOID, cpp.BOOL, cpp.CHAR, cpp.INT, cpp.SHORT, cpp.LONG,
 		cpp.UNSIGNED, cpp.SIGNED, cpp.FLOAT, cpp.DOUBLE:
 		return true
 	}
@@ -236,6 +608,8 @@ func (p *parser) Stmt() Node {
 			return p.Default()
 		case cpp.GOTO:
 			return p.parseGoto()
+		case cpp.ASM:
+			return p.Asm()
 		case ';':
 			pos := p.curt.Pos
 			p.next()
@@ -276,11 +650,12 @@ func (p *parser) Switch() Node {
 	sw.LAfter = p.nextLabel()
 	p.expect(cpp.SWITCH)
 	p.expect('(')
-	expr := p.Expr()
-	sw.Expr = expr
+	expr := p.decay(p.Expr())
 	if !IsIntType(expr.GetType()) {
 		p.errorPos(expr.GetPos(), "switch expression expects an integral type")
 	}
+	// Case values are converted to the promoted type (C11 6.8.4.2p5).
+	sw.Expr = p.intPromote(expr)
 	p.expect(')')
 	p.pushSwitch(sw)
 	p.pushBreak(sw.LAfter)
@@ -294,29 +669,55 @@ func (p *parser) Switch() Node {
 func (p *parser) parseGoto() Node {
 	pos := p.curt.Pos
 	p.next()
-	actualLabel := p.curt.Val
+	if p.curt.Kind == '*' {
+		// A computed goto jumps to an address taken with &&label.
+		p.gnuExtension(pos, "computed goto")
+		p.next()
+		target := p.decay(p.Expr())
+		if !IsPtrType(target.GetType()) {
+			p.errorPos(target.GetPos(), "computed goto requires a pointer")
+		}
+		p.expect(';')
+		return &Goto{
+			Pos:    pos,
+			Target: target,
+		}
+	}
+	name := p.curt
 	p.expect(cpp.IDENT)
 	p.expect(';')
-	ret := &Goto{
+	p.gotos = append(p.gotos, vmJump{label: name, vm: p.vm})
+	return &Goto{
 		Pos:   pos,
-		Label: "", // To be fixed later.
+		Label: p.labelRef(name),
 	}
-	p.gotos = append(p.gotos, gotoFixup{
-		actualLabel,
-		ret,
-	})
-	return ret
+}
+
+// Returns the anonymous label of a goto label used in the current
+// function, which must be defined somewhere in it.
+func (p *parser) labelRef(name *cpp.Token) string {
+	p.labelRefs = append(p.labelRefs, name)
+	return p.anonLabel(name.Val)
+}
+
+func (p *parser) anonLabel(label string) string {
+	anonlabel, ok := p.labels[label]
+	if !ok {
+		anonlabel = p.nextLabel()
+		p.labels[label] = anonlabel
+	}
+	return anonlabel
 }
 
 func (p *parser) parseLabeledStmt() Node {
 	pos := p.curt.Pos
 	label := p.curt.Val
-	anonlabel := p.nextLabel()
-	_, ok := p.labels[label]
-	if ok {
+	if p.labelDefs[label] {
 		p.errorPos(pos, "redefinition of label %s in function", label)
 	}
-	p.labels[label] = anonlabel
+	p.labelDefs[label] = true
+	p.labelVMs[label] = p.vm
+	anonlabel := p.anonLabel(label)
 	p.expect(cpp.IDENT)
 	p.expect(':')
 	return &LabeledStmt{
@@ -334,23 +735,38 @@ func (p *parser) Case() Node {
 	if sw == nil {
 		p.errorPos(pos, "'case' outside a switch statement")
 	}
-	expr := p.Expr()
-	if !IsIntType(expr.GetType()) {
-		p.errorPos(expr.GetPos(), "expected an integral type")
-	}
-	v, err := p.fold(expr)
-	if err != nil {
-		p.errorPos(expr.GetPos(), err.Error())
+	p.checkSwitchJump(pos)
+	ty := sw.Expr.GetType()
+	signed := IsSignedIntType(ty)
+	lo := p.caseValue(ty)
+	hi := lo
+	isRange := p.curt.Kind == cpp.ELLIPSIS
+	if isRange {
+		p.gnuExtension(p.curt.Pos, "a case range")
+		p.next()
+		hi = p.caseValue(ty)
+		if compareInts('<', hi, lo, signed) {
+			p.warnPos(pos, "empty range specified")
+		}
 	}
 	p.expect(':')
 	anonlabel := p.nextLabel()
-	i := v.(*Constant)
-	// XXX TODO
-	swc := SwitchCase{
-		V:     i.Val,
-		Label: anonlabel,
+	if !compareInts('<', hi, lo, signed) {
+		for _, c := range sw.Cases {
+			if compareInts('<', hi, c.V, signed) || compareInts('<', c.Hi, lo, signed) {
+				continue
+			}
+			if isRange {
+				p.errorPos(pos, "duplicate (or overlapping) case value")
+			}
+			p.errorPos(pos, "duplicate case value")
+		}
+		sw.Cases = append(sw.Cases, SwitchCase{
+			V:     lo,
+			Hi:    hi,
+			Label: anonlabel,
+		})
 	}
-	sw.Cases = append(sw.Cases, swc)
 	return &LabeledStmt{
 		Pos:       pos,
 		AnonLabel: anonlabel,
@@ -359,6 +775,29 @@ func (p *parser) Case() Node {
 	}
 }
 
+// Parses a case value, converted to ty, the type of the switch.
+func (p *parser) caseValue(ty CType) int64 {
+	expr := p.CondExpr()
+	if !IsIntType(expr.GetType()) {
+		p.errorPos(expr.GetPos(), "expected an integral type")
+	}
+	v, err := p.fold(p.convert(expr, ty))
+	if err != nil {
+		p.errorPos(expr.GetPos(), "%s", err)
+	}
+	i, ok := v.(*Constant)
+	if !ok {
+		p.errorPos(expr.GetPos(), "case label does not reduce to an integer constant")
+	}
+	return i.Val
+}
+
+func (p *parser) checkSwitchJump(pos cpp.FilePos) {
+	if !vmInScope(p.vm, p.switchVMs[p.switchCounter-1]) {
+		p.errorPos(pos, "switch jumps into scope of identifier with variably modified type")
+	}
+}
+
 func (p *parser) Default() Node {
 	pos := p.curt.Pos
 	p.expect(cpp.DEFAULT)
@@ -366,6 +805,7 @@ func (p *parser) Default() Node {
 	if sw == nil {
 		p.errorPos(pos, "'default' outside a switch statement")
 	}
+	p.checkSwitchJump(pos)
 	p.expect(':')
 	if sw.LDefault != "" {
 		p.errorPos(pos, "multiple default statements in switch")
@@ -410,8 +850,31 @@ func (p *parser) BreakCont() Node {
 func (p *parser) Return() Node {
 	pos := p.curt.Pos
 	p.expect(cpp.RETURN)
-	expr := p.Expr()
+	retty := p.curFunc.FuncType.RetType
+	var expr Expr
+	if p.curt.Kind != ';' {
+		expr = p.decay(p.Expr())
+	}
 	p.expect(';')
+	if p.curFunc.NoReturn {
+		p.warnPos(pos, "function %s declared _Noreturn has a return statement", p.curFunc.Name)
+	}
+	switch {
+	case expr == nil:
+		if !IsVoidType(retty) {
+			if !p.opts.GNU {
+				p.errorPos(pos, "return with no value in function returning non-void")
+			}
+			p.warnPos(pos, "return with no value in function returning non-void")
+		}
+	case IsVoidType(retty):
+		// GNU C allows returning a void expression.
+		if !IsVoidType(expr.GetType()) || !p.opts.GNU {
+			p.errorPos(expr.GetPos(), "return with a value in function returning void")
+		}
+	default:
+		expr = p.assignConv(expr.GetPos(), retty, expr, "return")
+	}
 	return &Return{
 		Pos: pos,
 		Ret: expr,
@@ -444,14 +907,26 @@ func (p *parser) If() Node {
 func (p *parser) For() Node {
 	pos := p.curt.Pos
 	lstart := p.nextLabel()
+	lstep := p.nextLabel()
 	lend := p.nextLabel()
-	var init, cond, step Expr
+	var init Node
+	var cond, step Expr
 	p.expect(cpp.FOR)
 	p.expect('(')
-	if p.curt.Kind != ';' {
-		init = p.Expr()
+	// A declaration in the first clause is scoped to the loop.
+	p.pushScope()
+	if p.isDeclStart(p.curt) {
+		decl := p.Decl(false).(*DeclList)
+		if decl.Storage != SC_AUTO && decl.Storage != SC_REGISTER {
+			p.errorPos(decl.Pos, "declaration of non-automatic variable in for loop initial declaration")
+		}
+		init = decl
+	} else {
+		if p.curt.Kind != ';' {
+			init = p.Expr()
+		}
+		p.expect(';')
 	}
-	p.expect(';')
 	if p.curt.Kind != ';' {
 		cond = p.Expr()
 	}
@@ -460,9 +935,10 @@ func (p *parser) For() Node {
 		step = p.Expr()
 	}
 	p.expect(')')
-	p.pushBreakCont(lend, lstart)
+	p.pushBreakCont(lend, lstep)
 	body := p.Stmt()
 	p.popBreakCont()
+	p.popScope()
 	return &For{
 		Pos:    pos,
 		Init:   init,
@@ -470,6 +946,7 @@ func (p *parser) For() Node {
 		Step:   step,
 		Body:   body,
 		LStart: lstart,
+		LStep:  lstep,
 		LEnd:   lend,
 	}
 }
@@ -523,9 +1000,11 @@ func (p *parser) Block() *Block {
 	var stmts []Node
 	pos := p.curt.Pos
 	p.expect('{')
+	p.pushScope()
 	for p.curt.Kind != '}' {
 		stmts = append(stmts, p.Stmt())
 	}
+	p.popScope()
 	p.expect('}')
 	return &Block{
 		Pos:  pos,
@@ -535,26 +1014,65 @@ func (p *parser) Block() *Block {
 
 func (p *parser) FuncBody(f *CFunc) {
 	p.labels = make(map[string]string)
+	p.labelDefs = make(map[string]bool)
+	p.labelRefs = nil
+	p.labelVMs = make(map[string]*vmDecl)
 	p.gotos = nil
 	for p.curt.Kind != '}' {
 		stmt := p.Stmt()
 		f.Body = append(f.Body, stmt)
 	}
-	for _, fixup := range p.gotos {
-		anonlabel, ok := p.labels[fixup.actualLabel]
-		if !ok {
-			p.errorPos(fixup.g.GetPos(), "goto target %s is undefined", fixup.actualLabel)
+	for _, name := range p.labelRefs {
+		if !p.labelDefs[name.Val] {
+			p.errorPos(name.Pos, "goto target %s is undefined", name.Val)
 		}
-		fixup.g.Label = anonlabel
 	}
+	for _, g := range p.gotos {
+		if !vmInScope(p.labelVMs[g.label.Val], g.vm) {
+			p.errorPos(g.label.Pos, "jump into scope of identifier with variably modified type")
+		}
+	}
+}
+
+// Reaching the end of main returns 0, reaching the end
+// of any other function returning a value is suspicious.
+func (p *parser) checkFuncEnd(f *CFunc, endPos cpp.FilePos) {
+	retty := f.FuncType.RetType
+	if !stmtsFallThrough(f.Body) {
+		return
+	}
+	if f.NoReturn {
+		p.warnPos(endPos, "function %s declared _Noreturn can return", f.Name)
+		return
+	}
+	if IsVoidType(retty) {
+		return
+	}
+	if f.Name == "main" && Unqual(retty) == CInt {
+		f.Body = append(f.Body, &Return{
+			Pos: endPos,
+			Ret: &Constant{
+				Pos:  endPos,
+				Val:  0,
+				Type: CInt,
+			},
+		})
+		return
+	}
+	p.warnPos(endPos, "control reaches end of non-void function %s", f.Name)
 }
 
 func (p *parser) Decl(isGlobal bool) Node {
 	firstDecl := true
 	declPos := p.curt.Pos
 	var name *cpp.Token
-	declList := &DeclList{}
-	sc, ty := p.DeclSpecs()
+	var ty CType
+	declList := &DeclList{Pos: declPos}
+	if p.curt.Kind == cpp.STATIC_ASSERT {
+		p.StaticAssert()
+		return declList
+	}
+	sc, fs, sattrs, basety := p.DeclSpecs()
 	declList.Storage = sc
 	isTypedef := sc == SC_TYPEDEF
 
@@ -564,34 +1082,65 @@ func (p *parser) Decl(isGlobal bool) Node {
 	}
 
 	for {
-		name, ty = p.Declarator(ty, false)
+		mark := len(p.dims)
+		name, ty = p.Declarator(basety, false)
 		if name == nil {
 			panic("internal error")
 		}
+		dims := p.takeDims(mark)
+		a := *sattrs
+		p.Attributes(&a)
+		ty = p.modeType(name.Pos, ty, &a)
+		var autoInit Expr
+		if a.autoType {
+			ty, autoInit = p.autoTypeInit(name, basety, ty)
+		}
+		fty, isFunc := ty.(*CFuncT)
+		if fs&FS_INLINE != 0 && (!isFunc || isTypedef) {
+			p.errorPos(name.Pos, "%s declared inline but is not a function", name.Val)
+		}
+		if fs&FS_NORETURN != 0 && (!isFunc || isTypedef) {
+			p.errorPos(name.Pos, "%s declared _Noreturn but is not a function", name.Val)
+		}
+		if a.alignas != 0 {
+			p.checkAlignas(name, ty, sc, a.alignas)
+		}
+		align := a.alignas
+		if a.aligned > align {
+			align = a.aligned
+		}
 		if firstDecl && isGlobal {
 			// if declaring a function
-			if p.curt.Kind == '{' {
+			if p.curt.Kind == '{' || (isFunc && hasIdentList(fty) && p.isDeclStart(p.curt)) {
 				if isTypedef {
 					p.errorPos(name.Pos, "cannot typedef a function")
 				}
-				fty, ok := ty.(*CFuncT)
-				if !ok {
+				if !isFunc {
 					p.errorPos(name.Pos, "expected a function")
 				}
-				err := p.decls.define(name.Val, &GSymbol{
-					Label: name.Val,
-					Type:  fty,
-				})
-				if err != nil {
-					p.errorPos(declPos, err.Error())
+				if hasIdentList(fty) {
+					p.paramDeclList(fty)
+				}
+				if p.funcDefs[name.Val] {
+					p.errorPos(name.Pos, "redefinition of %s", name.Val)
 				}
+				p.funcDefs[name.Val] = true
+				gsym := p.declareFunc(name.Pos, name.Val, fty, sc, fs)
+				p.symbolAttrs(name, gsym, &a)
 				p.pushScope()
 				var psyms []*LSymbol
 
 				for idx, name := range fty.ArgNames {
+					if IsIncomplete(fty.ArgTypes[idx]) {
+						p.errorPos(declPos, "parameter %s has incomplete type", name)
+					}
 					sym := &LSymbol{
 						Type: fty.ArgTypes[idx],
 					}
+					if fty.params != nil {
+						// Keep the symbols array lengths refer to.
+						sym = fty.params[idx]
+					}
 					psyms = append(psyms, sym)
 					err := p.decls.define(name, sym)
 					if err != nil {
@@ -603,48 +1152,136 @@ func (p *parser) Decl(isGlobal bool) Node {
 					FuncType:     fty,
 					Pos:          declPos,
 					ParamSymbols: psyms,
+					Internal:     gsym.Internal,
+					NoReturn:     gsym.NoReturn,
+					Sym:          gsym,
+				}
+				// Lengths of variable length arrays in the parameter
+				// types are computed on entry to the function.
+				for _, d := range fty.dims {
+					f.Anonymous = append(f.Anonymous, d.sym)
+				}
+				if dims := withDims(fty.dims, nil); dims != nil {
+					f.Body = append(f.Body, &ExprStmt{
+						Pos:  declPos,
+						Expr: dims,
+					})
 				}
 				p.expect('{')
+				p.curFunc = f
+				p.dims = nil
 				p.FuncBody(f)
+				p.curFunc = nil
+				p.checkFuncEnd(f, p.curt.Pos)
 				p.expect('}')
 				p.popScope()
 				return f
 			}
 		}
+		if isFunc && hasIdentList(fty) {
+			p.errorPos(name.Pos, "parameter names without types in function declaration")
+		}
 		var sym Symbol
-		if isTypedef {
+		var err error
+		switch {
+		case isTypedef:
+			p.checkAttrs(name.Pos, &a, "mode", "aligned")
+			if a.aligned != 0 {
+				ty = AlignType(ty, a.aligned)
+			}
 			sym = &TSymbol{
 				Type: ty,
 			}
-		} else if isGlobal {
-			sym = &GSymbol{
-				Label: name.Val,
-				Type:  ty,
+			err = p.types.define(name.Val, sym)
+		case isFunc:
+			gsym := p.declareFunc(name.Pos, name.Val, fty, sc, fs)
+			p.symbolAttrs(name, gsym, &a)
+			sym = gsym
+		case isGlobal || sc == SC_EXTERN:
+			if isGlobal && sc == SC_REGISTER {
+				p.errorPos(name.Pos, "invalid storage class for %s", name.Val)
 			}
-		} else {
-			sym = &LSymbol{
-				Type: ty,
+			if IsVariablyModified(ty) {
+				p.errorPos(name.Pos, "object with variably modified type %s must have no linkage", name.Val)
+			}
+			gsym := p.declareLinked(name.Pos, name.Val, ty, sc)
+			if align > gsym.Align {
+				gsym.Align = align
+			}
+			p.symbolAttrs(name, gsym, &a)
+			sym = gsym
+		default:
+			// Arrays without a size are completed by their initializer.
+			if IsIncomplete(ty) && !(IsArrType(ty) && p.curt.Kind == '=') {
+				p.errorPos(name.Pos, "variable %s has incomplete type", name.Val)
+			}
+			if IsVariableSize(ty) && sc == SC_STATIC {
+				p.errorPos(name.Pos, "storage size of %s is not constant", name.Val)
+			}
+			if sc == SC_STATIC {
+				// Static locals have no linkage, so they get a
+				// label that cannot clash with any other.
+				gsym := &GSymbol{
+					Label:    name.Val + p.nextLabel(),
+					Type:     ty,
+					Internal: true,
+					Align:    align,
+				}
+				p.symbolAttrs(name, gsym, &a)
+				sym = gsym
+			} else {
+				lsym := &LSymbol{
+					Type:  ty,
+					Align: align,
+				}
+				p.localAttrs(name, lsym, &a)
+				sym = lsym
 			}
-		}
-		var err error
-		if isTypedef {
-			err = p.types.define(name.Val, sym)
-		} else {
 			err = p.decls.define(name.Val, sym)
 		}
 		if err != nil {
-			p.errorPos(name.Pos, err.Error())
+			p.errorPos(name.Pos, "%s", err)
+		}
+		if !isGlobal && IsVariablyModified(ty) {
+			p.vm = &vmDecl{outer: p.vm}
 		}
 		declList.Symbols = append(declList.Symbols, sym)
+		declList.Dims = append(declList.Dims, withDims(dims, nil))
 		var init Expr
 		var initPos cpp.FilePos
-		if p.curt.Kind == '=' {
-			p.next()
+		if p.curt.Kind == '=' || autoInit != nil {
+			if autoInit == nil {
+				p.next()
+			}
 			initPos = p.curt.Pos
 			if isTypedef {
 				p.errorPos(initPos, "cannot initialize a typedef")
 			}
-			init = p.Initializer(ty, true)
+			if isFunc {
+				p.errorPos(name.Pos, "function %s is initialized like a variable", name.Val)
+			}
+			if IsVariableSize(ty) {
+				p.errorPos(name.Pos, "variable-sized object %s may not be initialized", name.Val)
+			}
+			if !isGlobal && sc == SC_EXTERN {
+				p.errorPos(name.Pos, "%s has both extern and initializer", name.Val)
+			}
+			switch sym := sym.(type) {
+			case *GSymbol:
+				init = p.declInit(sym.Type, isGlobal || sc == SC_STATIC, autoInit)
+				if IsIncomplete(sym.Type) {
+					sym.Type = init.GetType()
+				}
+			case *LSymbol:
+				init = p.declInit(sym.Type, false, autoInit)
+				if IsIncomplete(sym.Type) {
+					sym.Type = init.GetType()
+				}
+			}
+		}
+		// An alias is defined by its target.
+		if gsym, ok := sym.(*GSymbol); ok && !isFunc && (a.alias == "" || init != nil) {
+			p.defineObject(name.Pos, gsym, sc, init)
 		}
 		declList.Inits = append(declList.Inits, init)
 		if p.curt.Kind != ',' {
@@ -660,17 +1297,224 @@ func (p *parser) Decl(isGlobal bool) Node {
 	return declList
 }
 
+// The type of a variable declared with __auto_type is the type of its
+// initializer, converted as if it were used as a value. So the
+// initializer is parsed before the variable is in scope.
+func (p *parser) autoTypeInit(name *cpp.Token, basety, ty CType) (CType, Expr) {
+	if ty != basety {
+		p.errorPos(name.Pos, "__auto_type requires a plain identifier as declarator")
+	}
+	if p.curt.Kind != '=' {
+		p.errorPos(name.Pos, "__auto_type declaration of %s has no initializer", name.Val)
+	}
+	p.next()
+	init := p.decay(p.AssignmentExpr())
+	if IsVoidType(init.GetType()) {
+		p.errorPos(init.GetPos(), "variable %s has initializer of type void", name.Val)
+	}
+	return Qualify(init.GetType(), QualsOf(basety)), init
+}
+
+// Parses the initializer of a declaration, unless it was parsed
+// already to find the type of an __auto_type declaration.
+func (p *parser) declInit(ty CType, constant bool, autoInit Expr) Expr {
+	if autoInit != nil {
+		return p.exprInit(ty, constant, autoInit)
+	}
+	return p.Initializer(ty, constant)
+}
+
+// Parameters of array and function type are adjusted to pointers.
+func adjustParamType(ty CType) CType {
+	switch t := ty.(type) {
+	case *Array:
+		return &Ptr{t.MemberType}
+	case *CFuncT:
+		return &Ptr{t}
+	}
+	return ty
+}
+
+// Reports whether fty is from an old style declarator with an
+// identifier list, which is only allowed in a function definition.
+func hasIdentList(fty *CFuncT) bool {
+	return fty.Unprototyped && len(fty.ArgNames) != 0
+}
+
+// Parses the declaration list of an old style function definition,
+// giving the types of parameters named in its identifier list.
+func (p *parser) paramDeclList(fty *CFuncT) {
+	declared := make(map[string]bool)
+	for p.curt.Kind != '{' {
+		pos := p.curt.Pos
+		sc, fs, sattrs, basety := p.DeclSpecs()
+		if sc != SC_AUTO && sc != SC_REGISTER {
+			p.errorPos(pos, "invalid storage class for parameter")
+		}
+		p.noFuncSpecs(pos, fs)
+		p.noAutoType(pos, sattrs)
+		p.noAlignas(pos, sattrs.alignas)
+		for {
+			name, ty := p.Declarator(basety, false)
+			a := *sattrs
+			p.Attributes(&a)
+			p.checkAttrs(name.Pos, &a, "mode")
+			ty = p.modeType(name.Pos, ty, &a)
+			idx := -1
+			for i, n := range fty.ArgNames {
+				if n == name.Val {
+					idx = i
+				}
+			}
+			if idx < 0 {
+				p.errorPos(name.Pos, "declaration for parameter %s but no such parameter", name.Val)
+			}
+			if declared[name.Val] {
+				p.errorPos(name.Pos, "redefinition of parameter %s", name.Val)
+			}
+			declared[name.Val] = true
+			fty.ArgTypes[idx] = adjustParamType(ty)
+			if p.curt.Kind != ',' {
+				break
+			}
+			p.next()
+		}
+		p.expect(';')
+	}
+}
+
+// Functions declared static have internal linkage, which is only
+// possible at file scope. If every file scope declaration of a
+// function is inline and none are extern, its definition is an
+// inline definition rather than an external one.
+func (p *parser) declareFunc(pos cpp.FilePos, name string, fty *CFuncT, sc SClass, fs FuncSpec) *GSymbol {
+	if sc == SC_REGISTER || (sc == SC_STATIC && p.curFunc != nil) {
+		p.errorPos(pos, "invalid storage class for function %s", name)
+	}
+	_, declared := p.linked[name]
+	gsym := p.declareLinked(pos, name, fty, sc)
+	if p.curFunc == nil {
+		inlineOnly := fs&FS_INLINE != 0 && sc != SC_EXTERN
+		gsym.InlineOnly = inlineOnly && (!declared || gsym.InlineOnly)
+	}
+	// Any declaration of a function may make it _Noreturn.
+	if fs&FS_NORETURN != 0 {
+		gsym.NoReturn = true
+	}
+	return gsym
+}
+
+// Objects declared with _Alignas must be neither typedefs, functions,
+// nor register variables, and may not be less strictly aligned than
+// their type requires.
+func (p *parser) checkAlignas(name *cpp.Token, ty CType, sc SClass, align int) {
+	switch {
+	case sc == SC_TYPEDEF:
+		p.errorPos(name.Pos, "_Alignas specified for typedef %s", name.Val)
+	case IsCFuncType(ty):
+		p.errorPos(name.Pos, "_Alignas specified for function %s", name.Val)
+	case sc == SC_REGISTER:
+		p.errorPos(name.Pos, "_Alignas specified for register variable %s", name.Val)
+	case align < p.szdesc.GetAlign(ty):
+		p.errorPos(name.Pos, "_Alignas cannot reduce the alignment of %s", name.Val)
+	}
+}
+
+// Identifiers with linkage may be declared any number of times, in
+// any scope, as long as the declarations agree. All of them refer to
+// the same symbol. A later declaration may complete the type, either
+// with a prototype or with the size of an array.
+func (p *parser) declareLinked(pos cpp.FilePos, name string, ty CType, sc SClass) *GSymbol {
+	gsym, ok := p.linked[name]
+	if !ok {
+		gsym = &GSymbol{
+			Label:    name,
+			Type:     ty,
+			Internal: sc == SC_STATIC,
+		}
+		p.linked[name] = gsym
+		p.tu.Symbols = append(p.tu.Symbols, gsym)
+	} else {
+		if IsCFuncType(gsym.Type) != IsCFuncType(ty) {
+			p.errorPos(pos, "%s redeclared as a different kind of symbol", name)
+		}
+		if !TypesCompatible(gsym.Type, ty) {
+			p.errorPos(pos, "conflicting types for %s", name)
+		}
+		// Extern declarations, and function declarations without a
+		// storage class, take the linkage of the earlier declaration.
+		if sc == SC_STATIC && !gsym.Internal {
+			p.errorPos(pos, "static declaration of %s follows non-static declaration", name)
+		}
+		if gsym.Internal && sc != SC_STATIC && sc != SC_EXTERN && !IsCFuncType(ty) {
+			p.errorPos(pos, "non-static declaration of %s follows static declaration", name)
+		}
+		switch t := gsym.Type.(type) {
+		case *CFuncT:
+			if t.Unprototyped {
+				gsym.Type = ty
+			}
+		case *Array:
+			if t.Incomplete {
+				gsym.Type = ty
+			}
+		}
+	}
+	sym, err := p.decls.lookupLocal(name)
+	if err != nil {
+		err = p.decls.define(name, gsym)
+		if err != nil {
+			p.errorPos(pos, "%s", err)
+		}
+	} else if sym != gsym {
+		p.errorPos(pos, "redefinition of %s", name)
+	}
+	return gsym
+}
+
+// Objects with static storage are defined by a declaration that is
+// not extern. At file scope they may be defined without an
+// initializer, a tentative definition, any number of times, but
+// may only be initialized once.
+func (p *parser) defineObject(pos cpp.FilePos, gsym *GSymbol, sc SClass, init Expr) {
+	if sc == SC_EXTERN && init == nil {
+		return
+	}
+	if gsym.Alias != "" {
+		p.errorPos(pos, "%s defined both normally and as an alias", gsym.Label)
+	}
+	if init != nil {
+		if gsym.Init != nil {
+			p.errorPos(pos, "redefinition of %s", gsym.Label)
+		}
+		gsym.Init = init
+	}
+	if _, ok := p.objects[gsym]; !ok {
+		p.objects[gsym] = pos
+		p.tu.Objects = append(p.tu.Objects, gsym)
+	}
+}
+
 func (p *parser) ParamDecl() (*cpp.Token, CType) {
-	_, ty := p.DeclSpecs()
-	return p.Declarator(ty, true)
+	pos := p.curt.Pos
+	_, fs, a, ty := p.DeclSpecs()
+	p.noFuncSpecs(pos, fs)
+	p.noAlignas(pos, a.alignas)
+	p.noAutoType(pos, a)
+	name, ty := p.Declarator(ty, true)
+	p.Attributes(a)
+	p.checkAttrs(pos, a, "mode")
+	return name, p.modeType(pos, ty, a)
 }
 
 func isStorageClass(k cpp.TokenKind) (bool, SClass) {
 	switch k {
+	case cpp.AUTO:
+		return true, SC_AUTO
 	case cpp.STATIC:
 		return true, SC_STATIC
 	case cpp.EXTERN:
-		return true, SC_GLOBAL
+		return true, SC_EXTERN
 	case cpp.TYPEDEF:
 		return true, SC_TYPEDEF
 	case cpp.REGISTER:
@@ -680,6 +1524,7 @@ func isStorageClass(k cpp.TokenKind) (bool, SClass) {
 }
 
 type dSpec struct {
+	boolcnt     int
 	signedcnt   int
 	unsignedcnt int
 	charcnt     int
@@ -696,6 +1541,9 @@ type dSpecLutEnt struct {
 }
 
 var declSpecLut = [...]dSpecLutEnt{
+	{dSpec{
+		boolcnt: 1,
+	}, CBool},
 	{dSpec{
 		charcnt: 1,
 	}, CChar},
@@ -781,7 +1629,7 @@ var declSpecLut = [...]dSpecLutEnt{
 		unsignedcnt: 1,
 		longcnt:     1,
 		intcnt:      1,
-	}, CLong},
+	}, CULong},
 	{dSpec{
 		longcnt: 2,
 	}, CLLong},
@@ -815,13 +1663,22 @@ var declSpecLut = [...]dSpecLutEnt{
 	}, CDouble},
 }
 
-func (p *parser) DeclSpecs() (SClass, CType) {
+// Parses declaration specifiers, returning the storage class, function
+// specifiers, attributes, including any alignment requested with
+// _Alignas, and the type.
+func (p *parser) DeclSpecs() (SClass, FuncSpec, *attrs, CType) {
 	dspecpos := p.curt.Pos
 	scassigned := false
 	sc := SC_AUTO
+	var fs FuncSpec
+	a := &attrs{}
 	var ty CType = CInt
 	var spec dSpec
 	nullspec := dSpec{}
+	isvoid := false
+	// A typedef name or struct specifier.
+	var named CType
+	var quals Qualifiers
 loop:
 	for {
 		pos := p.curt.Pos
@@ -836,7 +1693,24 @@ loop:
 			continue
 		}
 		switch p.curt.Kind {
+		case cpp.INLINE:
+			fs |= FS_INLINE
+			p.next()
+		case cpp.NORETURN:
+			fs |= FS_NORETURN
+			p.next()
+		case cpp.ALIGNAS:
+			// The strictest of several alignments applies.
+			if align := p.Alignas(); align > a.alignas {
+				a.alignas = align
+			}
+		case cpp.ATTRIBUTE:
+			p.Attributes(a)
 		case cpp.VOID:
+			isvoid = true
+			p.next()
+		case cpp.BOOL:
+			spec.boolcnt += 1
 			p.next()
 		case cpp.CHAR:
 			spec.charcnt += 1
@@ -863,31 +1737,75 @@ loop:
 			spec.unsignedcnt += 1
 			p.next()
 		case cpp.IDENT:
-			t := p.curt
+			// Once a type is known, an identifier is the declarator.
+			if named != nil || spec != nullspec || isvoid {
+				break loop
+			}
+			t := p.curt
 			sym, err := p.types.lookup(t.Val)
 			if err != nil {
 				break loop
 			}
-			tsym := sym.(*TSymbol)
 			p.next()
-			if spec != nullspec {
-				p.error("TODO...")
-			}
-			return sc, tsym.Type
-		case cpp.STRUCT:
-			if spec != nullspec {
-				p.error("TODO...")
-			}
-			ty = p.Struct()
-			return sc, ty
-		case cpp.UNION:
-		case cpp.VOLATILE, cpp.CONST:
+			named = sym.(*TSymbol).Type
+		case cpp.STRUCT, cpp.UNION:
+			if named != nil || spec != nullspec || isvoid {
+				p.errorPos(pos, "invalid type")
+			}
+			named = p.Struct()
+		case cpp.ENUM:
+			if named != nil || spec != nullspec || isvoid {
+				p.errorPos(pos, "invalid type")
+			}
+			named = p.Enum()
+		case cpp.ATOMIC:
+			if p.nextt.Kind != '(' {
+				quals |= QualAtomic
+				p.next()
+				break
+			}
+			// The _Atomic(type-name) specifier.
+			if named != nil || spec != nullspec || isvoid {
+				p.errorPos(pos, "invalid type")
+			}
+			p.next()
+			p.next()
+			named = Qualify(p.TypeName(), QualAtomic)
+			p.expect(')')
+		case cpp.TYPEOF:
+			if named != nil || spec != nullspec || isvoid {
+				p.errorPos(pos, "invalid type")
+			}
+			named = p.Typeof()
+		case cpp.AUTO_TYPE:
+			// The type is taken from the initializer by Decl.
+			if named != nil || spec != nullspec || isvoid {
+				p.errorPos(pos, "invalid type")
+			}
+			p.gnuExtension(pos, "__auto_type")
+			p.next()
+			a.autoType = true
+			named = CVoid
+		case cpp.CONST, cpp.VOLATILE, cpp.RESTRICT:
+			quals |= qualifier(p.curt.Kind)
 			p.next()
 		default:
 			break loop
 		}
 	}
 
+	if isvoid {
+		if spec != nullspec || named != nil {
+			p.errorPos(dspecpos, "invalid type")
+		}
+		ty = CVoid
+	}
+	if named != nil {
+		if spec != nullspec {
+			p.errorPos(dspecpos, "invalid type")
+		}
+		ty = named
+	}
 	// If we got any type specifiers, look up
 	// the correct type.
 	if spec != nullspec {
@@ -903,7 +1821,111 @@ loop:
 			p.errorPos(dspecpos, "invalid type")
 		}
 	}
-	return sc, ty
+	return sc, fs, a, p.qualify(dspecpos, ty, quals)
+}
+
+// Parses typeof(type-name) or typeof(expression), giving the type of
+// the expression, which is not evaluated.
+func (p *parser) Typeof() CType {
+	pos := p.curt.Pos
+	p.gnuExtension(pos, "typeof")
+	p.next()
+	p.expect('(')
+	var ty CType
+	if p.isDeclStart(p.curt) {
+		ty = p.TypeName()
+	} else {
+		e := p.Expr()
+		if isBitfield(e) {
+			p.errorPos(pos, "typeof applied to a bit-field")
+		}
+		ty = e.GetType()
+	}
+	p.expect(')')
+	return ty
+}
+
+// Reports __auto_type used outside the declaration of a variable.
+func (p *parser) noAutoType(pos cpp.FilePos, a *attrs) {
+	if a.autoType {
+		p.errorPos(pos, "__auto_type not allowed here")
+	}
+}
+
+// Reports function specifiers used outside a declaration.
+func (p *parser) noFuncSpecs(pos cpp.FilePos, fs FuncSpec) {
+	if fs != 0 {
+		p.errorPos(pos, "function specifier not allowed here")
+	}
+}
+
+// Reports _Alignas used outside the declaration of an object.
+func (p *parser) noAlignas(pos cpp.FilePos, align int) {
+	if align != 0 {
+		p.errorPos(pos, "_Alignas not allowed here")
+	}
+}
+
+// Parses _Alignas(type-name) or _Alignas(constant-expression),
+// returning the requested alignment, or 0 for _Alignas(0),
+// which has no effect.
+func (p *parser) Alignas() int {
+	p.next()
+	p.expect('(')
+	pos := p.curt.Pos
+	var align int64
+	if p.isDeclStart(p.curt) {
+		ty := p.TypeName()
+		if IsIncomplete(ty) || IsCFuncType(ty) {
+			p.errorPos(pos, "invalid type in _Alignas")
+		}
+		align = int64(p.szdesc.GetAlign(ty))
+	} else {
+		e := p.CondExpr()
+		c, err := p.fold(e)
+		if err != nil {
+			p.errorPos(pos, "%s", err)
+		}
+		v, ok := c.(*Constant)
+		if !ok || !IsIntType(v.Type) {
+			p.errorPos(pos, "_Alignas requires an integer constant")
+		}
+		align = v.Val
+		if align < 0 || align&(align-1) != 0 {
+			p.errorPos(pos, "requested alignment is not a power of 2")
+		}
+	}
+	p.expect(')')
+	return int(align)
+}
+
+func qualifier(k cpp.TokenKind) Qualifiers {
+	switch k {
+	case cpp.CONST:
+		return QualConst
+	case cpp.VOLATILE:
+		return QualVolatile
+	case cpp.RESTRICT:
+		return QualRestrict
+	case cpp.ATOMIC:
+		return QualAtomic
+	}
+	return 0
+}
+
+func isQualifier(k cpp.TokenKind) bool {
+	return qualifier(k) != 0
+}
+
+// Apply qualifiers to ty, checking that they are valid for it.
+func (p *parser) qualify(pos cpp.FilePos, ty CType, q Qualifiers) CType {
+	if q&QualRestrict != 0 && !IsPtrType(ty) {
+		p.errorPos(pos, "restrict requires a pointer type")
+	}
+	if q&QualAtomic != 0 && (IsArrType(ty) || IsCFuncType(ty)) {
+		p.errorPos(pos, "_Atomic cannot be applied to an array or function type")
+	}
+	return Qualify(ty, q)
 }
 
 // Declarator
@@ -930,21 +1952,33 @@ loop:
 // A delcarator missing an identifier.
 
 func (p *parser) Declarator(basety CType, abstract bool) (*cpp.Token, CType) {
-	for p.curt.Kind == cpp.CONST || p.curt.Kind == cpp.VOLATILE {
+	// Qualifiers after a '*' apply to the pointer. Attributes
+	// within a declarator would apply to the derived type, which
+	// is not supported, so any with an effect are reported.
+	pos := p.curt.Pos
+	var quals Qualifiers
+	for isQualifier(p.curt.Kind) || p.curt.Kind == cpp.ATTRIBUTE {
+		if p.curt.Kind == cpp.ATTRIBUTE {
+			var a attrs
+			p.Attributes(&a)
+			p.checkAttrs(pos, &a)
+			continue
+		}
+		quals |= qualifier(p.curt.Kind)
 		p.next()
 	}
+	basety = p.qualify(pos, basety, quals)
 	switch p.curt.Kind {
 	case '*':
 		p.next()
-		name, ty := p.Declarator(basety, abstract)
-		return name, &Ptr{ty}
+		return p.Declarator(&Ptr{basety}, abstract)
 	case '(':
 		forward := &ForwardedType{}
 		p.next()
 		name, ty := p.Declarator(forward, abstract)
 		p.expect(')')
 		forward.Type = p.DeclaratorTail(basety)
-		return name, ty
+		return name, resolveForward(ty)
 	case cpp.IDENT:
 		name := p.curt
 		p.next()
@@ -958,103 +1992,204 @@ func (p *parser) Declarator(basety CType, abstract bool) (*cpp.Token, CType) {
 	panic("unreachable")
 }
 
+// Replace any ForwardedType placeholders in ty with the type
+// they were later resolved to.
+func resolveForward(ty CType) CType {
+	switch t := ty.(type) {
+	case *ForwardedType:
+		return resolveForward(t.Type)
+	case *Ptr:
+		return &Ptr{resolveForward(t.PointsTo)}
+	case *Qualified:
+		ret := Qualify(resolveForward(t.Type), t.Quals)
+		if t.Align != 0 {
+			ret = AlignType(ret, t.Align)
+		}
+		return ret
+	case *Array:
+		return &Array{
+			MemberType: resolveForward(t.MemberType),
+			Dim:        t.Dim,
+			Incomplete: t.Incomplete,
+			DimSym:     t.DimSym,
+		}
+	case *CFuncT:
+		ret := *t
+		ret.RetType = resolveForward(t.RetType)
+		return &ret
+	}
+	return ty
+}
+
+// The suffixes of a declarator apply from the outside in, so for
+// int a[2][3] the type is an array of 2 arrays of 3 ints.
 func (p *parser) DeclaratorTail(basety CType) CType {
-	ret := basety
-	for {
-		switch p.curt.Kind {
-		case '[':
+	switch p.curt.Kind {
+	case '[':
+		pos := p.curt.Pos
+		p.next()
+		if p.curt.Kind == ']' {
 			p.next()
-			var dimn Expr
-			if p.curt.Kind != ']' {
-				dimn = p.AssignmentExpr()
+			return &Array{
+				MemberType: p.flexibleUse(pos, p.DeclaratorTail(basety)),
+				Incomplete: true,
 			}
-			p.expect(']')
-			dim, err := p.fold(dimn)
-			if err != nil {
-				p.errorPos(dimn.GetPos(), "invalid constant Expr for array dimensions")
+		}
+		if p.curt.Kind == '*' && p.nextt.Kind == ']' && p.protoDepth > 0 {
+			// [*] is a variable length array of unspecified
+			// length, only allowed in a prototype.
+			p.next()
+			p.next()
+			return &Array{
+				MemberType: p.flexibleUse(pos, p.DeclaratorTail(basety)),
+				DimSym:     &LSymbol{Type: CULong},
 			}
-			i, ok := dim.(*Constant)
-			if !ok || !IsIntType(i.Type) {
-				p.errorPos(dimn.GetPos(), "Expected an int type for array length")
+		}
+		dimn := p.AssignmentExpr()
+		p.expect(']')
+		dim, err := p.fold(dimn)
+		if err != nil {
+			// Arrays with a length that is not constant are variable
+			// length arrays, which cannot have static storage.
+			if p.decls.parent == nil {
+				p.errorPos(dimn.GetPos(), "%s", err)
 			}
-			ret = &Array{
-				Dim:        int(i.Val),
-				MemberType: ret,
+			return &Array{
+				MemberType: p.flexibleUse(pos, p.DeclaratorTail(basety)),
+				DimSym:     p.vlaDim(dimn),
 			}
-		case '(':
-			fret := &CFuncT{}
-			fret.RetType = basety
+		}
+		i, ok := dim.(*Constant)
+		if !ok || !IsIntType(i.Type) {
+			p.errorPos(dimn.GetPos(), "Expected an int type for array length")
+		}
+		if i.Val < 0 && IsSignedIntType(i.Type) {
+			p.errorPos(dimn.GetPos(), "size of array is negative")
+		}
+		return &Array{
+			Dim:        int(i.Val),
+			MemberType: p.flexibleUse(pos, p.DeclaratorTail(basety)),
+		}
+	case '(':
+		fret := &CFuncT{}
+		p.next()
+		switch {
+		case p.curt.Kind == ')':
+			// () declares a function without a prototype.
+			fret.Unprototyped = true
+		case p.curt.Kind == cpp.VOID && p.nextt.Kind == ')':
+			// (void) declares a function with no parameters.
 			p.next()
-			if p.curt.Kind != ')' {
-				for {
-					pnametok, pty := p.ParamDecl()
-					pname := ""
-					if pnametok != nil {
-						pname = pnametok.Val
-					}
-					fret.ArgTypes = append(fret.ArgTypes, pty)
-					fret.ArgNames = append(fret.ArgNames, pname)
-					if p.curt.Kind == ',' {
-						p.next()
-						continue
-					}
+		case p.curt.Kind == cpp.IDENT && !p.isDeclStart(p.curt):
+			// An old style identifier list, the types of the parameters
+			// are declared before the function body and default to int.
+			fret.Unprototyped = true
+			for {
+				pname := p.curt
+				p.expect(cpp.IDENT)
+				fret.ArgTypes = append(fret.ArgTypes, CInt)
+				fret.ArgNames = append(fret.ArgNames, pname.Val)
+				if p.curt.Kind != ',' {
 					break
 				}
+				p.next()
 			}
-			p.expect(')')
-			ret = fret
 		default:
-			return ret
+			// Parameters are in scope for the rest of the
+			// prototype, so array lengths can refer to them.
+			p.decls = newScope(p.decls)
+			p.protoDepth += 1
+			mark := len(p.dims)
+			for {
+				if p.curt.Kind == cpp.ELLIPSIS {
+					if len(fret.ArgTypes) == 0 {
+						p.errorPos(p.curt.Pos, "expected a named parameter before '...'")
+					}
+					p.next()
+					fret.IsVarArg = true
+					break
+				}
+				pnametok, pty := p.ParamDecl()
+				pty = adjustParamType(pty)
+				pname := ""
+				psym := &LSymbol{
+					Type: pty,
+				}
+				if pnametok != nil {
+					pname = pnametok.Val
+					err := p.decls.define(pname, psym)
+					if err != nil {
+						p.errorPos(pnametok.Pos, "multiple params with name %s", pname)
+					}
+				}
+				fret.ArgTypes = append(fret.ArgTypes, pty)
+				fret.ArgNames = append(fret.ArgNames, pname)
+				fret.params = append(fret.params, psym)
+				if p.curt.Kind == ',' {
+					p.next()
+					continue
+				}
+				break
+			}
+			fret.dims = p.takeDims(mark)
+			p.protoDepth -= 1
+			p.decls = p.decls.parent
 		}
+		p.expect(')')
+		fret.RetType = p.DeclaratorTail(basety)
+		return fret
+	default:
+		return basety
 	}
 }
 
-func (p *parser) Initializer(ty CType, constant bool) Expr {
-	_ = p.curt.Pos
-	if IsScalarType(ty) {
-		var init Expr
-		if p.curt.Kind == '{' {
-			p.expect('{')
-			init = p.AssignmentExpr()
-			p.expect('}')
-		} else {
-			init = p.AssignmentExpr()
-		}
-		// XXX ensure types are compatible.
-		// XXX Add cast.
-		if constant {
-			c, err := p.fold(init)
-			if err != nil {
-				p.errorPos(init.GetPos(), err.Error())
-			}
-			return c
-		} else {
-			return init
-		}
-	} /* else if IsCharArr(ty) {
-		switch p.curt.Kind {
-		case cpp.STRING:
-			p.expect(cpp.STRING)
-		case '{':
-			p.expect('{')
-			p.expect(cpp.STRING)
-			p.expect('}')
-		default:
-		}
-	} else if IsArrType(ty) {
-		arr := ty.(*Array)
-		p.expect('{')
-		var inits []Node
-		for p.curt.Kind != '}' {
-			inits = append(inits, p.parseInitializer(arr.MemberType, true))
-			if p.curt.Kind == ',' {
-				continue
-			}
-		}
-		p.expect('}')
+// Creates the hidden local holding the length of a variable
+// length array, which is set by evaluating n.
+func (p *parser) vlaDim(n Expr) *LSymbol {
+	n = p.decay(n)
+	if !IsIntType(n.GetType()) {
+		p.errorPos(n.GetPos(), "size of array has non-integer type")
+	}
+	sym := &LSymbol{
+		Type: CULong,
+	}
+	p.dims = append(p.dims, vlaDim{
+		sym: sym,
+		set: p.assign(n.GetPos(), '=', &Ident{Pos: n.GetPos(), Sym: sym}, n),
+	})
+	if p.curFunc != nil {
+		p.curFunc.Anonymous = append(p.curFunc.Anonymous, sym)
+	}
+	return sym
+}
+
+// Collects the lengths of the variable length arrays
+// declared since the length of p.dims was mark.
+func (p *parser) takeDims(mark int) []vlaDim {
+	dims := append([]vlaDim(nil), p.dims[mark:]...)
+	p.dims = p.dims[:mark]
+	return dims
+}
+
+// Returns an expression computing the lengths dims, or nil if there
+// are none. If n is not nil it is evaluated last to give the value.
+func withDims(dims []vlaDim, n Expr) Expr {
+	if len(dims) == 0 {
+		return n
+	}
+	var exprs []Expr
+	for _, d := range dims {
+		exprs = append(exprs, d.set)
+	}
+	if n != nil {
+		exprs = append(exprs, n)
+	}
+	last := exprs[len(exprs)-1]
+	return &Comma{
+		Pos:   exprs[0].GetPos(),
+		Exprs: exprs,
+		Type:  last.GetType(),
 	}
-	*/
-	panic("unimplemented")
 }
 
 func isAssignmentOperator(k cpp.TokenKind) bool {
@@ -1067,15 +2202,25 @@ func isAssignmentOperator(k cpp.TokenKind) bool {
 }
 
 func (p *parser) Expr() Expr {
-	var ret Expr
+	pos := p.curt.Pos
+	var exprs []Expr
 	for {
-		ret = p.AssignmentExpr()
+		exprs = append(exprs, p.AssignmentExpr())
 		if p.curt.Kind != ',' {
 			break
 		}
 		p.next()
 	}
-	return ret
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	last := p.decay(exprs[len(exprs)-1])
+	exprs[len(exprs)-1] = last
+	return &Comma{
+		Pos:   pos,
+		Exprs: exprs,
+		Type:  last.GetType(),
+	}
 }
 
 func (p *parser) AssignmentExpr() Expr {
@@ -1084,21 +2229,286 @@ func (p *parser) AssignmentExpr() Expr {
 		pos := p.curt.Pos
 		op := p.curt.Kind
 		p.next()
-		r := p.AssignmentExpr()
-		l = &Binop{
+		r := p.decay(p.AssignmentExpr())
+		return p.assign(pos, op, l, r)
+	}
+	return l
+}
+
+func isLvalue(n Expr) bool {
+	switch n := n.(type) {
+	case *Ident:
+		return !IsCFuncType(n.GetType())
+	case *Unop:
+		return n.Op == '*'
+	case *Index, *CompoundLiteral:
+		return true
+	case *Selector:
+		if n.Op == cpp.ARROW {
+			return true
+		}
+		return isLvalue(n.Operand)
+	}
+	return false
+}
+
+func (p *parser) ensureModifiableLvalue(pos cpp.FilePos, n Expr) {
+	if !isLvalue(n) || IsArrType(n.GetType()) {
+		p.errorPos(pos, "expression is not assignable")
+	}
+	if IsIncomplete(n.GetType()) {
+		p.errorPos(pos, "assignment to an incomplete type")
+	}
+	if QualsOf(n.GetType())&QualConst != 0 {
+		p.errorPos(pos, "assignment to a const-qualified lvalue")
+	}
+	if HasConstMember(n.GetType()) {
+		p.errorPos(pos, "assignment to a struct with a const member")
+	}
+}
+
+func isArithType(t CType) bool {
+	return IsIntType(t) || IsFloatType(t)
+}
+
+// Convert n to ty as if by assignment, checking the constraints of
+// C11 6.5.16.1. what names the kind of conversion in diagnostics.
+func (p *parser) assignConv(pos cpp.FilePos, ty CType, n Expr, what string) Expr {
+	ty = Unqual(ty)
+	nty := Unqual(n.GetType())
+	lptr, lisptr := ty.(*Ptr)
+	rptr, risptr := nty.(*Ptr)
+	switch {
+	case isArithType(ty) && isArithType(nty):
+	case IsStructType(ty) || IsStructType(nty):
+		if !TypesCompatible(ty, nty) {
+			p.errorPos(pos, "incompatible types in %s", what)
+		}
+	case lisptr && risptr:
+		p.checkQualDrop(pos, ty, n)
+		if !IsVoidType(lptr.PointsTo) && !IsVoidType(rptr.PointsTo) &&
+			!TypesCompatible(Unqual(lptr.PointsTo), Unqual(rptr.PointsTo)) {
+			p.warnPos(pos, "incompatible pointer types in %s", what)
+		}
+	case lisptr && isNullPtrConstant(n):
+	case lisptr && IsIntType(nty):
+		p.warnPos(pos, "%s makes pointer from integer without a cast", what)
+	case IsIntType(ty) && risptr:
+		if ty != CBool {
+			p.warnPos(pos, "%s makes integer from pointer without a cast", what)
+		}
+	default:
+		p.errorPos(pos, "incompatible types in %s", what)
+	}
+	return p.convert(n, ty)
+}
+
+// Check and convert the arguments of a call. Arguments matching a
+// prototype are converted as if by assignment to the parameter type,
+// the rest, or all of them without a prototype, are promoted.
+func (p *parser) callArgs(pos cpp.FilePos, fty *CFuncT, args []Expr) []Expr {
+	if !fty.Unprototyped {
+		if len(args) < len(fty.ArgTypes) {
+			p.errorPos(pos, "too few arguments to function call, expected %d, have %d", len(fty.ArgTypes), len(args))
+		}
+		if len(args) > len(fty.ArgTypes) && !fty.IsVarArg {
+			p.errorPos(pos, "too many arguments to function call, expected %d, have %d", len(fty.ArgTypes), len(args))
+		}
+	}
+	for idx, arg := range args {
+		if fty.Unprototyped || idx >= len(fty.ArgTypes) {
+			args[idx] = p.argPromote(arg)
+			continue
+		}
+		what := fmt.Sprintf("argument %d", idx+1)
+		args[idx] = p.assignConv(arg.GetPos(), fty.ArgTypes[idx], arg, what)
+	}
+	return args
+}
+
+// Converting a pointer may add qualifiers to the pointed to type
+// but never remove them (C11 6.5.16.1). Like other compilers, only
+// warn, as much existing code does this.
+func (p *parser) checkQualDrop(pos cpp.FilePos, ty CType, n Expr) {
+	lptr, lisptr := Unqual(ty).(*Ptr)
+	rptr, risptr := Unqual(n.GetType()).(*Ptr)
+	if !lisptr || !risptr {
+		return
+	}
+	lq := QualsOf(lptr.PointsTo)
+	rq := QualsOf(rptr.PointsTo)
+	if rq&^lq != 0 {
+		p.warnPos(pos, "conversion discards qualifiers from pointer target type")
+	}
+}
+
+// Maps an assignment operator to the binary operator it applies.
+func compoundOp(k cpp.TokenKind) cpp.TokenKind {
+	switch k {
+	case cpp.ADD_ASSIGN:
+		return '+'
+	case cpp.SUB_ASSIGN:
+		return '-'
+	case cpp.MUL_ASSIGN:
+		return '*'
+	case cpp.QUO_ASSIGN:
+		return '/'
+	case cpp.REM_ASSIGN:
+		return '%'
+	case cpp.AND_ASSIGN:
+		return '&'
+	case cpp.OR_ASSIGN:
+		return '|'
+	case cpp.XOR_ASSIGN:
+		return '^'
+	case cpp.SHL_ASSIGN:
+		return cpp.SHL
+	case cpp.SHR_ASSIGN:
+		return cpp.SHR
+	}
+	panic("internal error")
+}
+
+func (p *parser) assign(pos cpp.FilePos, op cpp.TokenKind, l, r Expr) Expr {
+	p.ensureModifiableLvalue(pos, l)
+	// The result has the unqualified type of the left operand.
+	ty := Unqual(l.GetType())
+	if op == '=' {
+		return &Binop{
 			Pos:  pos,
 			Op:   op,
 			L:    l,
-			R:    r,
-			Type: CInt,
+			R:    p.assignConv(pos, ty, r, "assignment"),
+			Type: ty,
 		}
 	}
-	return l
+	op = compoundOp(op)
+	var opty CType
+	lptr, lisptr := ty.(*Ptr)
+	switch {
+	case lisptr && (op == '+' || op == '-'):
+		p.ensureInt(r)
+		p.ensurePtrArith(pos, lptr)
+		opty = lptr
+	case op == cpp.SHL || op == cpp.SHR:
+		p.ensureInt(l)
+		p.ensureInt(r)
+		opty = p.intPromote(l).GetType()
+		r = p.intPromote(r)
+	default:
+		p.ensureInt(l)
+		p.ensureInt(r)
+		_, r, opty = p.arithConv(l, r)
+	}
+	return &CompoundAssign{
+		Pos:    pos,
+		Op:     op,
+		L:      l,
+		R:      r,
+		OpType: opty,
+		Type:   ty,
+	}
+}
+
+func (p *parser) incDec(pos cpp.FilePos, op cpp.TokenKind, post bool, operand Expr) Expr {
+	p.ensureModifiableLvalue(pos, operand)
+	p.ensureScalar(operand)
+	if ptr, ok := Unqual(operand.GetType()).(*Ptr); ok {
+		p.ensurePtrArith(pos, ptr)
+	}
+	return &IncDec{
+		Pos:     pos,
+		Op:      op,
+		Post:    post,
+		Operand: operand,
+		Type:    Unqual(operand.GetType()),
+	}
 }
 
 // Aka Ternary operator.
 func (p *parser) CondExpr() Expr {
-	return p.LogOrExpr()
+	c := p.LogOrExpr()
+	if p.curt.Kind != '?' {
+		return c
+	}
+	pos := p.curt.Pos
+	p.next()
+	c = p.decay(c)
+	p.ensureScalar(c)
+	if p.curt.Kind == ':' {
+		p.gnuExtension(pos, "omitting the middle operand of ?:")
+		p.next()
+		return p.elvis(pos, c, p.decay(p.CondExpr()))
+	}
+	t := p.decay(p.Expr())
+	p.expect(':')
+	f := p.decay(p.CondExpr())
+	return p.cond(pos, c, t, f)
+}
+
+// The GNU c ?: f is c ? c : f, but evaluates c only once. Unless it
+// is a constant, its value is kept in a temporary for the result.
+func (p *parser) elvis(pos cpp.FilePos, c, f Expr) Expr {
+	if v, err := p.fold(c); err == nil {
+		if _, ok := v.(*Constant); ok {
+			c = v
+		}
+	}
+	if _, ok := c.(*Constant); ok || p.curFunc == nil {
+		return p.cond(pos, c, c, f)
+	}
+	tmp := &LSymbol{
+		Type: Unqual(c.GetType()),
+	}
+	p.curFunc.Anonymous = append(p.curFunc.Anonymous, tmp)
+	set := p.assign(pos, '=', &Ident{Pos: pos, Sym: tmp}, c)
+	return p.cond(pos, set, &Ident{Pos: pos, Sym: tmp}, f)
+}
+
+// Determine the result type of the ternary operator as
+// described in C11 6.5.15, converting both branches to it.
+func (p *parser) cond(pos cpp.FilePos, c, t, f Expr) Expr {
+	var ty CType
+	tty := t.GetType()
+	fty := f.GetType()
+	tptr, tisptr := tty.(*Ptr)
+	fptr, fisptr := fty.(*Ptr)
+	switch {
+	case IsIntType(tty) && IsIntType(fty):
+		t, f, ty = p.arithConv(t, f)
+	case IsVoidType(tty) && IsVoidType(fty):
+		ty = CVoid
+	case IsStructType(tty) && tty == fty:
+		ty = tty
+	case tisptr && fisptr:
+		switch {
+		case TypesCompatible(tptr.PointsTo, fptr.PointsTo):
+			ty = tty
+		case isNullPtrConstant(f):
+			ty = tty
+		case isNullPtrConstant(t):
+			ty = fty
+		case IsVoidType(tptr.PointsTo):
+			ty = tty
+		case IsVoidType(fptr.PointsTo):
+			ty = fty
+		default:
+			p.errorPos(pos, "pointer type mismatch in conditional expression")
+		}
+	case tisptr && isNullPtrConstant(f):
+		ty = tty
+	case fisptr && isNullPtrConstant(t):
+		ty = fty
+	default:
+		p.errorPos(pos, "type mismatch in conditional expression")
+	}
+	return &Cond{
+		Pos:  pos,
+		Cond: c,
+		Then: p.convert(t, ty),
+		Else: p.convert(f, ty),
+		Type: ty,
+	}
 }
 
 func (p *parser) LogOrExpr() Expr {
@@ -1108,13 +2518,7 @@ func (p *parser) LogOrExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.LogAndExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1126,13 +2530,7 @@ func (p *parser) LogAndExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.OrExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1144,13 +2542,7 @@ func (p *parser) OrExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.XorExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1162,13 +2554,7 @@ func (p *parser) XorExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.AndExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1180,13 +2566,7 @@ func (p *parser) AndExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.EqlExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1198,13 +2578,7 @@ func (p *parser) EqlExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.RelExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1216,13 +2590,7 @@ func (p *parser) RelExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.ShiftExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1234,13 +2602,7 @@ func (p *parser) ShiftExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.AddExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1252,13 +2614,7 @@ func (p *parser) AddExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.MulExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1270,13 +2626,7 @@ func (p *parser) MulExpr() Expr {
 		op := p.curt.Kind
 		p.next()
 		r := p.CastExpr()
-		l = &Binop{
-			Pos:  pos,
-			Op:   op,
-			L:    l,
-			R:    r,
-			Type: CInt,
-		}
+		l = p.binop(pos, op, l, r)
 	}
 	return l
 }
@@ -1287,21 +2637,67 @@ func (p *parser) CastExpr() Expr {
 		if p.isDeclStart(p.nextt) {
 			pos := p.curt.Pos
 			p.expect('(')
+			mark := len(p.dims)
 			ty := p.TypeName()
+			dims := p.takeDims(mark)
 			p.expect(')')
-			operand := p.UnaryExpr()
-			return &Cast{
+			if p.curt.Kind == '{' {
+				return p.postfix(p.compoundLiteral(pos, ty))
+			}
+			operand := p.decay(p.CastExpr())
+			return withDims(dims, &Cast{
 				Pos:     pos,
 				Operand: operand,
 				Type:    ty,
-			}
+			})
 		}
 	}
 	return p.UnaryExpr()
 }
 
+// A compound literal creates an unnamed object, with static storage
+// at file scope, and otherwise automatic storage in the enclosing
+// function.
+func (p *parser) compoundLiteral(pos cpp.FilePos, ty CType) Expr {
+	if IsCFuncType(ty) || (IsIncomplete(ty) && !IsArrType(ty)) {
+		p.errorPos(pos, "compound literal has invalid type")
+	}
+	if IsVariableSize(ty) {
+		p.errorPos(pos, "compound literal has variable size")
+	}
+	lit := &CompoundLiteral{
+		Pos: pos,
+	}
+	lit.Init = p.Initializer(ty, p.curFunc == nil)
+	if IsIncomplete(ty) {
+		ty = lit.Init.GetType()
+	}
+	lit.Type = ty
+	if p.curFunc == nil {
+		gsym := &GSymbol{
+			Label:    p.nextLabel(),
+			Type:     ty,
+			Internal: true,
+		}
+		p.defineObject(pos, gsym, SC_STATIC, lit.Init)
+		lit.Sym = gsym
+	} else {
+		lsym := &LSymbol{
+			Type: ty,
+		}
+		p.curFunc.Anonymous = append(p.curFunc.Anonymous, lsym)
+		lit.Sym = lsym
+	}
+	return lit
+}
+
 func (p *parser) TypeName() CType {
-	_, ty := p.DeclSpecs()
+	pos := p.curt.Pos
+	_, fs, a, ty := p.DeclSpecs()
+	p.noFuncSpecs(pos, fs)
+	p.noAlignas(pos, a.alignas)
+	p.noAutoType(pos, a)
+	p.checkAttrs(pos, a)
 	_, ty = p.Declarator(ty, true)
 	return ty
 }
@@ -1309,24 +2705,60 @@ func (p *parser) TypeName() CType {
 func (p *parser) UnaryExpr() Expr {
 	switch p.curt.Kind {
 	case cpp.INC, cpp.DEC:
+		pos := p.curt.Pos
+		op := p.curt.Kind
 		p.next()
-		p.UnaryExpr()
+		operand := p.UnaryExpr()
+		return p.incDec(pos, op, false, operand)
+	case cpp.SIZEOF, cpp.ALIGNOF:
+		return p.Sizeof()
+	case cpp.LAND:
+		// The GNU &&label takes the address of a label.
+		pos := p.curt.Pos
+		p.gnuExtension(pos, "taking the address of a label")
+		if p.curFunc == nil {
+			p.errorPos(pos, "taking the address of a label outside a function")
+		}
+		p.next()
+		name := p.curt
+		p.expect(cpp.IDENT)
+		return &LabelAddr{
+			Pos:   pos,
+			Label: p.labelRef(name),
+		}
 	case '*', '+', '-', '!', '~', '&':
 		pos := p.curt.Pos
 		op := p.curt.Kind
 		p.next()
 		operand := p.CastExpr()
+		if op != '&' {
+			operand = p.decay(operand)
+		}
 		ty := operand.GetType()
-		if op == '&' {
+		switch op {
+		case '&':
+			if !isLvalue(operand) && !IsCFuncType(ty) {
+				p.errorPos(pos, "lvalue required as unary '&' operand")
+			}
+			if isBitfield(operand) {
+				p.errorPos(pos, "cannot take the address of a bit-field")
+			}
 			ty = &Ptr{
 				PointsTo: ty,
 			}
-		} else if op == '*' {
+		case '*':
 			ptr, ok := ty.(*Ptr)
 			if !ok {
 				p.errorPos(pos, "dereferencing requires a pointer type")
 			}
 			ty = ptr.PointsTo
+		case '!':
+			p.ensureScalar(operand)
+			ty = CInt
+		case '+', '-', '~':
+			p.ensureInt(operand)
+			operand = p.intPromote(operand)
+			ty = operand.GetType()
 		}
 		return &Unop{
 			Pos:     pos,
@@ -1337,18 +2769,145 @@ func (p *parser) UnaryExpr() Expr {
 	default:
 		return p.PostExpr()
 	}
-	panic("unreachable")
+}
+
+// sizeof and _Alignof, which are folded to a constant of type size_t,
+// except for the size of a variable length array.
+func (p *parser) Sizeof() Expr {
+	pos := p.curt.Pos
+	op := p.curt.Kind
+	p.next()
+	var ty CType
+	var dims []vlaDim
+	if p.curt.Kind == '(' && p.isDeclStart(p.nextt) {
+		p.next()
+		mark := len(p.dims)
+		ty = p.TypeName()
+		dims = p.takeDims(mark)
+		p.expect(')')
+	} else {
+		operand := p.UnaryExpr()
+		if isBitfield(operand) {
+			p.errorPos(pos, "invalid application of %s to a bit-field", op)
+		}
+		ty = operand.GetType()
+	}
+	switch {
+	case IsCFuncType(ty):
+		p.errorPos(pos, "invalid application of %s to a function type", op)
+	case IsVoidType(ty):
+		if !p.opts.GNU {
+			p.errorPos(pos, "invalid application of %s to a void type", op)
+		}
+		// GNU C gives void a size and alignment of 1.
+		return &Constant{
+			Pos:  pos,
+			Val:  1,
+			Type: CULong,
+		}
+	case IsIncomplete(ty):
+		p.errorPos(pos, "invalid application of %s to an incomplete type", op)
+	}
+	if op == cpp.SIZEOF && IsVariableSize(ty) {
+		return withDims(dims, &Sizeof{
+			Pos: pos,
+			Of:  ty,
+		})
+	}
+	var v int
+	if op == cpp.SIZEOF {
+		v = p.szdesc.GetSize(ty)
+	} else {
+		v = p.szdesc.GetAlign(ty)
+	}
+	return &Constant{
+		Pos:  pos,
+		Val:  int64(v),
+		Type: CULong,
+	}
+}
+
+// Parses __builtin_offsetof(type-name, member-designator) where the
+// designator is a member name followed by any number of .member
+// and [constant] suffixes.
+func (p *parser) Offsetof() Expr {
+	pos := p.curt.Pos
+	p.next()
+	p.expect('(')
+	ty := p.TypeName()
+	p.expect(',')
+	offset := int64(0)
+	first := true
+	for {
+		switch {
+		case first || p.curt.Kind == '.':
+			if !first {
+				p.next()
+			}
+			first = false
+			strct, isStruct := Unqual(ty).(*CStruct)
+			if !isStruct {
+				p.errorPos(p.curt.Pos, "offsetof requires a struct or union type")
+			}
+			sel := p.curt
+			p.expect(cpp.IDENT)
+			if strct.Incomplete {
+				p.errorPos(sel.Pos, "offsetof applied to an incomplete type")
+			}
+			path := strct.FieldPath(sel.Val)
+			if path == nil {
+				p.errorPos(sel.Pos, "struct does not have field %s", sel.Val)
+			}
+			for _, idx := range path {
+				if strct.BitWidth(idx) >= 0 {
+					p.errorPos(sel.Pos, "offsetof applied to a bit-field")
+				}
+				offset += int64(p.szdesc.GetLayout(strct).Offsets[idx])
+				ty = strct.Types[idx]
+				strct, _ = Unqual(ty).(*CStruct)
+			}
+		case p.curt.Kind == '[':
+			arr, isArr := ty.(*Array)
+			if !isArr {
+				p.errorPos(p.curt.Pos, "offsetof subscript requires an array member")
+			}
+			p.next()
+			idx := p.Expr()
+			p.expect(']')
+			c, err := p.fold(idx)
+			if err != nil {
+				p.errorPos(idx.GetPos(), "%s", err)
+			}
+			v, ok := c.(*Constant)
+			if !ok {
+				p.errorPos(idx.GetPos(), "offsetof subscript must be an integer constant")
+			}
+			ty = arr.MemberType
+			offset += v.Val * int64(p.szdesc.GetSize(ty))
+		default:
+			p.expect(')')
+			return &Constant{
+				Pos:  pos,
+				Val:  offset,
+				Type: CULong,
+			}
+		}
+	}
 }
 
 func (p *parser) PostExpr() Expr {
-	l := p.PrimaryExpr()
+	return p.postfix(p.PrimaryExpr())
+}
+
+// Parses any postfix operators applied to l.
+func (p *parser) postfix(l Expr) Expr {
 loop:
 	for {
 		switch p.curt.Kind {
 		case '[':
 			var ty CType
 			arr, isArr := l.GetType().(*Array)
-			ptr, isPtr := l.GetType().(*Ptr)
+			ptr, isPtr := Unqual(l.GetType()).(*Ptr)
 			if !isArr && !isPtr {
 				p.errorPos(p.curt.Pos, "Can only index into array or pointer types")
 			}
@@ -1358,62 +2917,50 @@ loop:
 			if isPtr {
 				ty = ptr.PointsTo
 			}
+			pos := p.curt.Pos
+			if IsIncomplete(ty) {
+				p.errorPos(pos, "subscript of an incomplete type")
+			}
 			p.next()
 			idx := p.Expr()
 			p.expect(']')
 			l = &Index{
+				Pos:  pos,
 				Arr:  l,
 				Idx:  idx,
 				Type: ty,
 			}
 		case '.':
 			pos := p.curt.Pos
-			strct, isStruct := l.GetType().(*CStruct)
+			strct, isStruct := Unqual(l.GetType()).(*CStruct)
 			p.next()
 			if !isStruct {
 				p.errorPos(l.GetPos(), "expected a struct")
 			}
-			sel := p.curt
-			p.expect(cpp.IDENT)
-			ty := strct.FieldType(sel.Val)
-			if ty == nil {
-				p.errorPos(pos, "struct does not have field %s", sel.Val)
-			}
-			l = &Selector{
-				Op:      '.',
-				Pos:     pos,
-				Operand: l,
-				Type:    ty,
-				Sel:     sel.Val,
+			if strct.Incomplete {
+				p.errorPos(pos, "member access into incomplete type")
 			}
+			// Members of a qualified struct have its qualifiers.
+			l = p.selectMember(pos, '.', l, strct, QualsOf(l.GetType()))
 		case cpp.ARROW:
 			pos := p.curt.Pos
 			p.next()
-			pty, isPtr := l.GetType().(*Ptr)
+			pty, isPtr := Unqual(l.GetType()).(*Ptr)
 			if !isPtr {
 				p.errorPos(l.GetPos(), "expected a pointer")
 			}
-			sty, isPStruct := pty.PointsTo.(*CStruct)
+			sty, isPStruct := Unqual(pty.PointsTo).(*CStruct)
 			if !isPStruct {
 				p.errorPos(l.GetPos(), "expected a pointer to a struct")
 			}
-			sel := p.curt
-			p.expect(cpp.IDENT)
-			ty := sty.FieldType(sel.Val)
-			if ty == nil {
-				p.errorPos(pos, "struct does not have field %s", sel.Val)
-			}
-			l = &Selector{
-				Op:      cpp.ARROW,
-				Pos:     pos,
-				Operand: l,
-				Type:    ty,
-				Sel:     sel.Val,
+			if sty.Incomplete {
+				p.errorPos(pos, "member access into incomplete type")
 			}
+			l = p.selectMember(pos, cpp.ARROW, l, sty, QualsOf(pty.PointsTo))
 		case '(':
 			parenpos := p.curt.Pos
 			var fty *CFuncT
-			switch ty := l.GetType().(type) {
+			switch ty := Unqual(l.GetType()).(type) {
 			case *Ptr:
 				functy, ok := ty.PointsTo.(*CFuncT)
 				if !ok {
@@ -1429,7 +2976,7 @@ loop:
 			p.next()
 			if p.curt.Kind != ')' {
 				for {
-					args = append(args, p.AssignmentExpr())
+					args = append(args, p.decay(p.AssignmentExpr()))
 					if p.curt.Kind == ',' {
 						p.next()
 						continue
@@ -1438,16 +2985,23 @@ loop:
 				}
 			}
 			p.expect(')')
-			return &Call{
+			call := &Call{
 				Pos:      parenpos,
 				FuncLike: l,
-				Args:     args,
+				Args:     p.callArgs(parenpos, fty, args),
 				Type:     fty.RetType,
 			}
-		case cpp.INC:
-			p.next()
-		case cpp.DEC:
+			if ident, ok := l.(*Ident); ok {
+				if gsym, ok := ident.Sym.(*GSymbol); ok && gsym.format != nil {
+					p.checkFormat(call, gsym.format)
+				}
+			}
+			l = call
+		case cpp.INC, cpp.DEC:
+			pos := p.curt.Pos
+			op := p.curt.Kind
 			p.next()
+			l = p.incDec(pos, op, true, l)
 		default:
 			break loop
 		}
@@ -1455,41 +3009,260 @@ loop:
 	return l
 }
 
-func constantToExpr(t *cpp.Token) (Expr, error) {
+// Parses the member name after op, selecting it from l, which is
+// or points to strct with qualifiers quals. A member of an anonymous
+// struct or union is selected through each enclosing member.
+func (p *parser) selectMember(pos cpp.FilePos, op cpp.TokenKind, l Expr, strct *CStruct, quals Qualifiers) Expr {
+	sel := p.curt
+	p.expect(cpp.IDENT)
+	path := strct.FieldPath(sel.Val)
+	if path == nil {
+		p.errorPos(pos, "struct does not have field %s", sel.Val)
+	}
+	for _, idx := range path {
+		ty := Qualify(strct.Types[idx], quals)
+		l = &Selector{
+			Op:      op,
+			Pos:     pos,
+			Operand: l,
+			Type:    ty,
+			Sel:     strct.Names[idx],
+			Index:   idx,
+		}
+		op = '.'
+		strct, _ = Unqual(ty).(*CStruct)
+	}
+	return l
+}
+
+// The candidate types of an integer constant for each suffix, in the
+// order they are tried (C11 6.4.4.1). Octal and hexadecimal constants
+// may also have unsigned types without a u suffix.
+var intConstTypes = map[string][2][]CType{
+	"": {
+		{CInt, CLong, CLLong},
+		{CInt, CUInt, CLong, CULong, CLLong, CULLong},
+	},
+	"u": {
+		{CUInt, CULong, CULLong},
+		{CUInt, CULong, CULLong},
+	},
+	"l": {
+		{CLong, CLLong},
+		{CLong, CULong, CLLong, CULLong},
+	},
+	"ul": {
+		{CULong, CULLong},
+		{CULong, CULLong},
+	},
+	"ll": {
+		{CLLong},
+		{CLLong, CULLong},
+	},
+	"ull": {
+		{CULLong},
+		{CULLong},
+	},
+}
+
+// Splits the suffix from an integer constant, returning it in a
+// canonical form, or ok == false if the suffix is invalid.
+func intConstSuffix(s string) (digits string, suffix string, ok bool) {
+	end := strings.TrimRight(s, "uUlL")
+	tail := s[len(end):]
+	unsigned := false
+	long := ""
+	for len(tail) != 0 {
+		switch {
+		case (tail[0] == 'u' || tail[0] == 'U') && !unsigned:
+			unsigned = true
+			tail = tail[1:]
+		case (strings.HasPrefix(tail, "ll") || strings.HasPrefix(tail, "LL")) && long == "":
+			long = "ll"
+			tail = tail[2:]
+		case (tail[0] == 'l' || tail[0] == 'L') && long == "":
+			long = "l"
+			tail = tail[1:]
+		default:
+			return "", "", false
+		}
+	}
+	if unsigned {
+		return end, "u" + long, true
+	}
+	return end, long, true
+}
+
+func (p *parser) constantToExpr(t *cpp.Token) (Expr, error) {
 	switch t.Kind {
 	case cpp.INT_CONSTANT:
-		v, err := strconv.ParseInt(t.Val, 0, 64)
-		return &Constant{
-			Val:  v,
-			Pos:  t.Pos,
-			Type: CInt,
-		}, err
+		digits, suffix, ok := intConstSuffix(t.Val)
+		if !ok {
+			return nil, fmt.Errorf("invalid suffix on integer constant %s", t.Val)
+		}
+		v, err := strconv.ParseUint(digits, 0, 64)
+		if err != nil {
+			if err.(*strconv.NumError).Err == strconv.ErrRange {
+				return nil, fmt.Errorf("integer constant %s is too large", t.Val)
+			}
+			return nil, fmt.Errorf("invalid integer constant %s", t.Val)
+		}
+		types := intConstTypes[suffix][0]
+		if digits[0] == '0' && len(digits) > 1 {
+			// Octal or hexadecimal.
+			types = intConstTypes[suffix][1]
+		}
+		for _, ty := range types {
+			bits := uint(p.szdesc.GetSize(ty) * 8)
+			if IsSignedIntType(ty) {
+				bits -= 1
+			}
+			if bits >= 64 || v < uint64(1)<<bits {
+				return &Constant{
+					Val:  int64(v),
+					Pos:  t.Pos,
+					Type: ty,
+				}, nil
+			}
+		}
+		return nil, fmt.Errorf("integer constant %s is too large for any integer type", t.Val)
 	default:
 		return nil, fmt.Errorf("internal error - %s", t.Kind)
 	}
 }
 
+var simpleEscapes = map[byte]byte{
+	'n': '\n', 't': '\t', 'r': '\r', 'a': '\a', 'b': '\b', 'f': '\f',
+	'v': '\v', '\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+// Returns the chars of a quoted string literal with escapes
+// replaced, not including the terminating null.
+func unquoteString(lit string) ([]byte, error) {
+	s := lit[1 : len(lit)-1]
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, fmt.Errorf("invalid escape in string %s", lit)
+		}
+		if c, ok := simpleEscapes[s[i]]; ok {
+			b = append(b, c)
+			continue
+		}
+		// Octal escapes have at most three digits, hex escapes any number.
+		start, base, digits, max := i, 8, "01234567", 3
+		if s[i] == 'x' {
+			i++
+			start, base, digits, max = i, 16, "0123456789abcdefABCDEF", len(s)
+		}
+		end := start
+		for end < len(s) && end-start < max && strings.IndexByte(digits, s[end]) >= 0 {
+			end++
+		}
+		v, err := strconv.ParseUint(s[start:end], base, 64)
+		if err != nil || v > 255 {
+			return nil, fmt.Errorf("invalid escape in string %s", lit)
+		}
+		b = append(b, byte(v))
+		i = end - 1
+	}
+	return b, nil
+}
+
+// A character constant has type int. Its chars are signed, and a
+// constant of several chars has them in order from the most
+// significant byte, as in GCC.
+func (p *parser) charConstant(t *cpp.Token) Expr {
+	b, err := unquoteString(t.Val)
+	if err != nil {
+		p.errorPos(t.Pos, "%s", err)
+	}
+	if len(b) == 0 {
+		p.errorPos(t.Pos, "empty character constant")
+	}
+	if len(b) > 1 {
+		p.warnPos(t.Pos, "multi-character character constant")
+	}
+	var v int32
+	for _, c := range b {
+		v = v<<8 | int32(c)
+	}
+	if len(b) == 1 {
+		v = int32(int8(b[0]))
+	}
+	return &Constant{
+		Pos:  t.Pos,
+		Val:  int64(v),
+		Type: CInt,
+	}
+}
+
+// Calling an undeclared function declares it as returning int with
+// no prototype. This was removed in C99, but is accepted with a
+// warning in GNU mode.
+func (p *parser) implicitDecl(name *cpp.Token) Symbol {
+	if !p.opts.GNU {
+		p.errorPos(name.Pos, "implicit declaration of function %s", name.Val)
+	}
+	p.warnPos(name.Pos, "implicit declaration of function %s", name.Val)
+	fty := &CFuncT{
+		RetType:      CInt,
+		Unprototyped: true,
+	}
+	decls := p.decls
+	for p.decls.parent != nil {
+		p.decls = p.decls.parent
+	}
+	sym := p.declareLinked(name.Pos, name.Val, fty, SC_EXTERN)
+	p.decls = decls
+	return sym
+}
+
 func (p *parser) PrimaryExpr() Expr {
 	switch p.curt.Kind {
 	case cpp.IDENT:
+		if b := p.Builtin(); b != nil {
+			return b
+		}
 		sym, err := p.decls.lookup(p.curt.Val)
-		if err != nil {
+		if err != nil && p.nextt.Kind == '(' {
+			sym = p.implicitDecl(p.curt)
+		} else if err != nil {
 			p.errorPos(p.curt.Pos, "undefined symbol %s", p.curt.Val)
 		}
+		pos := p.curt.Pos
 		p.next()
+		if gsym, ok := sym.(*GSymbol); ok {
+			gsym.used = true
+		}
+		if esym, ok := sym.(*ESymbol); ok {
+			return &Constant{
+				Pos:  pos,
+				Val:  esym.Val,
+				Type: CInt,
+			}
+		}
 		return &Ident{
+			Pos: pos,
 			Sym: sym,
 		}
 	case cpp.INT_CONSTANT:
 		t := p.curt
 		p.next()
-		n, err := constantToExpr(t)
+		n, err := p.constantToExpr(t)
 		if err != nil {
-			p.errorPos(t.Pos, err.Error())
+			p.errorPos(t.Pos, "%s", err)
 		}
 		return n
 	case cpp.CHAR_CONSTANT:
+		t := p.curt
 		p.next()
+		return p.charConstant(t)
 	case cpp.STRING:
 		s := p.curt
 		p.next()
@@ -1502,64 +3275,468 @@ func (p *parser) PrimaryExpr() Expr {
 		p.addAnonymousString(rstr)
 		return rstr
 	case '(':
+		if p.nextt.Kind == '{' {
+			return p.StmtExpr()
+		}
 		p.next()
 		expr := p.Expr()
 		p.expect(')')
 		return expr
+	case cpp.GENERIC:
+		return p.Generic()
 	default:
 		p.errorPos(p.curt.Pos, "expected an identifier, constant, string or Expr")
 	}
 	panic("unreachable")
 }
 
-func (p *parser) Struct() CType {
-	p.expect(cpp.STRUCT)
-	var ret *CStruct
-	sname := ""
+// Parses a GNU statement expression, ({ ... }). When the block ends
+// with an expression statement, its value is the value of the whole,
+// converted as if it were used as a value.
+func (p *parser) StmtExpr() Expr {
+	pos := p.curt.Pos
+	p.gnuExtension(pos, "a statement expression")
+	if p.curFunc == nil {
+		p.errorPos(pos, "statement expression outside a function")
+	}
+	p.expect('(')
+	body := p.Block()
+	p.expect(')')
+	var ty CType = CVoid
+	if n := len(body.Body); n != 0 {
+		if last, ok := body.Body[n-1].(*ExprStmt); ok {
+			last.Expr = p.decay(last.Expr)
+			ty = last.Expr.GetType()
+		}
+	}
+	return &StmtExpr{
+		Pos:  pos,
+		Body: body,
+		Type: ty,
+	}
+}
+
+// Parses a _Generic selection, which is replaced by the expression
+// associated with the type of the controlling expression, after it is
+// converted as if it were used as a value. The controlling expression
+// and the other associations are not evaluated.
+func (p *parser) Generic() Expr {
+	pos := p.curt.Pos
+	p.next()
+	p.expect('(')
+	cty := p.decay(p.AssignmentExpr()).GetType()
+	var types []CType
+	var match, def Expr
+	for p.curt.Kind == ',' {
+		p.next()
+		apos := p.curt.Pos
+		var ty CType
+		if p.curt.Kind == cpp.DEFAULT {
+			if def != nil {
+				p.errorPos(apos, "duplicate default in _Generic")
+			}
+			p.next()
+		} else {
+			ty = p.TypeName()
+			if IsIncomplete(ty) || IsCFuncType(ty) || IsVariablyModified(ty) {
+				p.errorPos(apos, "_Generic association has an invalid type")
+			}
+			for _, t := range types {
+				if TypesCompatible(t, ty) {
+					p.errorPos(apos, "_Generic specifies two compatible types")
+				}
+			}
+			types = append(types, ty)
+		}
+		p.expect(':')
+		e := p.AssignmentExpr()
+		switch {
+		case ty == nil:
+			def = e
+		case TypesCompatible(cty, ty):
+			match = e
+		}
+	}
+	p.expect(')')
+	if match == nil {
+		match = def
+	}
+	if match == nil {
+		p.errorPos(pos, "_Generic selector is not compatible with any association")
+	}
+	return match
+}
+
+// Parses _Static_assert(constant-expression, string-literal), which
+// fails to compile if the expression is zero. The message may be
+// omitted, as in C23.
+func (p *parser) StaticAssert() {
+	pos := p.curt.Pos
+	p.next()
+	p.expect('(')
+	cond := p.CondExpr()
+	c, err := p.fold(cond)
+	if err != nil {
+		p.errorPos(cond.GetPos(), "%s", err)
+	}
+	v, ok := c.(*Constant)
+	if !ok || !IsIntType(v.Type) {
+		p.errorPos(cond.GetPos(), "expression in static assertion is not an integer constant")
+	}
+	msg := ""
+	if p.curt.Kind == ',' {
+		p.next()
+		msg = p.curt.Val
+		p.expect(cpp.STRING)
+	}
+	p.expect(')')
+	p.expect(';')
+	if v.Val == 0 {
+		if msg == "" {
+			p.errorPos(pos, "static assertion failed")
+		}
+		p.errorPos(pos, "static assertion failed: %s", msg)
+	}
+}
+
+// Enumerated types are represented by the integer type they are
+// compatible with, which like gcc is unsigned int unless some
+// enumerator is negative, in which case it is int. Enum tags share a
+// namespace with struct and union tags.
+func (p *parser) Enum() CType {
+	p.expect(cpp.ENUM)
+	// Enums are always int sized, so attributes such as packed
+	// which would change that are ignored.
+	var a attrs
+	p.Attributes(&a)
 	npos := p.curt.Pos
+	var tag *TSymbol
 	if p.curt.Kind == cpp.IDENT {
-		sname = p.curt.Val
+		ename := p.curt.Val
 		p.next()
-		sym, err := p.structs.lookup(sname)
-		if err != nil && p.curt.Kind != '{' {
-			p.errorPos(npos, err.Error())
+		var sym Symbol
+		var err error
+		if p.curt.Kind == '{' {
+			sym, err = p.structs.lookupLocal(ename)
+		} else {
+			sym, err = p.structs.lookup(ename)
 		}
+		isEnum := false
 		if err == nil {
-			ret = sym.(*TSymbol).Type.(*CStruct)
+			_, isEnum = sym.(*TSymbol).Type.(Primitive)
+		}
+		switch {
+		case err == nil && !isEnum:
+			p.errorPos(npos, "%s defined as the wrong kind of tag", ename)
+		case err == nil && p.curt.Kind == '{':
+			p.errorPos(npos, "redefinition of enum %s", ename)
+		case err != nil && p.curt.Kind != '{':
+			p.errorPos(npos, "use of undefined enum %s", ename)
+		case err != nil:
+			tag = &TSymbol{
+				Type: CInt,
+			}
+			err = p.structs.define(ename, tag)
+			if err != nil {
+				p.errorPos(npos, "%s", err)
+			}
+		}
+		if p.curt.Kind != '{' {
+			p.checkAttrs(npos, &a)
+			return sym.(*TSymbol).Type
 		}
 	}
-	if p.curt.Kind == '{' {
-		p.expect('{')
-		ret = &CStruct{}
-		for {
-			if p.curt.Kind == '}' {
-				break
+	p.expect('{')
+	v := int64(0)
+	var ty CType = CUInt
+	for {
+		name := p.curt
+		p.expect(cpp.IDENT)
+		p.Attributes(&a)
+		if p.curt.Kind == '=' {
+			p.next()
+			vexpr := p.CondExpr()
+			c, err := p.fold(vexpr)
+			if err != nil {
+				p.errorPos(vexpr.GetPos(), "%s", err)
 			}
-			_, basety := p.DeclSpecs()
-			for {
-				name, ty := p.Declarator(basety, false)
-				ret.Names = append(ret.Names, name.Val)
-				ret.Types = append(ret.Types, ty)
-				if p.curt.Kind == ',' {
-					p.next()
-					continue
-				}
-				break
+			i, ok := c.(*Constant)
+			if !ok || !IsIntType(i.Type) {
+				p.errorPos(vexpr.GetPos(), "enumerator value for %s is not an integer constant", name.Val)
 			}
-			p.expect(';')
+			v = i.Val
+			// Unsigned values too large for int64 appear negative.
+			if !IsSignedIntType(i.Type) && v < 0 {
+				p.errorPos(name.Pos, "enumerator value for %s is outside the range of int", name.Val)
+			}
+		}
+		if v != p.wrapInt(v, CInt) {
+			p.errorPos(name.Pos, "enumerator value for %s is outside the range of int", name.Val)
+		}
+		if v < 0 {
+			ty = CInt
+		}
+		err := p.decls.define(name.Val, &ESymbol{
+			Val: v,
+		})
+		if err != nil {
+			p.errorPos(name.Pos, "%s", err)
+		}
+		v += 1
+		if p.curt.Kind != ',' {
+			break
+		}
+		p.next()
+		if p.curt.Kind == '}' {
+			break
 		}
-		p.expect('}')
-		if sname != "" {
-			// TODO:
-			// If ret is nil, is this a predefine?
-			// Do we need an incomplete type?
+	}
+	p.expect('}')
+	p.Attributes(&a)
+	p.checkAttrs(npos, &a)
+	if tag != nil {
+		tag.Type = ty
+	}
+	return ty
+}
+
+// Parses the ": width" of a bit-field member, name is nil for
+// unnamed bit-fields.
+func (p *parser) bitfieldWidth(name *cpp.Token, ty CType) int {
+	pos := p.curt.Pos
+	p.expect(':')
+	if !IsIntType(ty) {
+		p.errorPos(pos, "bit-field has non-integer type")
+	}
+	wexpr := p.CondExpr()
+	c, err := p.fold(wexpr)
+	if err != nil {
+		p.errorPos(wexpr.GetPos(), "%s", err)
+	}
+	w, ok := c.(*Constant)
+	if !ok {
+		p.errorPos(wexpr.GetPos(), "bit-field width is not an integer constant")
+	}
+	maxw := int64(p.szdesc.GetSize(ty) * 8)
+	if ty == CBool {
+		maxw = 1
+	}
+	switch {
+	case w.Val < 0:
+		p.errorPos(pos, "bit-field has negative width")
+	case w.Val > maxw:
+		p.errorPos(pos, "width of bit-field exceeds its type")
+	case w.Val == 0 && name != nil:
+		p.errorPos(pos, "named bit-field %s has zero width", name.Val)
+	}
+	return int(w.Val)
+}
+
+// Parses a struct or union specifier.
+// Structs and unions share a single tag namespace. A tag which is
+// used before it is defined names an incomplete type, which the
+// definition later completes in place so existing pointers to it
+// see the members.
+func (p *parser) Struct() CType {
+	isUnion := p.curt.Kind == cpp.UNION
+	kind := "struct"
+	if isUnion {
+		kind = "union"
+	}
+	p.next()
+	// Attributes of the type may follow the keyword or the definition.
+	var sattrs attrs
+	p.Attributes(&sattrs)
+	var ret *CStruct
+	sname := ""
+	npos := p.curt.Pos
+	if p.curt.Kind != cpp.IDENT {
+		ret = &CStruct{IsUnion: isUnion}
+	} else {
+		sname = p.curt.Val
+		p.next()
+		var sym Symbol
+		var err error
+		if p.curt.Kind == '{' || p.curt.Kind == ';' {
+			// A definition or a bare "struct foo;" always refers
+			// to a type in the current scope, hiding any outer one.
+			sym, err = p.structs.lookupLocal(sname)
+		} else {
+			sym, err = p.structs.lookup(sname)
+		}
+		if err == nil {
+			var ok bool
+			ret, ok = sym.(*TSymbol).Type.(*CStruct)
+			if !ok || ret.IsUnion != isUnion {
+				p.errorPos(npos, "%s defined as the wrong kind of tag", sname)
+			}
+		} else {
+			ret = &CStruct{IsUnion: isUnion, Incomplete: true}
 			err := p.structs.define(sname, &TSymbol{
 				Type: ret,
 			})
 			if err != nil {
-				p.errorPos(npos, err.Error())
+				p.errorPos(npos, "%s", err)
+			}
+		}
+	}
+	if p.curt.Kind != '{' {
+		if sname == "" {
+			p.errorPos(npos, "expected a tag or '{' after %s", kind)
+		}
+		p.checkAttrs(npos, &sattrs)
+		return ret
+	}
+	if sname != "" && !ret.Incomplete {
+		p.errorPos(npos, "redefinition of %s %s", kind, sname)
+	}
+	p.expect('{')
+	// The last member may be an array without a size.
+	var flexible *cpp.Token
+	for {
+		if p.curt.Kind == '}' {
+			break
+		}
+		if p.curt.Kind == cpp.STATIC_ASSERT {
+			p.StaticAssert()
+			continue
+		}
+		pos := p.curt.Pos
+		p.untagged = nil
+		_, fs, mattrs, basety := p.DeclSpecs()
+		p.noFuncSpecs(pos, fs)
+		p.noAutoType(pos, mattrs)
+		if mattrs.alignas != 0 && mattrs.alignas < p.szdesc.GetAlign(basety) {
+			p.errorPos(pos, "_Alignas cannot reduce the alignment of a member")
+		}
+		if p.curt.Kind == ';' {
+			// A struct or union defined without a tag or a declarator
+			// is an anonymous member, whose members are accessed as
+			// if they were members of the enclosing struct or union.
+			if s, ok := Unqual(basety).(*CStruct); ok && s == p.untagged {
+				if flexible != nil {
+					p.errorPos(flexible.Pos, "flexible array member %s not at end of struct", flexible.Val)
+				}
+				for _, n := range memberNames(s) {
+					if ret.FieldPath(n) != nil {
+						p.errorPos(pos, "duplicate member %s", n)
+					}
+				}
+				p.checkAttrs(pos, mattrs, "aligned", "packed")
+				ret.Names = append(ret.Names, "")
+				ret.Types = append(ret.Types, basety)
+				ret.BitWidths = append(ret.BitWidths, -1)
+				ret.Aligns = append(ret.Aligns, p.memberAlign(basety, mattrs))
+				ret.PackedFields = append(ret.PackedFields, false)
+			} else {
+				p.warnPos(pos, "declaration does not declare anything")
+			}
+			p.next()
+			continue
+		}
+		for {
+			var name *cpp.Token
+			ty := basety
+			// Unnamed bit-fields have no declarator.
+			if p.curt.Kind != ':' {
+				name, ty = p.Declarator(basety, false)
+				if ret.FieldPath(name.Val) != nil {
+					p.errorPos(name.Pos, "duplicate member %s", name.Val)
+				}
+				if flexible != nil {
+					p.errorPos(flexible.Pos, "flexible array member %s not at end of struct", flexible.Val)
+				}
+				switch {
+				case isFlexibleArray(ty):
+					if isUnion {
+						p.errorPos(name.Pos, "flexible array member in union")
+					}
+					if !hasNamed(ret) {
+						p.errorPos(name.Pos, "flexible array member in a struct with no named members")
+					}
+					flexible = name
+				case IsIncomplete(ty):
+					p.errorPos(name.Pos, "field %s has incomplete type", name.Val)
+				case IsVariablyModified(ty):
+					p.errorPos(name.Pos, "field %s has variably modified type", name.Val)
+				default:
+					p.flexibleUse(name.Pos, ty)
+				}
+			}
+			width := -1
+			if p.curt.Kind == ':' {
+				width = p.bitfieldWidth(name, ty)
+			}
+			a := *mattrs
+			p.Attributes(&a)
+			p.checkAttrs(pos, &a, "aligned", "packed")
+			if width >= 0 && a.alignas != 0 {
+				p.errorPos(pos, "alignment specified for a bit-field")
+			}
+			sname := ""
+			if name != nil {
+				sname = name.Val
+			}
+			ret.Names = append(ret.Names, sname)
+			ret.Types = append(ret.Types, ty)
+			ret.BitWidths = append(ret.BitWidths, width)
+			if width >= 0 {
+				ret.Aligns = append(ret.Aligns, a.aligned)
+			} else {
+				ret.Aligns = append(ret.Aligns, p.memberAlign(ty, &a))
+			}
+			ret.PackedFields = append(ret.PackedFields, width >= 0 && a.packed)
+			if p.curt.Kind == ',' {
+				p.next()
+				continue
 			}
+			break
 		}
+		p.expect(';')
+	}
+	p.expect('}')
+	p.Attributes(&sattrs)
+	p.structAttrs(npos, ret, &sattrs)
+	ret.Incomplete = false
+	if sname == "" {
+		p.untagged = ret
 	}
 	return ret
 }
+
+func isFlexibleArray(ty CType) bool {
+	arr, ok := ty.(*Array)
+	return ok && arr.Incomplete && !IsIncomplete(arr.MemberType)
+}
+
+// Returns the names of the members of s, including
+// those of its anonymous members.
+func memberNames(s *CStruct) []string {
+	var names []string
+	for idx, n := range s.Names {
+		if s.IsAnonymous(idx) {
+			names = append(names, memberNames(Unqual(s.Types[idx]).(*CStruct))...)
+		} else if n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+func hasNamed(s *CStruct) bool {
+	for idx, n := range s.Names {
+		if n != "" || s.IsAnonymous(idx) {
+			return true
+		}
+	}
+	return false
+}
+
+// A struct with a flexible array member may only be an array element
+// or a member of another struct as a GNU extension. Returns ty.
+func (p *parser) flexibleUse(pos cpp.FilePos, ty CType) CType {
+	if s, ok := Unqual(ty).(*CStruct); ok && s.HasFlexibleArray() && !p.opts.GNU {
+		p.errorPos(pos, "invalid use of structure with flexible array member")
+	}
+	return ty
+}
@@ -0,0 +1,112 @@
+package parse
+
+// A simple reachability analysis of function bodies, used to find
+// functions which can fall off the end without returning a value.
+//
+// The analysis is conservative: any labeled statement is assumed to
+// be reachable, and loop conditions are only understood when they
+// are constants.
+
+// Reports whether execution can reach the end of the statement n.
+func canFallThrough(n Node) bool {
+	switch n := n.(type) {
+	case *Return, *Goto:
+		return false
+	case *ExprStmt:
+		return !isNoReturnCall(n.Expr)
+	case *Block:
+		return stmtsFallThrough(n.Body)
+	case *LabeledStmt:
+		return canFallThrough(n.Stmt)
+	case *If:
+		if n.Else == nil {
+			return true
+		}
+		return canFallThrough(n.Stmt) || canFallThrough(n.Else)
+	case *While:
+		return !isConstTrue(n.Cond) || hasJump(n.Body, n.LEnd)
+	case *For:
+		infinite := n.Cond == nil || isConstTrue(n.Cond)
+		return !infinite || hasJump(n.Body, n.LEnd)
+	case *DoWhile:
+		// The condition is only reached from the end of the body
+		// or a continue.
+		if hasJump(n.Body, n.LEnd) {
+			return true
+		}
+		reachesCond := canFallThrough(n.Body) || hasJump(n.Body, n.LCond)
+		return reachesCond && !isConstTrue(n.Cond)
+	case *Switch:
+		// Without a default, no case may match.
+		if n.LDefault == "" {
+			return true
+		}
+		return canFallThrough(n.Stmt) || hasJump(n.Stmt, n.LAfter)
+	}
+	return true
+}
+
+// Reports whether execution can reach the end of a list of statements.
+func stmtsFallThrough(stmts []Node) bool {
+	reachable := true
+	for _, stmt := range stmts {
+		if _, ok := stmt.(*LabeledStmt); ok {
+			reachable = true
+		}
+		if reachable {
+			reachable = canFallThrough(stmt)
+		}
+	}
+	return reachable
+}
+
+// Reports whether n calls a function declared _Noreturn,
+// or a builtin which never returns.
+func isNoReturnCall(n Expr) bool {
+	if b, ok := n.(*BuiltinCall); ok {
+		return b.Name == "__builtin_trap" || b.Name == "__builtin_unreachable"
+	}
+	call, ok := n.(*Call)
+	if !ok {
+		return false
+	}
+	ident, ok := call.FuncLike.(*Ident)
+	if !ok {
+		return false
+	}
+	gsym, ok := ident.Sym.(*GSymbol)
+	return ok && gsym.NoReturn
+}
+
+func isConstTrue(n Node) bool {
+	c, ok := n.(*Constant)
+	return ok && c.Val != 0
+}
+
+// Reports whether n contains a break out of the loop or switch
+// which ends at label, or a continue of the loop continuing at it.
+func hasJump(n Node, label string) bool {
+	switch n := n.(type) {
+	case *Goto:
+		return (n.IsBreak || n.IsCont) && n.Label == label
+	case *Block:
+		for _, stmt := range n.Body {
+			if hasJump(stmt, label) {
+				return true
+			}
+		}
+	case *LabeledStmt:
+		return hasJump(n.Stmt, label)
+	case *If:
+		return hasJump(n.Stmt, label) || (n.Else != nil && hasJump(n.Else, label))
+	case *While:
+		return hasJump(n.Body, label)
+	case *For:
+		return hasJump(n.Body, label)
+	case *DoWhile:
+		return hasJump(n.Body, label)
+	case *Switch:
+		return hasJump(n.Stmt, label)
+	}
+	return false
+}
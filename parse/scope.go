@@ -18,6 +18,15 @@ func (s *scope) lookup(k string) (Symbol, error) {
 	return nil, fmt.Errorf("%s is not defined", k)
 }
 
+// Like lookup, but ignores enclosing scopes.
+func (s *scope) lookupLocal(k string) (Symbol, error) {
+	sym, ok := s.kv[k]
+	if ok {
+		return sym, nil
+	}
+	return nil, fmt.Errorf("%s is not defined", k)
+}
+
 func (s *scope) define(k string, v Symbol) error {
 	_, ok := s.kv[k]
 	if ok {
@@ -48,12 +57,47 @@ type Symbol interface{}
 type GSymbol struct {
 	Label string
 	Type  CType
+	// Has internal linkage, or is a static local.
+	Internal bool
+	// A function whose file scope declarations are all inline.
+	InlineOnly bool
+	// A function declared _Noreturn.
+	NoReturn bool
+	// Alignment requested with _Alignas or attributes, or 0.
+	Align int
+	// Attributes of the symbol from all its declarations. An alias
+	// is defined as another name for the symbol Alias.
+	Weak         bool
+	Section      string
+	Visibility   string
+	Alias        string
+	Constructor  bool
+	Destructor   bool
+	CtorPriority int
+	DtorPriority int
+	// Referenced, or declared used or unused.
+	used bool
+	// How calls to the function are checked against its format string.
+	format *formatAttr
+	// The constant initializer of a defined object, nil if
+	// it is zero initialized.
+	Init Expr
 }
 
 type LSymbol struct {
 	Type CType
+	// Alignment requested with _Alignas or attributes, or 0.
+	Align int
+	// A function called with the address of the variable
+	// when it goes out of scope, or nil.
+	Cleanup *GSymbol
 }
 
 type TSymbol struct {
 	Type CType
 }
+
+// An enumeration constant.
+type ESymbol struct {
+	Val int64
+}
@@ -8,6 +8,16 @@ import (
 )
 
 func ReportError(err error) {
+	report(err)
+}
+
+// Warnings are reported the same way as errors, the
+// message itself says it is a warning.
+func ReportWarning(err error) {
+	report(err)
+}
+
+func report(err error) {
 	if err == nil {
 		return
 	}
@@ -17,55 +17,80 @@ import (
 var (
 	filter = flag.String("filter", ".*", "A regex filtering which tests to run")
 	cfg    = Config{
-		CompileCmd:  "x64cc -o {{.Out}} {{.In}}",
-		AssembleCmd: "as {{.In}} -o {{.Out}}",
-		LinkCmd:     "gcc {{.In}} -o {{.Out}}",
+		CompileCmd:     "x64cc {{.Flags}} -o {{.Out}} {{.In}}",
+		AssembleCmd:    "as {{.In}} -o {{.Out}}",
+		HostCompileCmd: "gcc -c {{.In}} -o {{.Out}}",
+		LinkCmd:        "gcc {{.In}} -o {{.Out}}",
 	}
 )
 
 type Config struct {
 	CompileCmd  string
 	AssembleCmd string
-	LinkCmd     string
+	// Compiles companion sources with the host compiler,
+	// used to check we agree with the host ABI.
+	HostCompileCmd string
+	LinkCmd        string
 }
 
-func (c Config) Compile(in, out string) error {
-	return RunWithInOutTemplate(in, out, c.CompileCmd, 5*time.Second)
+// Tests may specify extra compiler flags with a comment of the form
+// "// FLAGS: -flag".
+var flagsRe = regexp.MustCompile(`// FLAGS: (.*)`)
+
+func (c Config) Compile(in, out string) (string, error) {
+	src, err := ioutil.ReadFile(in)
+	if err != nil {
+		return "", err
+	}
+	flags := ""
+	match := flagsRe.FindSubmatch(src)
+	if match != nil {
+		flags = string(match[1])
+	}
+	return RunWithInOutTemplate(in, out, flags, c.CompileCmd, 5*time.Second)
 }
 
-func (c Config) Assemble(in, out string) error {
-	return RunWithInOutTemplate(in, out, c.AssembleCmd, 5*time.Second)
+func (c Config) Assemble(in, out string) (string, error) {
+	return RunWithInOutTemplate(in, out, "", c.AssembleCmd, 5*time.Second)
 }
 
-func (c Config) Link(in, out string) error {
-	return RunWithInOutTemplate(in, out, c.LinkCmd, 5*time.Second)
+func (c Config) HostCompile(in, out string) (string, error) {
+	return RunWithInOutTemplate(in, out, "", c.HostCompileCmd, 5*time.Second)
 }
 
-func RunWithInOutTemplate(in, out, templ string, timeout time.Duration) error {
-	data := struct{ In, Out string }{In: in, Out: out}
+func (c Config) Link(in, out string) (string, error) {
+	return RunWithInOutTemplate(in, out, "", c.LinkCmd, 5*time.Second)
+}
+
+func RunWithInOutTemplate(in, out, flags, templ string, timeout time.Duration) (string, error) {
+	data := struct{ In, Out, Flags string }{In: in, Out: out, Flags: flags}
 	t := template.New("gencmdline")
 	t, err := t.Parse(templ)
 	if err != nil {
-		return err
+		return "", err
 	}
 	var b bytes.Buffer
 	err = t.Execute(&b, data)
 	if err != nil {
-		return err
+		return "", err
 	}
 	cmdline := b.String()
 	return RunWithTimeout(cmdline, timeout)
 }
 
-// True on success, else fail.
-func RunWithTimeout(command string, timeout time.Duration) error {
-	args := strings.Split(command, " ")
+// Returns the combined stdout and stderr of the command.
+// err is nil on success, else fail.
+func RunWithTimeout(command string, timeout time.Duration) (string, error) {
+	args := strings.Fields(command)
 	if len(args) == 0 {
-		return fmt.Errorf("malformed command %s", command)
+		return "", fmt.Errorf("malformed command %s", command)
 	}
 	bin := args[0]
 	args = args[1:]
 	c := exec.Command(bin, args...)
+	var output bytes.Buffer
+	c.Stdout = &output
+	c.Stderr = &output
 	rc := make(chan error)
 	go func() {
 		err := c.Run()
@@ -75,13 +100,15 @@ func RunWithTimeout(command string, timeout time.Duration) error {
 	defer t.Stop()
 	select {
 	case <-t.C:
-		return fmt.Errorf("%s timed out", bin)
+		return "", fmt.Errorf("%s timed out", bin)
 	case err := <-rc:
-		return err
+		return output.String(), err
 	}
 }
 
 // Tests which are expected to run and return an error code true or false.
+// A test NNNN-name.c may have a companion NNNN-name.host.c which is
+// compiled by the host compiler and linked into the test binary.
 func ExecuteTests(tdir string) error {
 	fmt.Println("execute tests in", tdir)
 	passcount := 0
@@ -91,7 +118,7 @@ func ExecuteTests(tdir string) error {
 		panic(err)
 	}
 	for _, t := range tests {
-		if !strings.HasSuffix(t.Name(), ".c") {
+		if !strings.HasSuffix(t.Name(), ".c") || strings.HasSuffix(t.Name(), ".host.c") {
 			continue
 		}
 		tc := filepath.Join(tdir, t.Name())
@@ -106,22 +133,33 @@ func ExecuteTests(tdir string) error {
 		oname := tc + ".o"
 		bname := tc + ".bin"
 		runcount += 1
-		err = cfg.Compile(tc, sname)
+		_, err = cfg.Compile(tc, sname)
 		if err != nil {
 			fmt.Printf("FAIL: %s compile - %s\n", tc, err)
 			continue
 		}
-		err = cfg.Assemble(sname, oname)
+		_, err = cfg.Assemble(sname, oname)
 		if err != nil {
 			fmt.Printf("FAIL: %s assemble - %s\n", tc, err)
 			continue
 		}
-		err = cfg.Link(oname, bname)
+		objs := oname
+		hostc := strings.TrimSuffix(tc, ".c") + ".host.c"
+		if _, err := os.Stat(hostc); err == nil {
+			hosto := hostc + ".o"
+			_, err = cfg.HostCompile(hostc, hosto)
+			if err != nil {
+				fmt.Printf("FAIL: %s host compile - %s\n", tc, err)
+				continue
+			}
+			objs += " " + hosto
+		}
+		_, err = cfg.Link(objs, bname)
 		if err != nil {
 			fmt.Printf("FAIL: %s link - %s\n", tc, err)
 			continue
 		}
-		err = RunWithTimeout(bname, 5*time.Second)
+		_, err = RunWithTimeout(bname, 5*time.Second)
 		if err != nil {
 			fmt.Printf("FAIL: %s execute - %s\n", tc, err)
 			continue
@@ -135,6 +173,60 @@ func ExecuteTests(tdir string) error {
 	return nil
 }
 
+var expectedErrorRe = regexp.MustCompile(`// ERROR: (.*)`)
+
+// Tests which are expected to fail compilation.
+// Each test contains a comment of the form "// ERROR: message",
+// and the compiler output must contain the message.
+func ErrorTests(tdir string) error {
+	fmt.Println("error tests in", tdir)
+	passcount := 0
+	runcount := 0
+	tests, err := ioutil.ReadDir(tdir)
+	if err != nil {
+		panic(err)
+	}
+	for _, t := range tests {
+		if !strings.HasSuffix(t.Name(), ".c") {
+			continue
+		}
+		tc := filepath.Join(tdir, t.Name())
+		m, err := regexp.MatchString(*filter, tc)
+		if err != nil {
+			panic(err)
+		}
+		if !m {
+			continue
+		}
+		runcount += 1
+		src, err := ioutil.ReadFile(tc)
+		if err != nil {
+			panic(err)
+		}
+		match := expectedErrorRe.FindSubmatch(src)
+		if match == nil {
+			fmt.Printf("FAIL: %s has no expected error\n", tc)
+			continue
+		}
+		expected := strings.TrimSpace(string(match[1]))
+		out, err := cfg.Compile(tc, tc+".s")
+		if err == nil {
+			fmt.Printf("FAIL: %s compiled successfully\n", tc)
+			continue
+		}
+		if !strings.Contains(out, expected) {
+			fmt.Printf("FAIL: %s expected error '%s' got '%s'\n", tc, expected, strings.TrimSpace(out))
+			continue
+		}
+		fmt.Printf("PASS: %s\n", tc)
+		passcount += 1
+	}
+	if passcount != runcount {
+		return fmt.Errorf("passed %d/%d", passcount, runcount)
+	}
+	return nil
+}
+
 func main() {
 	flag.Parse()
 	pass := true
@@ -145,6 +237,13 @@ func main() {
 			pass = false
 		}
 	}
+	for _, tdir := range []string{"test/testcases/error"} {
+		err := ErrorTests(tdir)
+		if err != nil {
+			fmt.Printf("%s FAIL: %s\n", tdir, err)
+			pass = false
+		}
+	}
 	if !pass {
 		os.Exit(1)
 	}
@@ -0,0 +1,9 @@
+// ERROR: arithmetic on a pointer to void
+// FLAGS: -std=c11
+
+void *p;
+
+int main() {
+	p = p + 1;
+	return 0;
+}
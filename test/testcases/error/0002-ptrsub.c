@@ -0,0 +1,8 @@
+// ERROR: subtraction of incompatible pointer types
+
+int *p;
+char *q;
+
+int main() {
+	return p - q;
+}
@@ -0,0 +1,8 @@
+// ERROR: comparison of incompatible pointer types
+
+int *p;
+long *q;
+
+int main() {
+	return p < q;
+}
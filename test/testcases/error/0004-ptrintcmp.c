@@ -0,0 +1,7 @@
+// ERROR: comparison between pointer and integer
+
+int *p;
+
+int main() {
+	return p == 1;
+}
@@ -0,0 +1,8 @@
+// ERROR: expression is not assignable
+
+int x;
+
+int main() {
+	x + 1 = 2;
+	return 0;
+}
@@ -0,0 +1,8 @@
+// ERROR: expression is not assignable
+
+int x;
+
+int main() {
+	x++++;
+	return 0;
+}
@@ -0,0 +1,9 @@
+// ERROR: expression is not assignable
+
+int a[4];
+int b[4];
+
+int main() {
+	a += 1;
+	return 0;
+}
@@ -0,0 +1,5 @@
+// ERROR: invalid application of sizeof to a function type
+
+int main() {
+	return sizeof(main);
+}
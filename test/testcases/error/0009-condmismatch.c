@@ -0,0 +1,9 @@
+// ERROR: pointer type mismatch in conditional expression
+
+int *p;
+long *q;
+
+int main() {
+	p = 1 ? p : q;
+	return 0;
+}
@@ -0,0 +1,8 @@
+// ERROR: undefined symbol y
+
+int main() {
+	{
+		int y = 1;
+	}
+	return y;
+}
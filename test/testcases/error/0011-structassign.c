@@ -0,0 +1,14 @@
+// ERROR: incompatible types in assignment
+
+struct a { int x; };
+struct b { int x; };
+
+int
+main()
+{
+	struct a a;
+	struct b b;
+
+	a = b;
+	return 0;
+}
@@ -0,0 +1,16 @@
+// ERROR: va_start used in function with fixed arguments
+
+int
+f(int n)
+{
+	__builtin_va_list ap;
+
+	__builtin_va_start(ap, n);
+	return 0;
+}
+
+int
+main()
+{
+	return f(0);
+}
@@ -0,0 +1,9 @@
+// ERROR: invalid application of sizeof to an incomplete type
+
+struct s;
+
+int
+main()
+{
+	return sizeof(struct s);
+}
@@ -0,0 +1,22 @@
+// ERROR: member access into incomplete type
+
+struct s {
+	int a;
+};
+
+int
+main()
+{
+	struct s v;
+	struct s *p;
+
+	p = &v;
+	{
+		/* Declares a new, incomplete struct s hiding the outer one. */
+		struct s;
+		struct s *q;
+
+		q = 0;
+		return q->a;
+	}
+}
@@ -0,0 +1,10 @@
+// ERROR: variable v has incomplete type
+
+struct s;
+
+int
+main()
+{
+	struct s v;
+	return 0;
+}
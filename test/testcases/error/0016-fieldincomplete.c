@@ -0,0 +1,12 @@
+// ERROR: field self has incomplete type
+
+struct s {
+	int a;
+	struct s self;
+};
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,15 @@
+// ERROR: redefinition of struct s
+
+struct s {
+	int a;
+};
+
+struct s {
+	int b;
+};
+
+int
+main()
+{
+	return 0;
+}
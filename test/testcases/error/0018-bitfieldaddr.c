@@ -0,0 +1,14 @@
+// ERROR: cannot take the address of a bit-field
+
+struct s {
+	int a : 3;
+};
+
+int
+main()
+{
+	struct s v;
+	int *p;
+	p = &v.a;
+	return 0;
+}
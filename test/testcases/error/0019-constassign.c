@@ -0,0 +1,9 @@
+// ERROR: assignment to a const-qualified lvalue
+
+int
+main()
+{
+	const int x = 1;
+	x = 2;
+	return 0;
+}
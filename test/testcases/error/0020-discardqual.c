@@ -0,0 +1,11 @@
+// FLAGS: -Werror
+// ERROR: warning: conversion discards qualifiers from pointer target type
+
+int
+main()
+{
+	const int x = 1;
+	int *p;
+	p = &x;
+	return 0;
+}
@@ -0,0 +1,8 @@
+// ERROR: restrict requires a pointer type
+
+int
+main()
+{
+	restrict int x;
+	return 0;
+}
@@ -0,0 +1,15 @@
+// ERROR: assignment to a struct with a const member
+
+struct s {
+	int a;
+	const int b;
+};
+
+int
+main()
+{
+	struct s x;
+	struct s y;
+	x = y;
+	return 0;
+}
@@ -0,0 +1,7 @@
+// ERROR: integer constant 18446744073709551616 is too large
+
+int
+main()
+{
+	return 18446744073709551616;
+}
@@ -0,0 +1,7 @@
+// ERROR: invalid suffix on integer constant 1lul
+
+int
+main()
+{
+	return 1lul;
+}
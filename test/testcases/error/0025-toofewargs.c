@@ -0,0 +1,9 @@
+// ERROR: too few arguments to function call, expected 2, have 1
+
+int f(int a, int b);
+
+int
+main()
+{
+	return f(1);
+}
@@ -0,0 +1,9 @@
+// ERROR: too many arguments to function call, expected 0, have 1
+
+int f(void);
+
+int
+main()
+{
+	return f(1);
+}
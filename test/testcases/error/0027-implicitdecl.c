@@ -0,0 +1,8 @@
+// FLAGS: -std=c11
+// ERROR: implicit declaration of function f
+
+int
+main()
+{
+	return f(1);
+}
@@ -0,0 +1,11 @@
+// FLAGS: -Werror
+// ERROR: warning: incompatible pointer types in argument 1
+
+int f(long *p);
+
+int
+main()
+{
+	int x;
+	return f(&x);
+}
@@ -0,0 +1,10 @@
+// ERROR: conflicting types for f
+
+int f(int a);
+long f(int a);
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,15 @@
+// ERROR: declaration for parameter c but no such parameter
+
+int
+f(a, b)
+	int a;
+	int c;
+{
+	return a + b;
+}
+
+int
+main()
+{
+	return 0;
+}
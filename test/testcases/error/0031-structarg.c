@@ -0,0 +1,13 @@
+// ERROR: incompatible types in argument 1
+
+struct s {
+	int a;
+};
+
+int f(struct s v);
+
+int
+main()
+{
+	return f(1);
+}
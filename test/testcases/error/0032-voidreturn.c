@@ -0,0 +1,13 @@
+// ERROR: return with a value in function returning void
+
+void
+f()
+{
+	return 1;
+}
+
+int
+main()
+{
+	return 0;
+}
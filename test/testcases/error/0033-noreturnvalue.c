@@ -0,0 +1,14 @@
+// FLAGS: -std=c11
+// ERROR: return with no value in function returning non-void
+
+int
+f()
+{
+	return;
+}
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,18 @@
+// FLAGS: -Werror
+// ERROR: warning: control reaches end of non-void function f
+
+int
+f(int x)
+{
+	while (x) {
+		if (x > 10)
+			return 1;
+		x--;
+	}
+}
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,15 @@
+// ERROR: static declaration of f follows non-static declaration
+
+int f(void);
+
+static int
+f(void)
+{
+	return 0;
+}
+
+int
+main()
+{
+	return f();
+}
@@ -0,0 +1,11 @@
+// ERROR: redefinition of x
+
+int x = 1;
+int x;
+int x = 2;
+
+int
+main()
+{
+	return x;
+}
@@ -0,0 +1,10 @@
+// ERROR: conflicting types for x
+
+int x;
+long x;
+
+int
+main()
+{
+	return 0;
+}
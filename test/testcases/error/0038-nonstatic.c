@@ -0,0 +1,10 @@
+// ERROR: non-static declaration of x follows static declaration
+
+static int x;
+int x;
+
+int
+main()
+{
+	return x;
+}
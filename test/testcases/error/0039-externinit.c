@@ -0,0 +1,9 @@
+// ERROR: x has both extern and initializer
+
+int
+main()
+{
+	extern int x = 1;
+
+	return x;
+}
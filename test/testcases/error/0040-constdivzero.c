@@ -0,0 +1,9 @@
+// ERROR: division by zero in constant expression
+
+int a[4 / (2 - 2)];
+
+int
+main()
+{
+	return 0;
+}
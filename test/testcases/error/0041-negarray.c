@@ -0,0 +1,13 @@
+// ERROR: size of array is negative
+
+enum {
+	N = 2,
+};
+
+int
+main()
+{
+	int a[N - 3];
+
+	return 0;
+}
@@ -0,0 +1,12 @@
+// ERROR: enumerator value for B is outside the range of int
+
+enum {
+	A = 0x7fffffff,
+	B,
+};
+
+int
+main()
+{
+	return 0;
+}
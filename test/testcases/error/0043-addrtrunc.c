@@ -0,0 +1,10 @@
+// ERROR: initializer element is not computable at load time
+
+int x;
+int y = (int)&x;
+
+int
+main()
+{
+	return y;
+}
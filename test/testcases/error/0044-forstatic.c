@@ -0,0 +1,11 @@
+// ERROR: declaration of non-automatic variable in for loop initial declaration
+
+int
+main()
+{
+	int n = 0;
+
+	for (static int i = 0; i < 3; i++)
+		n++;
+	return n;
+}
@@ -0,0 +1,9 @@
+// ERROR: x declared inline but is not a function
+
+inline int x;
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,13 @@
+// ERROR: flexible array member data not at end of struct
+
+struct s {
+	int n;
+	int data[];
+	int m;
+};
+
+int
+main()
+{
+	return 0;
+}
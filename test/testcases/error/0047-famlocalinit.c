@@ -0,0 +1,13 @@
+// ERROR: non-static initialization of a flexible array member
+
+struct s {
+	int n;
+	int data[];
+};
+
+int
+main()
+{
+	struct s v = {1, {2, 3}};
+	return v.n;
+}
@@ -0,0 +1,9 @@
+// ERROR: excess elements in initializer
+
+int a[2] = {1, 2, 3};
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,13 @@
+// ERROR: unknown field z specified in initializer
+
+struct point {
+	int x;
+	int y;
+};
+
+int
+main()
+{
+	struct point p = {.x = 1, .z = 2};
+	return p.x;
+}
@@ -0,0 +1,9 @@
+// ERROR: initializer-string for array of chars is too long
+
+char s[2] = "abc";
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,10 @@
+// ERROR: storage size of a is not constant
+
+int
+main()
+{
+	int n;
+	n = 3;
+	static int a[n];
+	return 0;
+}
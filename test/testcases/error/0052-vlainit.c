@@ -0,0 +1,10 @@
+// ERROR: variable-sized object a may not be initialized
+
+int
+main()
+{
+	int n;
+	n = 3;
+	int a[n] = {1, 2, 3};
+	return a[0];
+}
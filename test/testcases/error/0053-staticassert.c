@@ -0,0 +1,9 @@
+// ERROR: static assertion failed: "long is too small"
+
+_Static_assert(sizeof(long) >= 16, "long is too small");
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,10 @@
+// ERROR: _Generic selector is not compatible with any association
+
+int
+main()
+{
+	long l;
+
+	l = 0;
+	return _Generic(l, int: 0, char *: 1);
+}
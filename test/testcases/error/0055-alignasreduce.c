@@ -0,0 +1,9 @@
+// ERROR: _Alignas cannot reduce the alignment of x
+
+_Alignas(2) long x;
+
+int
+main()
+{
+	return 0;
+}
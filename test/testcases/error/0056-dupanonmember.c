@@ -0,0 +1,15 @@
+// ERROR: duplicate member a
+
+struct s {
+	int a;
+	union {
+		long b;
+		int a;
+	};
+};
+
+int
+main()
+{
+	return 0;
+}
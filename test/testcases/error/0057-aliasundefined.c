@@ -0,0 +1,11 @@
+// ERROR: f aliased to undefined symbol g
+
+void g(void);
+void f(void) __attribute__((alias("g")));
+
+int
+main()
+{
+	f();
+	return 0;
+}
@@ -0,0 +1,9 @@
+// ERROR: weak declaration of x must be public
+
+static int x __attribute__((weak));
+
+int
+main()
+{
+	return x;
+}
@@ -0,0 +1,11 @@
+// ERROR: cleanup argument n is not a function
+
+int n;
+
+int
+main()
+{
+	int x __attribute__((cleanup(n))) = 0;
+
+	return x;
+}
@@ -0,0 +1,9 @@
+// ERROR: __auto_type declaration of x has no initializer
+
+int
+main()
+{
+	__auto_type x;
+
+	return 0;
+}
@@ -0,0 +1,9 @@
+// ERROR: statement expression outside a function
+
+int x = ({ 1; });
+
+int
+main()
+{
+	return x;
+}
@@ -0,0 +1,10 @@
+// ERROR: goto target missing is undefined
+
+int
+main()
+{
+	void *p;
+
+	p = &&missing;
+	goto *p;
+}
@@ -0,0 +1,10 @@
+// ERROR: output operand constraint lacks '='
+
+int
+main()
+{
+	int i;
+
+	asm("movl $1, %0" : "r"(i));
+	return i;
+}
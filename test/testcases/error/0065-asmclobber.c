@@ -0,0 +1,8 @@
+// ERROR: unknown register name foo in asm
+
+int
+main()
+{
+	asm("nop" : : : "foo");
+	return 0;
+}
@@ -0,0 +1,9 @@
+// ERROR: argument 3 of __builtin_add_overflow is not a pointer to a non-boolean integer
+
+int
+main()
+{
+	_Bool b;
+
+	return __builtin_add_overflow(1, 2, &b);
+}
@@ -0,0 +1,8 @@
+// ERROR: unclosed preprocessor conditional
+
+#if __has_builtin(__builtin_trap)
+int
+main()
+{
+	return 0;
+}
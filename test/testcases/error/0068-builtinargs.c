@@ -0,0 +1,10 @@
+// ERROR: too few arguments to function call, expected 3, have 2
+
+int
+main()
+{
+	int a[2];
+
+	__builtin_memset(a, 0);
+	return 0;
+}
@@ -0,0 +1,10 @@
+// ERROR: malformed defined check, missing ) in preprocessor conditional
+
+#if defined(FOO
+#endif
+
+int
+main()
+{
+	return 0;
+}
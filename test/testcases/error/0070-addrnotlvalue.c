@@ -0,0 +1,16 @@
+// ERROR: lvalue required as unary '&' operand
+
+int
+f(void)
+{
+	return 1;
+}
+
+int
+main()
+{
+	int *p;
+
+	p = &f();
+	return *p;
+}
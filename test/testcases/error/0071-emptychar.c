@@ -0,0 +1,7 @@
+// ERROR: empty character constant
+
+int
+main()
+{
+	return '';
+}
@@ -0,0 +1,16 @@
+// ERROR: duplicate case value
+
+int
+main()
+{
+	int x;
+
+	x = 0;
+	switch (x) {
+	case -1:
+		return 1;
+	case 4294967295u:
+		return 2;
+	}
+	return 0;
+}
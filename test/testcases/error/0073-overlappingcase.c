@@ -0,0 +1,16 @@
+// ERROR: duplicate (or overlapping) case value
+
+int
+main()
+{
+	int x;
+
+	x = 0;
+	switch (x) {
+	case 3:
+		return 1;
+	case 1 ... 5:
+		return 2;
+	}
+	return 0;
+}
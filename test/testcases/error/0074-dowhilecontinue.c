@@ -0,0 +1,18 @@
+// FLAGS: -Werror
+// ERROR: warning: control reaches end of non-void function f
+
+int
+f(int x)
+{
+	do {
+		if (x--)
+			continue;
+		return 1;
+	} while (x > 0);
+}
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,10 @@
+// ERROR: memory input 1 is not directly addressable
+
+int
+main()
+{
+	int x = 1, y;
+
+	asm("" : "=r"(y) : "m"(x + 1));
+	return y;
+}
@@ -0,0 +1,19 @@
+// ERROR: jump into scope of identifier with variably modified type
+
+int
+f(int n)
+{
+	goto l;
+	{
+		int a[n];
+	l:
+		a[0] = 1;
+		return a[0];
+	}
+}
+
+int
+main()
+{
+	return f(1) - 1;
+}
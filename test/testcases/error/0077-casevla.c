@@ -0,0 +1,21 @@
+// ERROR: switch jumps into scope of identifier with variably modified type
+
+int
+f(int n)
+{
+	switch (n) {
+	case 0:
+		return 0;
+		int a[n];
+	case 1:
+		a[0] = 1;
+		return a[0];
+	}
+	return -1;
+}
+
+int
+main()
+{
+	return f(1) - 1;
+}
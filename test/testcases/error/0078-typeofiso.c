@@ -0,0 +1,10 @@
+// FLAGS: -std=c11
+// ERROR: implicit declaration of function typeof
+
+int
+main()
+{
+	int x = 0;
+	typeof(x) y = 0;
+	return y;
+}
@@ -0,0 +1,9 @@
+// FLAGS: -std=c11
+// ERROR: implicit declaration of function asm
+
+int
+main()
+{
+	asm("nop");
+	return 0;
+}
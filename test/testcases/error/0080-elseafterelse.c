@@ -0,0 +1,15 @@
+// ERROR: #else after #else
+
+#if 0
+int x;
+#else
+int y;
+#else
+int z;
+#endif
+
+int
+main()
+{
+	return 0;
+}
@@ -0,0 +1,15 @@
+// ERROR: #elif after #else
+
+#if 1
+int x;
+#else
+int y;
+#elif 1
+int z;
+#endif
+
+int
+main()
+{
+	return 0;
+}
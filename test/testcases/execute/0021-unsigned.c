@@ -0,0 +1,51 @@
+
+unsigned char uc;
+unsigned short us;
+unsigned int ui;
+unsigned long ul;
+unsigned long long ull;
+
+int main() {
+	uc = 255;
+	if (uc / 2 != 127)
+		return 1;
+	if (uc >> 4 != 15)
+		return 2;
+	uc = uc + 1;
+	if (uc != 0)
+		return 3;
+	us = 65535;
+	if (us % 256 != 255)
+		return 4;
+	us = us * 2;
+	if (us != 65534)
+		return 5;
+	ui = (unsigned int)-1;
+	if (ui / 2 != 2147483647)
+		return 6;
+	if (ui % 10 != 5)
+		return 7;
+	if (ui >> 31 != 1)
+		return 8;
+	if (ui < 0)
+		return 9;
+	if (ui + 1 != 0)
+		return 10;
+	if ((ui << 4) >> 4 != 268435455)
+		return 11;
+	ul = (unsigned long)-1;
+	if (ul >> 63 != 1)
+		return 12;
+	if (ul / 3 != (ul >> 1) / 3 * 2 + 1)
+		return 13;
+	if (ul <= 0)
+		return 14;
+	ull = (unsigned long long)-2;
+	if (ull % 3 != 2)
+		return 15;
+	if (~ull != 1)
+		return 16;
+	if (-ui != 1)
+		return 17;
+	return 0;
+}
@@ -0,0 +1,43 @@
+
+char c;
+short s;
+int i;
+long l;
+unsigned int ui;
+unsigned long ul;
+
+int main() {
+	c = -1;
+	s = -1;
+	i = -1;
+	l = -1;
+	ui = 1;
+	ul = 1;
+	if (!(c < 0) || !(s < 0) || !(i < 0) || !(l < 0))
+		return 1;
+	if (!(c <= -1) || !(s >= -1) || !(i <= -1) || !(l >= -1))
+		return 2;
+	if (c > 0 || s >= 0 || i > 0 || l >= 0)
+		return 3;
+	// The usual arithmetic conversions make -1 unsigned here.
+	if (i < ui)
+		return 4;
+	if (!(i > ui))
+		return 5;
+	if (l < ul)
+		return 6;
+	if (!(i >= ul))
+		return 7;
+	// Signed long can represent every unsigned int.
+	if (!(l < ui))
+		return 8;
+	if (ui <= 0 || ul <= 0)
+		return 9;
+	if (!(ui >= 1) || !(ul <= 1))
+		return 10;
+	if (c != s || s != i || i != l)
+		return 11;
+	if (!(ui == ul))
+		return 12;
+	return 0;
+}
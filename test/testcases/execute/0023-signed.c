@@ -0,0 +1,46 @@
+
+signed char c;
+short s;
+int i;
+long l;
+long long ll;
+
+int main() {
+	c = 127;
+	c = c + 1;
+	if (c != -128)
+		return 1;
+	if (c / 2 != -64)
+		return 2;
+	if (c >> 1 != -64)
+		return 3;
+	s = 32767;
+	s = s + 1;
+	if (s != -32768)
+		return 4;
+	if (s % 10 != -8)
+		return 5;
+	i = -7;
+	if (i / 2 != -3)
+		return 6;
+	if (i % 2 != -1)
+		return 7;
+	if (i >> 1 != -4)
+		return 8;
+	if (i << 2 != -28)
+		return 9;
+	l = -1;
+	l = l << 40;
+	if (l >> 40 != -1)
+		return 10;
+	ll = -9;
+	if (ll / 4 != -2 || ll % 4 != -1)
+		return 11;
+	if ((i & 255) != 249 || (i | 8) != -7 || (i ^ -1) != 6)
+		return 12;
+	if (~i != 6)
+		return 13;
+	if (-c != 128)
+		return 14;
+	return 0;
+}
@@ -0,0 +1,35 @@
+
+int calls;
+
+int t() {
+	calls = calls + 1;
+	return 1;
+}
+
+int f() {
+	calls = calls + 1;
+	return 0;
+}
+
+int main() {
+	calls = 0;
+	if (f() && t())
+		return 1;
+	if (calls != 1)
+		return 2;
+	if (!(t() || f()))
+		return 3;
+	if (calls != 2)
+		return 4;
+	if (!(t() && t()))
+		return 5;
+	if (calls != 4)
+		return 6;
+	if (f() || f())
+		return 7;
+	if (calls != 6)
+		return 8;
+	if ((2 && 3) != 1 || (0 || 5) != 1 || (0 || 0) != 0)
+		return 9;
+	return 0;
+}
@@ -0,0 +1,62 @@
+
+struct s {
+	long a;
+	long b;
+};
+
+int arr[10];
+struct s sarr[4];
+char *str = "hello";
+
+int add(int a, int b) {
+	return a + b;
+}
+
+int main() {
+	int *p;
+	int *q;
+	long *lp;
+	struct s *sp;
+	int (*fp)(int, int);
+	p = arr;
+	*p = 1;
+	*(p + 1) = 2;
+	*(2 + p) = 3;
+	if (arr[0] != 1 || arr[1] != 2 || arr[2] != 3)
+		return 1;
+	q = arr + 9;
+	if (q - p != 9)
+		return 2;
+	if (p - q != -9)
+		return 3;
+	if (*(q - 7) != 3)
+		return 4;
+	if (!(p < q) || p > q || !(p <= q) || p >= q || !(p <= p) || !(q >= q))
+		return 5;
+	if (p == q || !(p != q) || p == 0 || !(p != 0))
+		return 6;
+	sp = sarr;
+	sp = sp + 3;
+	if (sp - sarr != 3)
+		return 7;
+	if ((char *)sp - (char *)sarr != 3 * 16)
+		return 8;
+	lp = 0;
+	if (lp != 0)
+		return 9;
+	lp = lp + 2;
+	if ((long)lp != 16)
+		return 10;
+	if (*(str + 4) != 111)
+		return 11;
+	fp = add;
+	if (fp(1, 2) != 3)
+		return 12;
+	fp = &add;
+	if ((*fp)(2, 2) != 4)
+		return 13;
+	p = &arr[5];
+	if (p - 5 != arr)
+		return 14;
+	return 0;
+}
@@ -0,0 +1,14 @@
+
+char buf[8];
+
+int main() {
+	void *p;
+	void *q;
+	p = buf;
+	q = p + 3;
+	if (q - p != 3)
+		return 1;
+	if ((char *)q != buf + 3)
+		return 2;
+	return 0;
+}
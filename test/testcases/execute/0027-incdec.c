@@ -0,0 +1,53 @@
+
+int arr[4];
+int calls;
+
+int idx() {
+	calls++;
+	return 1;
+}
+
+int main() {
+	int i;
+	int *p;
+	unsigned char uc;
+	long l;
+	i = 0;
+	if (i++ != 0)
+		return 1;
+	if (i != 1)
+		return 2;
+	if (++i != 2)
+		return 3;
+	if (i-- != 2)
+		return 4;
+	if (--i != 0)
+		return 5;
+	p = arr;
+	*p++ = 5;
+	*p++ = 6;
+	if (p - arr != 2 || arr[0] != 5 || arr[1] != 6)
+		return 6;
+	if (*--p != 6)
+		return 7;
+	if (++p != &arr[2])
+		return 8;
+	uc = 255;
+	uc++;
+	if (uc != 0)
+		return 9;
+	uc--;
+	if (uc != 255)
+		return 10;
+	l = -1;
+	++l;
+	if (l)
+		return 11;
+	arr[idx()]++;
+	if (calls != 1 || arr[1] != 7)
+		return 12;
+	--arr[idx()];
+	if (calls != 2 || arr[1] != 6)
+		return 13;
+	return 0;
+}
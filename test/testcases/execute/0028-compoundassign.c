@@ -0,0 +1,75 @@
+
+int arr[4];
+int calls;
+
+int idx() {
+	calls++;
+	return 2;
+}
+
+int main() {
+	int x;
+	unsigned int u;
+	char c;
+	long l;
+	int *p;
+	x = 10;
+	if ((x += 5) != 15)
+		return 1;
+	if ((x -= 3) != 12)
+		return 2;
+	if ((x *= 2) != 24)
+		return 3;
+	if ((x /= 5) != 4)
+		return 4;
+	if ((x %= 3) != 1)
+		return 5;
+	if ((x <<= 4) != 16)
+		return 6;
+	if ((x >>= 2) != 4)
+		return 7;
+	if ((x |= 3) != 7)
+		return 8;
+	if ((x &= 5) != 5)
+		return 9;
+	if ((x ^= 1) != 4)
+		return 10;
+	c = 100;
+	c += 100;
+	if (c != -56)
+		return 11;
+	u = 0;
+	u -= 1;
+	if (u != 4294967295)
+		return 12;
+	u >>= 28;
+	if (u != 15)
+		return 13;
+	x = -8;
+	x >>= 1;
+	if (x != -4)
+		return 14;
+	// The division is performed as unsigned.
+	x = -8;
+	x /= u;
+	if (x != 286331152)
+		return 15;
+	l = 1;
+	l <<= 40;
+	if (l >> 40 != 1)
+		return 16;
+	p = arr;
+	p += 3;
+	if (p - arr != 3)
+		return 17;
+	p -= 2;
+	if (p != &arr[1])
+		return 18;
+	arr[idx()] += 7;
+	if (calls != 1 || arr[2] != 7)
+		return 19;
+	arr[idx()] *= 3;
+	if (calls != 2 || arr[2] != 21)
+		return 20;
+	return 0;
+}
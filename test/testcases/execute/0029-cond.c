@@ -0,0 +1,46 @@
+
+int calls;
+int arr[3];
+
+int f(int v) {
+	calls++;
+	return v;
+}
+
+int main() {
+	int x;
+	unsigned int u;
+	int *p;
+	void *vp;
+	x = 1;
+	if ((x ? 2 : 3) != 2)
+		return 1;
+	x = 0;
+	if ((x ? 2 : 3) != 3)
+		return 2;
+	if ((1 ? f(1) : f(2)) != 1 || calls != 1)
+		return 3;
+	if ((0 ? f(1) : f(2)) != 2 || calls != 2)
+		return 4;
+	// Right associative.
+	x = 2;
+	if ((x == 1 ? 10 : x == 2 ? 20 : 30) != 20)
+		return 5;
+	// The usual arithmetic conversions apply to the result.
+	u = 1;
+	if ((x ? -1 : u) < 0)
+		return 6;
+	p = x ? arr : 0;
+	if (p != arr)
+		return 7;
+	p = x ? 0 : arr;
+	if (p != 0)
+		return 8;
+	vp = arr;
+	if ((x ? vp : p) != arr)
+		return 9;
+	x ? f(0) : f(1);
+	if (calls != 3)
+		return 10;
+	return 0;
+}
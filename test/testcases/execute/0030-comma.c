@@ -0,0 +1,24 @@
+
+int calls;
+
+int f() {
+	calls++;
+	return calls;
+}
+
+int main() {
+	int x;
+	int i;
+	int j;
+	x = (f(), f(), f());
+	if (x != 3 || calls != 3)
+		return 1;
+	for (i = 0, j = 10; i < j; i++, j--)
+		;
+	if (i != 5 || j != 5)
+		return 2;
+	x = (1, 2);
+	if (x != 2)
+		return 3;
+	return 0;
+}
@@ -0,0 +1,49 @@
+
+char c;
+short s;
+int i;
+long l;
+long long ll;
+int arr[10];
+int *p;
+char carr[3][5];
+int *parr[3];
+int (*pa)[3];
+
+int main() {
+	if (sizeof(char) != 1 || sizeof c != 1 || sizeof(unsigned char) != 1)
+		return 1;
+	if (sizeof(short) != 2 || sizeof s != 2)
+		return 2;
+	if (sizeof(int) != 4 || sizeof(i) != 4 || sizeof(unsigned) != 4)
+		return 3;
+	if (sizeof(long) != 8 || sizeof l != 8 || sizeof(long long) != 8 || sizeof ll != 8)
+		return 4;
+	if (sizeof(int *) != 8 || sizeof p != 8 || sizeof *p != 4)
+		return 5;
+	if (sizeof arr != 40 || sizeof(int[10]) != 40 || sizeof arr[0] != 4)
+		return 6;
+	// Arrays decay in arithmetic.
+	if (sizeof(arr + 0) != 8)
+		return 7;
+	if (sizeof carr != 15 || sizeof carr[0] != 5)
+		return 8;
+	// The operand is not evaluated.
+	i = 0;
+	if (sizeof(i++) != 4 || i != 0)
+		return 9;
+	// sizeof has type size_t which is unsigned.
+	if (sizeof(int) - 5 < 0)
+		return 10;
+	if (sizeof(c + c) != 4)
+		return 11;
+	if (_Alignof(char) != 1 || _Alignof(short) != 2 || _Alignof(int) != 4 || _Alignof(long) != 8)
+		return 12;
+	if (_Alignof(int[4]) != 4 || _Alignof(char *) != 8)
+		return 13;
+	if (__alignof__(l) != 8)
+		return 14;
+	if (sizeof parr != 24 || sizeof pa != 8 || sizeof *pa != 12)
+		return 15;
+	return 0;
+}
@@ -0,0 +1,36 @@
+
+int g = 3;
+
+int f(int a) {
+	int b = a * 2;
+	int c = b + 1, d = c + b;
+	return d;
+}
+
+int main() {
+	int x = 5;
+	char c = 300;
+	unsigned int u = -1;
+	long l = x;
+	int *p = &x;
+	int arr[4];
+	int *q = arr;
+	int y = g + x;
+	if (x != 5)
+		return 1;
+	if (c != 44)
+		return 2;
+	if (u != 4294967295)
+		return 3;
+	if (l != 5)
+		return 4;
+	if (*p != 5)
+		return 5;
+	if (q != arr)
+		return 6;
+	if (y != 8)
+		return 7;
+	if (f(2) != 9)
+		return 8;
+	return 0;
+}
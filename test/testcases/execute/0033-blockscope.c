@@ -0,0 +1,65 @@
+
+int main() {
+	int x = 1;
+	int total = 0;
+	int i;
+	{
+		int x = 2;
+		if (x != 2)
+			return 1;
+		{
+			int x = 3;
+			if (x != 3)
+				return 2;
+		}
+		if (x != 2)
+			return 3;
+	}
+	if (x != 1)
+		return 4;
+	for (i = 0; i < 3; i++) {
+		int y = i * 10;
+		int z = y + 1;
+		total = total + z;
+	}
+	if (total != 33)
+		return 5;
+	if (x) {
+		int a = 7;
+		int b = 8;
+		if (a + b != 15)
+			return 6;
+	} else {
+		int c = 9;
+		return c;
+	}
+	while (x < 3) {
+		int w = x;
+		x = w + 1;
+	}
+	if (x != 3)
+		return 7;
+	switch (x) {
+	case 3: {
+		int s = 4;
+		x = s;
+	}
+	}
+	if (x != 4)
+		return 8;
+	// Variables in disjoint blocks must not clobber live ones.
+	{
+		int a = 1;
+		{
+			int b = 2;
+			if (a != 1 || b != 2)
+				return 9;
+		}
+		{
+			int c = 3;
+			if (a != 1 || c != 3)
+				return 10;
+		}
+	}
+	return 0;
+}
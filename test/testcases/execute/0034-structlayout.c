@@ -0,0 +1,79 @@
+struct s1 { char a; int b; char c; };
+struct s2 { char a; long b; short c; };
+struct s3 { short a; char b; };
+struct s4 { char a; struct s1 b; char c[3]; int *d; };
+struct s5 { int a[3]; char b; };
+struct s6 { char a; struct s3 b[2]; long c; };
+union u1 { char a; int b; short c[3]; };
+struct s7 { char a; union u1 b; char c; };
+
+long hostlayout(int i);
+
+int
+main()
+{
+	int n;
+
+	n = 0;
+	if (hostlayout(n++) != sizeof(struct s1))
+		return n;
+	if (hostlayout(n++) != _Alignof(struct s1))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s1, b))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s1, c))
+		return n;
+	if (hostlayout(n++) != sizeof(struct s2))
+		return n;
+	if (hostlayout(n++) != _Alignof(struct s2))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s2, b))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s2, c))
+		return n;
+	if (hostlayout(n++) != sizeof(struct s3))
+		return n;
+	if (hostlayout(n++) != _Alignof(struct s3))
+		return n;
+	if (hostlayout(n++) != sizeof(struct s4))
+		return n;
+	if (hostlayout(n++) != _Alignof(struct s4))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s4, b))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s4, b.c))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s4, c))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s4, d))
+		return n;
+	if (hostlayout(n++) != sizeof(struct s5))
+		return n;
+	if (hostlayout(n++) != _Alignof(struct s5))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s5, b))
+		return n;
+	if (hostlayout(n++) != sizeof(struct s6))
+		return n;
+	if (hostlayout(n++) != _Alignof(struct s6))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s6, b[1].b))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s6, c))
+		return n;
+	if (hostlayout(n++) != sizeof(union u1))
+		return n;
+	if (hostlayout(n++) != _Alignof(union u1))
+		return n;
+	if (hostlayout(n++) != sizeof(struct s7))
+		return n;
+	if (hostlayout(n++) != _Alignof(struct s7))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s7, b))
+		return n;
+	if (hostlayout(n++) != __builtin_offsetof(struct s7, c))
+		return n;
+	if (hostlayout(n++) != sizeof(struct s4[3]))
+		return n;
+	return 0;
+}
@@ -0,0 +1,34 @@
+#include <stddef.h>
+
+struct s1 { char a; int b; char c; };
+struct s2 { char a; long b; short c; };
+struct s3 { short a; char b; };
+struct s4 { char a; struct s1 b; char c[3]; int *d; };
+struct s5 { int a[3]; char b; };
+struct s6 { char a; struct s3 b[2]; long c; };
+union u1 { char a; int b; short c[3]; };
+struct s7 { char a; union u1 b; char c; };
+
+static long layout[] = {
+	sizeof(struct s1), _Alignof(struct s1),
+	offsetof(struct s1, b), offsetof(struct s1, c),
+	sizeof(struct s2), _Alignof(struct s2),
+	offsetof(struct s2, b), offsetof(struct s2, c),
+	sizeof(struct s3), _Alignof(struct s3),
+	sizeof(struct s4), _Alignof(struct s4),
+	offsetof(struct s4, b), offsetof(struct s4, b.c),
+	offsetof(struct s4, c), offsetof(struct s4, d),
+	sizeof(struct s5), _Alignof(struct s5), offsetof(struct s5, b),
+	sizeof(struct s6), _Alignof(struct s6),
+	offsetof(struct s6, b[1].b), offsetof(struct s6, c),
+	sizeof(union u1), _Alignof(union u1),
+	sizeof(struct s7), _Alignof(struct s7),
+	offsetof(struct s7, b), offsetof(struct s7, c),
+	sizeof(struct s4[3]),
+};
+
+long
+hostlayout(int i)
+{
+	return layout[i];
+}
@@ -0,0 +1,57 @@
+struct s {
+	char a;
+	int b;
+	char c;
+	long d;
+};
+
+union u {
+	long l;
+	int i;
+	char c;
+};
+
+struct s gs;
+union u gu;
+
+int
+main()
+{
+	struct s ls;
+	struct s *p;
+	union u *up;
+
+	p = &gs;
+	p->a = 1;
+	p->b = 2;
+	p->c = 3;
+	p->d = 4;
+	if (gs.a != 1)
+		return 1;
+	if (gs.b != 2)
+		return 2;
+	if (gs.c != 3)
+		return 3;
+	if (gs.d != 4)
+		return 4;
+	if (p->b + p->d != 6)
+		return 5;
+	if ((char *)&p->b - (char *)p != 4)
+		return 6;
+	if ((char *)&p->d - (char *)p != 16)
+		return 7;
+	ls.a = 5;
+	ls.d = 6;
+	if (ls.a != 5 || ls.d != 6)
+		return 8;
+	up = &gu;
+	up->l = 0;
+	up->c = 7;
+	if (gu.i != 7)
+		return 9;
+	if ((char *)&gu.c != (char *)&gu.l)
+		return 10;
+	if (sizeof(union u) != 8)
+		return 11;
+	return 0;
+}
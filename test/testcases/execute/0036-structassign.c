@@ -0,0 +1,110 @@
+struct small {
+	char a;
+	int b;
+};
+
+struct big {
+	long a[10];
+	char c;
+};
+
+struct outer {
+	char x;
+	struct small s;
+	short y;
+};
+
+struct small gs;
+struct big gb;
+
+struct small
+mksmall(char a, int b)
+{
+	struct small s;
+
+	s.a = a;
+	s.b = b;
+	return s;
+}
+
+struct big
+mkbig(long base)
+{
+	struct big b;
+	int i;
+
+	for (i = 0; i < 10; i++)
+		b.a[i] = base + i;
+	b.c = 99;
+	return b;
+}
+
+long
+sumbig(struct big b)
+{
+	long t;
+	int i;
+
+	t = 0;
+	for (i = 0; i < 10; i++)
+		t += b.a[i];
+	b.a[0] = 1000;
+	return t + b.c;
+}
+
+int
+main()
+{
+	struct small s1;
+	struct small s2;
+	struct big b1;
+	struct big b2;
+	struct outer o;
+	int i;
+
+	s1.a = 1;
+	s1.b = 2;
+	s2 = s1;
+	if (s2.a != 1 || s2.b != 2)
+		return 1;
+	gs = s2;
+	if (gs.a != 1 || gs.b != 2)
+		return 2;
+	for (i = 0; i < 10; i++)
+		b1.a[i] = i;
+	b1.c = 9;
+	b2 = b1;
+	for (i = 0; i < 10; i++)
+		if (b2.a[i] != i)
+			return 3;
+	if (b2.c != 9)
+		return 4;
+	gb = b2 = b1;
+	if (gb.a[9] != 9 || gb.c != 9)
+		return 5;
+	o.s = s1;
+	o.x = 3;
+	o.y = 4;
+	if (o.s.a != 1 || o.s.b != 2 || o.x != 3 || o.y != 4)
+		return 6;
+	s2 = mksmall(5, 6);
+	if (s2.a != 5 || s2.b != 6)
+		return 7;
+	if (mksmall(7, 8).b != 8)
+		return 8;
+	b1 = mkbig(100);
+	if (b1.a[0] != 100 || b1.a[9] != 109 || b1.c != 99)
+		return 9;
+	if (sumbig(b1) != 1045 + 99)
+		return 10;
+	if (b1.a[0] != 100)
+		return 11;
+	if (sumbig(mkbig(0)) != 45 + 99)
+		return 12;
+	{
+		struct small s3 = s1;
+		if (s3.a != 1 || s3.b != 2)
+			return 13;
+	}
+	return 0;
+}
@@ -0,0 +1,116 @@
+struct s1 { char a; int b; };
+struct s2 { long a; long b; };
+struct s3 { int a[3]; };
+struct s4 { char c[3]; };
+struct big { long a[5]; };
+struct mixed { double d; long l; };
+
+struct s1 host_mks1(char a, int b);
+int host_checks1(struct s1 s, char a, int b);
+struct s2 host_mks2(long a, long b);
+int host_checks2(struct s2 s, long a, long b);
+struct s3 host_mks3(int a, int b, int c);
+int host_checks3(struct s3 s, int a, int b, int c);
+struct s4 host_mks4(char a, char b, char c);
+int host_checks4(struct s4 s, char a, char b, char c);
+struct big host_mkbig(long base);
+int host_checkbig(int x, struct big s, long base);
+struct mixed host_mkmixed(long l);
+int host_checkmixed(struct mixed m, long l);
+int host_checkmany(struct s2 a, struct s2 b, struct s2 c, struct s2 d, long e);
+int host_callcc();
+
+struct s3
+cc_mks3(int a, int b, int c)
+{
+	struct s3 s;
+
+	s.a[0] = a;
+	s.a[1] = b;
+	s.a[2] = c;
+	return s;
+}
+
+int
+cc_checkbig(struct big b, long base)
+{
+	return b.a[0] == base && b.a[4] == base + 4;
+}
+
+struct big
+cc_mkbig(long base)
+{
+	struct big b;
+	int i;
+
+	for (i = 0; i < 5; i++)
+		b.a[i] = base + i;
+	return b;
+}
+
+struct s1
+cc_ids1(struct s1 s)
+{
+	return s;
+}
+
+int
+main()
+{
+	struct s1 a;
+	struct s2 b;
+	struct s3 c;
+	struct s4 d;
+	struct big e;
+	struct mixed f;
+	struct s2 m1;
+	struct s2 m2;
+	struct s2 m3;
+	struct s2 m4;
+
+	a = host_mks1(1, 2);
+	if (a.a != 1 || a.b != 2)
+		return 1;
+	if (!host_checks1(a, 1, 2))
+		return 2;
+	b = host_mks2(3, 4);
+	if (b.a != 3 || b.b != 4)
+		return 3;
+	if (!host_checks2(b, 3, 4))
+		return 4;
+	c = host_mks3(5, 6, 7);
+	if (c.a[0] != 5 || c.a[1] != 6 || c.a[2] != 7)
+		return 5;
+	if (!host_checks3(c, 5, 6, 7))
+		return 6;
+	d = host_mks4(8, 9, 10);
+	if (d.c[0] != 8 || d.c[1] != 9 || d.c[2] != 10)
+		return 7;
+	if (!host_checks4(d, 8, 9, 10))
+		return 8;
+	e = host_mkbig(11);
+	if (e.a[0] != 11 || e.a[4] != 15)
+		return 9;
+	if (!host_checkbig(7, e, 11))
+		return 10;
+	f = host_mkmixed(12);
+	if (f.l != 12)
+		return 11;
+	if (!host_checkmixed(f, 12))
+		return 12;
+	if (!host_checkmixed(host_mkmixed(13), 13))
+		return 13;
+	m1.a = 1;
+	m1.b = 2;
+	m2.a = 3;
+	m2.b = 4;
+	m3.a = 5;
+	m3.b = 6;
+	m4.a = 7;
+	m4.b = 8;
+	if (!host_checkmany(m1, m2, m3, m4, 9))
+		return 14;
+	if (!host_callcc())
+		return 15;
+	return 0;
+}
@@ -0,0 +1,52 @@
+struct s1 { char a; int b; };
+struct s2 { long a; long b; };
+struct s3 { int a[3]; };
+struct s4 { char c[3]; };
+struct big { long a[5]; };
+struct mixed { double d; long l; };
+
+struct s1 host_mks1(char a, int b) { struct s1 s = {a, b}; return s; }
+int host_checks1(struct s1 s, char a, int b) { return s.a == a && s.b == b; }
+
+struct s2 host_mks2(long a, long b) { struct s2 s = {a, b}; return s; }
+int host_checks2(struct s2 s, long a, long b) { return s.a == a && s.b == b; }
+
+struct s3 host_mks3(int a, int b, int c) { struct s3 s = {{a, b, c}}; return s; }
+int host_checks3(struct s3 s, int a, int b, int c) { return s.a[0] == a && s.a[1] == b && s.a[2] == c; }
+
+struct s4 host_mks4(char a, char b, char c) { struct s4 s = {{a, b, c}}; return s; }
+int host_checks4(struct s4 s, char a, char b, char c) { return s.c[0] == a && s.c[1] == b && s.c[2] == c; }
+
+struct big host_mkbig(long base) { struct big s = {{base, base + 1, base + 2, base + 3, base + 4}}; return s; }
+int host_checkbig(int x, struct big s, long base) { return x == 7 && s.a[0] == base && s.a[4] == base + 4; }
+
+struct mixed host_mkmixed(long l) { struct mixed m = {l * 0.5, l}; return m; }
+int host_checkmixed(struct mixed m, long l) { return m.d == l * 0.5 && m.l == l; }
+
+/* The fourth struct no longer fits in registers and goes on the stack. */
+int host_checkmany(struct s2 a, struct s2 b, struct s2 c, struct s2 d, long e)
+{
+	return a.a == 1 && b.b == 4 && c.a == 5 && d.a == 7 && d.b == 8 && e == 9;
+}
+
+struct s3 cc_mks3(int a, int b, int c);
+int cc_checkbig(struct big b, long base);
+struct big cc_mkbig(long base);
+struct s1 cc_ids1(struct s1 s);
+
+int host_callcc(void)
+{
+	struct s3 s = cc_mks3(1, 2, 3);
+	struct big b = cc_mkbig(10);
+	struct s1 x = {4, 5};
+	struct s1 y;
+
+	if (s.a[0] != 1 || s.a[1] != 2 || s.a[2] != 3)
+		return 0;
+	if (b.a[0] != 10 || b.a[4] != 14)
+		return 0;
+	if (!cc_checkbig(b, 10))
+		return 0;
+	y = cc_ids1(x);
+	return y.a == 4 && y.b == 5;
+}
@@ -0,0 +1,91 @@
+struct s2 { long a; long b; };
+struct big { long a[4]; };
+
+long host_many(long a, long b, long c, long d, long e, long f, long g, long h, long i, long j);
+int host_mixed(char a, short b, int c, long d, unsigned char e, unsigned short f,
+               unsigned int g, long h, char i, short j);
+long host_structs(long a, struct s2 b, long c, struct big d, long e, long f, struct s2 g, long h, long i);
+int host_aligned(void);
+long host_sumv(int n, ...);
+int host_callcc(void);
+int host_calleesaved(void);
+int snprintf(char *buf, unsigned long n, char *fmt, ...);
+int strcmp(char *a, char *b);
+
+long
+cc_many(long a, long b, long c, long d, long e, long f, long g, long h, long i, long j)
+{
+	return a + 2*b + 3*c + 4*d + 5*e + 6*f + 7*g + 8*h + 9*i + 10*j;
+}
+
+long
+cc_structs(long a, struct s2 b, long c, struct big d, long e, long f, struct s2 g, long h, long i)
+{
+	return a + b.a * 10 + b.b * 100 + c * 1000 + d.a[0] + d.a[3] * 2 + e * 3
+	    + f * 4 + g.a * 5 + g.b * 6 + h * 7 + i * 8;
+}
+
+long
+cc_clobber(void)
+{
+	long one;
+	long two;
+	long three;
+
+	one = 1;
+	two = 2;
+	three = 3;
+	if (!host_aligned())
+		return 0;
+	return host_many(1, 2, 3, 4, 5, 6, 7, 8, 9, 10) + host_sumv(3, one, two, three);
+}
+
+int
+main()
+{
+	unsigned int u;
+	struct s2 b;
+	struct big d;
+	struct s2 g;
+	char buf[64];
+	long v1;
+	long v2;
+	long v3;
+
+	if (host_many(1, 2, 3, 4, 5, 6, 7, 8, 9, 10) != 385)
+		return 1;
+	u = 4000000000;
+	if (!host_mixed(-1, -2, -3, -4, 250, 65000, u, 8, -9, -10))
+		return 2;
+	b.a = 2;
+	b.b = 3;
+	d.a[0] = 5;
+	d.a[3] = 8;
+	g.a = 11;
+	g.b = 12;
+	if (host_structs(1, b, 4, d, 9, 10, g, 13, 14) != cc_structs(1, b, 4, d, 9, 10, g, 13, 14))
+		return 3;
+	if (host_structs(1, b, 4, d, 9, 10, g, 13, 14) != 1 + 20 + 300 + 4000 + 5 + 16 + 27 + 40 + 55 + 72 + 91 + 112)
+		return 4;
+	if (!host_aligned())
+		return 5;
+	if (1 + host_aligned() != 2)
+		return 6;
+	if (host_many(1, 2, host_aligned() * 3, 4, 5, 6, 7, 8, 9 * host_aligned(), 10) != 385)
+		return 7;
+	if (host_many(1, 2, 3, 4, 5, 6, 7, 8, 9, host_aligned()) != 385 - 90)
+		return 8;
+	v1 = 1;
+	v2 = 2;
+	v3 = 3;
+	if (host_sumv(3, v1, v2, v3) != 14)
+		return 9;
+	snprintf(buf, 64, "%d %d %d %d %d %d %d %s", 1, 2, 3, 4, 5, 6, 7, "x");
+	if (strcmp(buf, "1 2 3 4 5 6 7 x") != 0)
+		return 10;
+	if (!host_callcc())
+		return 11;
+	if (!host_calleesaved())
+		return 12;
+	return 0;
+}
@@ -0,0 +1,101 @@
+#include <stdarg.h>
+
+struct s2 { long a; long b; };
+struct big { long a[4]; };
+
+long
+host_many(long a, long b, long c, long d, long e, long f, long g, long h, long i, long j)
+{
+	return a + 2*b + 3*c + 4*d + 5*e + 6*f + 7*g + 8*h + 9*i + 10*j;
+}
+
+int
+host_mixed(char a, short b, int c, long d, unsigned char e, unsigned short f,
+           unsigned int g, long h, char i, short j)
+{
+	return a == -1 && b == -2 && c == -3 && d == -4 && e == 250 && f == 65000
+	    && g == 4000000000u && h == 8 && i == -9 && j == -10;
+}
+
+long
+host_structs(long a, struct s2 b, long c, struct big d, long e, long f, struct s2 g, long h, long i)
+{
+	return a + b.a * 10 + b.b * 100 + c * 1000 + d.a[0] + d.a[3] * 2 + e * 3
+	    + f * 4 + g.a * 5 + g.b * 6 + h * 7 + i * 8;
+}
+
+int
+host_aligned(void)
+{
+	return ((long)__builtin_frame_address(0) & 15) == 0;
+}
+
+long
+host_sumv(int n, ...)
+{
+	va_list ap;
+	long t = 0;
+	int i;
+
+	va_start(ap, n);
+	for (i = 0; i < n; i++)
+		t += va_arg(ap, long) * (i + 1);
+	va_end(ap);
+	return t;
+}
+
+long cc_many(long a, long b, long c, long d, long e, long f, long g, long h, long i, long j);
+long cc_structs(long a, struct s2 b, long c, struct big d, long e, long f, struct s2 g, long h, long i);
+long cc_clobber(void);
+
+int
+host_callcc(void)
+{
+	struct s2 b = {2, 3};
+	struct big d = {{5, 6, 7, 8}};
+	struct s2 g = {11, 12};
+
+	if (cc_many(1, 2, 3, 4, 5, 6, 7, 8, 9, 10) != 385)
+		return 0;
+	return cc_structs(1, b, 4, d, 9, 10, g, 13, 14)
+	    == host_structs(1, b, 4, d, 9, 10, g, 13, 14);
+}
+
+/* Checks cc_clobber preserves the callee saved registers. */
+int
+host_calleesaved(void)
+{
+	long r;
+
+	__asm__ volatile(
+	    "pushq %%rbp\n\t"
+	    "movq %%rsp, %%rbp\n\t"
+	    "andq $-16, %%rsp\n\t"
+	    "movq $11, %%rbx\n\t"
+	    "movq $12, %%r12\n\t"
+	    "movq $13, %%r13\n\t"
+	    "movq $14, %%r14\n\t"
+	    "movq $15, %%r15\n\t"
+	    "call cc_clobber\n\t"
+	    "cmpq $11, %%rbx\n\t"
+	    "jne 1f\n\t"
+	    "cmpq $12, %%r12\n\t"
+	    "jne 1f\n\t"
+	    "cmpq $13, %%r13\n\t"
+	    "jne 1f\n\t"
+	    "cmpq $14, %%r14\n\t"
+	    "jne 1f\n\t"
+	    "cmpq $15, %%r15\n\t"
+	    "jne 1f\n\t"
+	    "jmp 2f\n"
+	    "1:\n\t"
+	    "movq $-1, %%rax\n"
+	    "2:\n\t"
+	    "movq %%rbp, %%rsp\n\t"
+	    "popq %%rbp\n\t"
+	    : "=a"(r)
+	    :
+	    : "rbx", "rcx", "rdx", "rsi", "rdi", "r8", "r9", "r10", "r11",
+	      "r12", "r13", "r14", "r15", "memory", "cc");
+	return r == 385 + 14;
+}
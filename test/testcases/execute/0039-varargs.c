@@ -0,0 +1,153 @@
+typedef __builtin_va_list va_list;
+
+struct pair {
+	long a;
+	long b;
+};
+
+struct big {
+	long a[3];
+};
+
+struct dbl {
+	double d;
+};
+
+int vsnprintf(char *buf, unsigned long n, char *fmt, va_list ap);
+int strcmp(char *a, char *b);
+int host_callcc(void);
+int host_checkdbl(struct dbl d, int i);
+
+long
+sum(int n, ...)
+{
+	va_list ap;
+	long t;
+	int i;
+
+	t = 0;
+	__builtin_va_start(ap, n);
+	for (i = 0; i < n; i++)
+		t += __builtin_va_arg(ap, int);
+	__builtin_va_end(ap);
+	return t;
+}
+
+long
+vsuml(int n, va_list ap)
+{
+	long t;
+
+	t = 0;
+	while (n--)
+		t = t * 10 + __builtin_va_arg(ap, long);
+	return t;
+}
+
+long
+twice(int n, ...)
+{
+	va_list ap;
+	va_list ap2;
+	long t;
+
+	__builtin_va_start(ap, n);
+	__builtin_va_copy(ap2, ap);
+	t = vsuml(n, ap);
+	t = t + vsuml(n, ap2);
+	__builtin_va_end(ap);
+	__builtin_va_end(ap2);
+	return t;
+}
+
+long
+structs(int n, ...)
+{
+	va_list ap;
+	struct pair p;
+	struct big b;
+	char *s;
+
+	__builtin_va_start(ap, n);
+	p = __builtin_va_arg(ap, struct pair);
+	b = __builtin_va_arg(ap, struct big);
+	s = __builtin_va_arg(ap, char *);
+	__builtin_va_end(ap);
+	return p.a + p.b * 10 + b.a[0] * 100 + b.a[2] * 1000 + s[0];
+}
+
+int
+format(char *buf, char *fmt, ...)
+{
+	va_list ap;
+	int r;
+
+	__builtin_va_start(ap, fmt);
+	r = vsnprintf(buf, 64, fmt, ap);
+	__builtin_va_end(ap);
+	return r;
+}
+
+/* Called by the host with doubles and ints interleaved. */
+int
+cc_mixed(int n, ...)
+{
+	va_list ap;
+	struct dbl d;
+	int i;
+	int ok;
+
+	ok = 1;
+	__builtin_va_start(ap, n);
+	for (i = 0; i < n; i++) {
+		d = __builtin_va_arg(ap, struct dbl);
+		if (!host_checkdbl(d, i))
+			ok = 0;
+		if (__builtin_va_arg(ap, int) != i)
+			ok = 0;
+	}
+	__builtin_va_end(ap);
+	return ok;
+}
+
+int
+main()
+{
+	char c;
+	short s;
+	long l1;
+	long l2;
+	long l3;
+	struct pair p;
+	struct big b;
+	char buf[64];
+
+	if (sum(0) != 0)
+		return 1;
+	if (sum(3, 1, 2, 3) != 6)
+		return 2;
+	/* More arguments than registers. */
+	if (sum(10, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10) != 55)
+		return 3;
+	c = -2;
+	s = -3;
+	if (sum(2, c, s) != -5)
+		return 4;
+	l1 = 1;
+	l2 = 2;
+	l3 = 3;
+	if (twice(3, l1, l2, l3) != 246)
+		return 5;
+	p.a = 1;
+	p.b = 2;
+	b.a[0] = 3;
+	b.a[2] = 4;
+	if (structs(3, p, b, "a") != 1 + 20 + 300 + 4000 + 97)
+		return 6;
+	format(buf, "%d-%s-%ld", 12, "ab", l3);
+	if (strcmp(buf, "12-ab-3") != 0)
+		return 8;
+	if (!host_callcc())
+		return 9;
+	return 0;
+}
@@ -0,0 +1,19 @@
+struct dbl {
+	double d;
+};
+
+int cc_mixed(int n, ...);
+
+int
+host_checkdbl(struct dbl d, int i)
+{
+	return d.d == i + 0.5;
+}
+
+int
+host_callcc(void)
+{
+	return cc_mixed(3, 0.5, 0, 1.5, 1, 2.5, 2)
+	    && cc_mixed(10, 0.5, 0, 1.5, 1, 2.5, 2, 3.5, 3, 4.5, 4, 5.5, 5,
+	                6.5, 6, 7.5, 7, 8.5, 8, 9.5, 9);
+}
@@ -0,0 +1,104 @@
+struct node {
+	int v;
+	struct node *next;
+};
+
+struct a;
+struct b {
+	struct a *a;
+	int v;
+};
+struct a {
+	struct b *b;
+	int v;
+};
+
+/* An opaque handle, only ever used through pointers. */
+struct handle;
+
+struct handle *
+passthrough(struct handle *h)
+{
+	return h;
+}
+
+struct s {
+	int x;
+};
+
+struct late *lp;
+struct late {
+	long a;
+	long b;
+};
+
+struct late gl;
+
+int
+inner()
+{
+	struct s {
+		long x;
+		long y;
+	};
+	struct s v;
+
+	v.y = 3;
+	return sizeof(v) + v.y;
+}
+
+int
+hidden()
+{
+	struct s;
+	struct s {
+		char c;
+	};
+
+	return sizeof(struct s);
+}
+
+int
+main()
+{
+	struct node n1;
+	struct node n2;
+	struct node n3;
+	struct node *p;
+	struct a a;
+	struct b b;
+	int t;
+
+	n1.v = 1;
+	n1.next = &n2;
+	n2.v = 2;
+	n2.next = &n3;
+	n3.v = 3;
+	n3.next = 0;
+	t = 0;
+	for (p = &n1; p; p = p->next)
+		t += p->v;
+	if (t != 6)
+		return 1;
+	a.b = &b;
+	b.a = &a;
+	a.v = 4;
+	b.v = 5;
+	if (a.b->a->b->v != 5)
+		return 2;
+	if (passthrough((struct handle *)&a) != (struct handle *)&a)
+		return 3;
+	if (sizeof(struct s) != 4)
+		return 4;
+	if (inner() != 19)
+		return 5;
+	if (hidden() != 1)
+		return 6;
+	if (sizeof(struct s) != 4)
+		return 7;
+	lp = &gl;
+	lp->b = 7;
+	if (gl.b != 7 || sizeof(*lp) != 16)
+		return 8;
+	return 0;
+}
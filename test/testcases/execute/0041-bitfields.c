@@ -0,0 +1,132 @@
+struct flags {
+	unsigned int a : 1;
+	unsigned int b : 3;
+	int c : 5;
+	unsigned int : 0;
+	unsigned int d : 7;
+	int : 4;
+	long e : 40;
+	char f;
+};
+
+struct packed {
+	char a;
+	int b : 12;
+	short c : 4;
+};
+
+union ubits {
+	unsigned int a : 5;
+	int b : 3;
+	char c;
+};
+
+struct wide {
+	unsigned long a : 63;
+	unsigned char b : 1;
+};
+
+int sizeofflags();
+int alignofflags();
+int sizeofpacked();
+int alignofpacked();
+int sizeofubits();
+int sizeofwide();
+int checkflags(struct flags *f);
+void setflags(struct flags *f);
+
+int
+main()
+{
+	struct flags f;
+	struct flags g;
+	struct packed p;
+	union ubits u;
+	struct wide w;
+	int i;
+	char *bytes;
+
+	if (sizeof(struct flags) != sizeofflags())
+		return 1;
+	if (_Alignof(struct flags) != alignofflags())
+		return 2;
+	if (sizeof(struct packed) != sizeofpacked())
+		return 3;
+	if (_Alignof(struct packed) != alignofpacked())
+		return 4;
+	if (sizeof(union ubits) != sizeofubits())
+		return 5;
+	if (sizeof(struct wide) != sizeofwide())
+		return 6;
+
+	bytes = (char *)&f;
+	for (i = 0; i < sizeof(f); i++)
+		bytes[i] = 0;
+	f.a = 1;
+	f.b = 5;
+	f.c = -3;
+	f.d = 100;
+	f.e = -1234567890;
+	f.f = 7;
+	if (!checkflags(&f))
+		return 7;
+	if (f.a != 1 || f.b != 5 || f.c != -3 || f.d != 100)
+		return 8;
+	if (f.e != -1234567890 || f.f != 7)
+		return 9;
+
+	setflags(&g);
+	if (g.a != 0 || g.b != 7 || g.c != -16 || g.d != 127)
+		return 10;
+	if (g.e != 549755813887 || g.f != 1)
+		return 11;
+
+	/* Stores truncate to the field width. */
+	f.b = 9;
+	if (f.b != 1)
+		return 12;
+	if ((f.c = 17) != -15)
+		return 13;
+	if (f.a != 1 || f.d != 100)
+		return 14;
+
+	/* Unsigned fields narrower than int promote to int. */
+	if (f.b - 2 >= 0)
+		return 15;
+
+	f.b = 7;
+	f.b++;
+	if (f.b != 0)
+		return 16;
+	if (f.b-- != 0)
+		return 17;
+	if (f.b != 7)
+		return 18;
+	f.c = 15;
+	f.c += 1;
+	if (f.c != -16)
+		return 19;
+	f.d |= 3;
+	if (f.d != 103)
+		return 20;
+	if (f.a != 1 || f.e != -1234567890 || f.f != 7)
+		return 21;
+
+	p.a = 1;
+	p.b = -1000;
+	p.c = 7;
+	if (p.a != 1 || p.b != -1000 || p.c != 7)
+		return 22;
+
+	u.c = 0;
+	u.a = 30;
+	if (u.a != 30 || u.b != -2)
+		return 23;
+
+	w.a = 1;
+	w.b = 1;
+	w.a -= 2;
+	if (w.a != 9223372036854775807 || w.b != 1)
+		return 24;
+	return 0;
+}
@@ -0,0 +1,52 @@
+struct flags {
+	unsigned int a : 1;
+	unsigned int b : 3;
+	int c : 5;
+	unsigned int : 0;
+	unsigned int d : 7;
+	int : 4;
+	long e : 40;
+	char f;
+};
+
+struct packed {
+	char a;
+	int b : 12;
+	short c : 4;
+};
+
+union ubits {
+	unsigned int a : 5;
+	int b : 3;
+	char c;
+};
+
+struct wide {
+	unsigned long a : 63;
+	unsigned char b : 1;
+};
+
+int sizeofflags() { return sizeof(struct flags); }
+int alignofflags() { return _Alignof(struct flags); }
+int sizeofpacked() { return sizeof(struct packed); }
+int alignofpacked() { return _Alignof(struct packed); }
+int sizeofubits() { return sizeof(union ubits); }
+int sizeofwide() { return sizeof(struct wide); }
+
+int
+checkflags(struct flags *f)
+{
+	return f->a == 1 && f->b == 5 && f->c == -3 && f->d == 100 &&
+	    f->e == -1234567890 && f->f == 7;
+}
+
+void
+setflags(struct flags *f)
+{
+	f->a = 0;
+	f->b = 7;
+	f->c = -16;
+	f->d = 127;
+	f->e = 549755813887;
+	f->f = 1;
+}
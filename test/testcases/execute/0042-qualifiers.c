@@ -0,0 +1,100 @@
+struct point {
+	int x;
+	const int y;
+};
+
+const int answer = 42;
+const char greeting[6];
+const char *const hello = "hello";
+const int *const answerp = &answer;
+volatile int counter;
+int *restrict rp;
+_Atomic int atom;
+_Atomic(long) atomlong;
+
+int isreadonly(const void *p);
+int iswritable(const volatile void *p);
+
+static int
+sum(const int *p, int n)
+{
+	int s;
+	int i;
+
+	s = 0;
+	for (i = 0; i < n; i++)
+		s += p[i];
+	return s;
+}
+
+static const int *
+first(const int *p)
+{
+	return p;
+}
+
+int
+main()
+{
+	int arr[3];
+	int *p;
+	const int *cp;
+	int *const pc = arr;
+	const volatile int cv = 7;
+	struct point pt;
+	const struct point *ppt;
+	volatile int v;
+	int i;
+
+	arr[0] = 1;
+	arr[1] = 2;
+	arr[2] = 3;
+	/* Adding qualifiers is always allowed. */
+	cp = arr;
+	if (sum(arr, 3) != 6)
+		return 1;
+	if (*first(arr) != 1)
+		return 2;
+	*pc = 10;
+	if (arr[0] != 10 || cp[0] != 10)
+		return 3;
+	p = (int *)cp;
+	*p = 11;
+	if (*cp != 11)
+		return 4;
+	if (answer != 42 || *answerp != 42 || cv != 7)
+		return 5;
+	pt.x = 1;
+	*(int *)&pt.y = 2;
+	ppt = &pt;
+	if (ppt->x + ppt->y != 3)
+		return 6;
+	pt.x = 5;
+	if (ppt->x != 5)
+		return 7;
+
+	v = 0;
+	for (i = 0; i < 10; i++)
+		v += i;
+	if (v != 45)
+		return 8;
+	counter = 3;
+	counter++;
+	if (counter != 4)
+		return 9;
+	atom = 1;
+	atomlong = 2;
+	rp = &arr[1];
+	if (atom + atomlong + *rp != 5)
+		return 10;
+
+	if (!isreadonly(&answer) || !isreadonly(greeting) || !isreadonly(&hello))
+		return 11;
+	if (hello[0] != 104)
+		return 14;
+	if (!iswritable(&counter))
+		return 12;
+	if (sizeof(const int) != 4 || sizeof(greeting) != 6)
+		return 13;
+	return 0;
+}
@@ -0,0 +1,35 @@
+#include <signal.h>
+#include <setjmp.h>
+#include <string.h>
+
+static sigjmp_buf env;
+
+static void
+onfault(int sig)
+{
+	siglongjmp(env, 1);
+}
+
+/* Try to write to p, restoring the original value. */
+static int
+canwrite(const void *p)
+{
+	struct sigaction sa, osa, obus;
+	volatile int ok = 0;
+
+	memset(&sa, 0, sizeof(sa));
+	sa.sa_handler = onfault;
+	sigaction(SIGSEGV, &sa, &osa);
+	sigaction(SIGBUS, &sa, &obus);
+	if (sigsetjmp(env, 1) == 0) {
+		volatile char *c = (volatile char *)p;
+		*c = *c;
+		ok = 1;
+	}
+	sigaction(SIGSEGV, &osa, 0);
+	sigaction(SIGBUS, &obus, 0);
+	return ok;
+}
+
+int isreadonly(const void *p) { return !canwrite(p); }
+int iswritable(const volatile void *p) { return canwrite((const void *)p); }
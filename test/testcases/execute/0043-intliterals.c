@@ -0,0 +1,55 @@
+/* Tell apart signed and unsigned types of the same size. */
+#define ISSIGNED(x) ((x) - (x) - 1 < 0)
+
+int
+main()
+{
+	long l;
+	unsigned long long u;
+
+	if (sizeof(1) != 4 || !ISSIGNED(1))
+		return 1;
+	if (sizeof(2147483647) != 4)
+		return 2;
+	if (sizeof(2147483648) != 8 || !ISSIGNED(2147483648))
+		return 3;
+	if (sizeof(0xFFFFFFFF) != 4 || ISSIGNED(0xFFFFFFFF))
+		return 4;
+	if (sizeof(0x7FFFFFFF) != 4 || !ISSIGNED(0x7FFFFFFF))
+		return 5;
+	if (sizeof(037777777777) != 4 || ISSIGNED(037777777777))
+		return 6;
+	if (sizeof(0x100000000) != 8 || !ISSIGNED(0x100000000))
+		return 7;
+	if (sizeof(0xFFFFFFFFFFFFFFFF) != 8 || ISSIGNED(0xFFFFFFFFFFFFFFFF))
+		return 8;
+	if (sizeof(10u) != 4 || ISSIGNED(10u))
+		return 9;
+	if (sizeof(10U) != 4 || sizeof(10l) != 8 || sizeof(10L) != 8)
+		return 10;
+	if (sizeof(10UL) != 8 || ISSIGNED(10UL) || ISSIGNED(10lu))
+		return 11;
+	if (sizeof(10ll) != 8 || !ISSIGNED(10LL) || ISSIGNED(10ull) || ISSIGNED(10LLU))
+		return 12;
+	if (sizeof(4294967296u) != 8 || ISSIGNED(4294967296u))
+		return 13;
+	if (sizeof(0u) != 4 || sizeof(0L) != 8 || sizeof(07) != 4)
+		return 14;
+	if (017 != 15 || 0x1f != 31 || 0X1F != 31 || 0 != 0)
+		return 15;
+
+	l = 1LL << 40;
+	if (l != 1099511627776)
+		return 16;
+	l = -2147483648;
+	if (l >= 0 || l + 2147483647 != -1)
+		return 17;
+	u = 0xFFFFFFFFFFFFFFFF;
+	if (u + 1 != 0)
+		return 18;
+	if (0xFFFFFFFF + 1 != 0)
+		return 19;
+	if (4294967295 + 1 != 4294967296)
+		return 20;
+	return 0;
+}
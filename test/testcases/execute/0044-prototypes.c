@@ -0,0 +1,87 @@
+int tochar(char c);
+long widen(long v);
+int count();
+int oldstyle();
+int sumptrs(void *a, const void *b);
+int noargs(void);
+
+int
+tochar(char c)
+{
+	return c;
+}
+
+long
+widen(long v)
+{
+	return v;
+}
+
+int
+count(a, b, c)
+	char *a;
+	int c;
+{
+	return a[0] + b + c;
+}
+
+int
+oldstyle(x, p)
+	short x;
+	int *p;
+{
+	*p = x;
+	return x + 1;
+}
+
+int
+sumptrs(void *a, const void *b)
+{
+	return *(int *)a + *(int *)b;
+}
+
+int
+noargs(void)
+{
+	return 7;
+}
+
+int
+main()
+{
+	char s[2];
+	int i;
+	int j;
+	long (*fp)(long);
+
+	if (tochar(300) != 44)
+		return 1;
+	if (tochar(-1) != -1)
+		return 2;
+	if (widen(2147483647) + 1 != 2147483648)
+		return 3;
+	fp = widen;
+	if (fp(-5) != -5)
+		return 4;
+	s[0] = 1;
+	if (count(s, 2, 3) != 6)
+		return 5;
+	if (oldstyle(9, &i) != 10 || i != 9)
+		return 6;
+	i = 2;
+	j = 3;
+	if (sumptrs(&i, &j) != 5)
+		return 7;
+	if (noargs() != 7)
+		return 8;
+	/* Implicitly declared, defined later. */
+	if (later(4) != 8)
+		return 9;
+	return 0;
+}
+
+int
+later(int x)
+{
+	return x * 2;
+}
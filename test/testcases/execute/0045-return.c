@@ -0,0 +1,81 @@
+int counter;
+
+void
+bump(int n)
+{
+	if (n == 0)
+		return;
+	counter += n;
+}
+
+void
+bumptwice(int n)
+{
+	bump(n);
+	return bump(n);
+}
+
+char
+narrow(int v)
+{
+	return v;
+}
+
+long
+wide(int v)
+{
+	return v;
+}
+
+int
+sign(int v)
+{
+	if (v < 0)
+		return -1;
+	else if (v > 0)
+		return 1;
+	else
+		return 0;
+}
+
+int
+loop(int v)
+{
+	for (;;) {
+		if (v > 10)
+			return v;
+		v += 3;
+	}
+}
+
+int
+pick(int v)
+{
+	switch (v) {
+	case 1:
+		return 10;
+	default:
+		return 20;
+	}
+}
+
+int
+main()
+{
+	bump(0);
+	bump(2);
+	bumptwice(3);
+	if (counter != 8)
+		return 1;
+	if (narrow(257) != 1 || narrow(255) != -1)
+		return 2;
+	if (wide(-7) != -7)
+		return 3;
+	if (sign(-5) != -1 || sign(0) != 0 || sign(9) != 1)
+		return 4;
+	if (loop(1) != 13)
+		return 5;
+	if (pick(1) != 10 || pick(5) != 20)
+		return 6;
+	/* Reaching the end of main returns 0. */
+}
@@ -0,0 +1,85 @@
+extern int hostval;
+extern int hostshadowed(void);
+extern int hosthelper(void);
+
+static int shadowed = 3;
+
+static int
+helper(void)
+{
+	return 1;
+}
+
+static int helper(void);
+
+int tentative;
+int tentative;
+int tentative = 5;
+int tentative;
+
+extern int arr[];
+int arr[3];
+
+int zeroed;
+static int szeroed;
+int early[];
+
+int
+counter(void)
+{
+	static int n;
+
+	n = n + 1;
+	return n;
+}
+
+int
+other(void)
+{
+	static int n = 100;
+
+	n = n + 1;
+	return n;
+}
+
+int
+useextern(void)
+{
+	extern int tentative;
+	typedef int myint;
+	register myint r = tentative;
+
+	return r;
+}
+
+int
+main()
+{
+	if (counter() != 1)
+		return 1;
+	if (counter() != 2)
+		return 2;
+	if (other() != 101)
+		return 3;
+	if (counter() != 3)
+		return 4;
+	if (hostval != 42)
+		return 5;
+	if (shadowed != 3 || hostshadowed() != 7)
+		return 6;
+	if (helper() != 1 || hosthelper() != 100)
+		return 7;
+	if (tentative != 5 || useextern() != 5)
+		return 8;
+	if (sizeof(arr) != 3 * sizeof(int))
+		return 9;
+	arr[2] = 9;
+	if (arr[2] != 9)
+		return 10;
+	if (zeroed != 0 || szeroed != 0)
+		return 11;
+	early[0] = 4;
+	if (early[0] != 4)
+		return 12;
+	return 0;
+}
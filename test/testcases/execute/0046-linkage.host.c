@@ -0,0 +1,20 @@
+int hostval = 42;
+int shadowed = 7;
+
+int
+helper(void)
+{
+	return 100;
+}
+
+int
+hostshadowed(void)
+{
+	return shadowed;
+}
+
+int
+hosthelper(void)
+{
+	return helper();
+}
@@ -0,0 +1,116 @@
+enum color {
+	RED,
+	GREEN = 5,
+	BLUE,
+	NEG = -3,
+	NEXT,
+};
+
+enum {
+	N = BLUE + 1,
+	BIG = 0x7fffffff,
+};
+
+struct s {
+	int a;
+	int b;
+};
+
+char buf[10];
+char *p = &buf[4];
+char *pend = buf + 10 - 1;
+char *q = "hello" + 2;
+struct s gs;
+int *pb = &gs.b;
+struct s sarr[3];
+int *pc = &sarr[2].b;
+struct s *ps = &sarr[1];
+int *pd = &(&sarr[1])->a;
+long off = (long)&((struct s *)0)->b;
+long pdiff = &buf[7] - &buf[2];
+int arr[N * 2];
+unsigned char uc = 300;
+int trunc = (unsigned char)511;
+int sext = (signed char)200;
+unsigned int umax = 0u - 1;
+int divs = -7 / 2;
+int mods = -7 % 2;
+unsigned int udiv = 0xfffffffeu / 2;
+int shr = -8 >> 1;
+unsigned int ushr = 0x80000000u >> 31;
+long wide = 1L << 40;
+int cond = 3 > 2 ? 10 : 20;
+int land = 0 && (1 / 0);
+int lor = 1 || (1 / 0);
+int nott = !0 + !5;
+int bits = (0xf0 | 0x0f) & ~0x3 ^ 1;
+int cmpu = -1 < 0u;
+int cmps = -1 < 0;
+enum color ecol = BLUE;
+
+static int
+helper(void)
+{
+	return 7;
+}
+
+int (*fp)(void) = helper;
+int (*fp2)(void) = &helper;
+
+int
+classify(int v)
+{
+	switch (v) {
+	case 1 + 2:
+		return 1;
+	case GREEN * 2:
+		return 2;
+	case (int)(unsigned char)257:
+		return 3;
+	case sizeof(struct s) * 10:
+		return 4;
+	case -NEG * 4:
+		return 5;
+	}
+	return 0;
+}
+
+int
+main()
+{
+	enum color c;
+	int local[BLUE];
+
+	c = GREEN;
+	if (RED != 0 || c != 5 || BLUE != 6 || NEG != -3 || NEXT != -2 || N != 7)
+		return 1;
+	if (sizeof(arr) != 14 * sizeof(int) || sizeof(local) != 6 * sizeof(int))
+		return 2;
+	if (p != buf + 4 || pend != buf + 9)
+		return 3;
+	if (q[0] != 108 || q[1] != 108 || q[2] != 111)
+		return 4;
+	if (pb != &gs.b || pc != &sarr[2].b || ps != sarr + 1 || pd != &sarr[1].a)
+		return 5;
+	if (off != 4 || pdiff != 5)
+		return 6;
+	if (uc != 44 || trunc != 255 || sext != -56)
+		return 7;
+	if (umax != 0xffffffff || divs != -3 || mods != -1 || udiv != 0x7fffffff)
+		return 8;
+	if (shr != -4 || ushr != 1 || wide != 1099511627776)
+		return 9;
+	if (cond != 10 || land != 0 || lor != 1 || nott != 1)
+		return 10;
+	if (bits != 253 || cmpu != 0 || cmps != 1)
+		return 11;
+	if (fp() != 7 || fp2() != 7)
+		return 12;
+	if (classify(3) != 1 || classify(10) != 2 || classify(1) != 3)
+		return 13;
+	if (classify(80) != 4 || classify(3 + 0) != 1 || classify(12) != 5)
+		return 14;
+	if (ecol != BLUE || BIG != 2147483647)
+		return 15;
+	return 0;
+}
@@ -0,0 +1,94 @@
+inline int
+twice(int x)
+{
+	return 2 * x;
+}
+
+static inline long long
+square(long long x)
+{
+	return x * x;
+}
+
+_Bool gflag = 5;
+_Bool gnull = (void *)0;
+_Bool gaddr = &gflag;
+
+_Bool
+tobool(long v)
+{
+	return v;
+}
+
+int
+main()
+{
+	int n;
+	_Bool b;
+	unsigned long long big;
+	long long neg;
+	unsigned long int ul;
+
+	n = 0;
+	for (int i = 0; i < 10; i++) {
+		if (i % 2)
+			continue;
+		n += i;
+	}
+	if (n != 20)
+		return 1;
+	n = 0;
+	for (int i = 0, j = 10; i < j; i++, j--)
+		n++;
+	if (n != 5)
+		return 2;
+	{
+		int i = 42;
+
+		for (int i = 0; i < 3; i++)
+			;
+		if (i != 42)
+			return 3;
+	}
+	n = 0;
+	int late = 7;
+	n += late;
+	typedef int myint;
+	myint m = 3;
+	if (n + m != 10)
+		return 4;
+
+	b = 256;
+	if (b != 1)
+		return 5;
+	b = 0;
+	b++;
+	b++;
+	if (b != 1)
+		return 6;
+	b--;
+	if (b != 0)
+		return 7;
+	b--;
+	if (b != 1)
+		return 8;
+	b += 2;
+	if (b != 1 || sizeof(b) != 1)
+		return 9;
+	b = &n;
+	if (!b || tobool(0x100000000) != 1 || tobool(0) != 0)
+		return 10;
+	if (gflag != 1 || gnull != 0 || gaddr != 1)
+		return 11;
+
+	big = 0xffffffffffffffffULL;
+	neg = -1LL;
+	ul = 0xffffffffffffffffUL;
+	if (big / 2 != 0x7fffffffffffffffLL || neg >= 0 || ul < 1)
+		return 12;
+	if (sizeof(long long) != 8 || square(3000000000LL) != 9000000000000000000LL)
+		return 13;
+	if (twice(21) != 42)
+		return 14;
+	return 0;
+}
@@ -0,0 +1,5 @@
+int
+twice(int x)
+{
+	return 2 * x;
+}
@@ -0,0 +1,136 @@
+struct point {
+	int x;
+	int y;
+};
+
+struct rect {
+	struct point min;
+	struct point max;
+	char name[8];
+};
+
+struct flags {
+	unsigned a : 3;
+	int b : 5;
+	unsigned c : 8;
+};
+
+union num {
+	long l;
+	char c;
+};
+
+struct vec {
+	long len;
+	int data[];
+};
+
+int garr[] = {1, 2, 3, 4};
+int gsparse[10] = {[2] = 5, [7] = 9, 10};
+struct rect grect = {{1, 2}, .max = {3, 4}, "grect"};
+struct rect gelided = {5, 6, 7, 8};
+struct point gpts[] = {1, 2, 3, 4, [3].y = 8};
+char gstr[] = "hi\tthere\n";
+char gexact[3] = "abc";
+struct flags gflags = {5, -3, 200};
+union num gunion = {.c = 3};
+int *gptr = (int[]){7, 8, 9};
+struct point *gpoint = &(struct point){10, 20};
+int *gaddrs[] = {&garr[1], &gsparse[7]};
+struct vec gvec = {3, {1, 2, 3}};
+const char *gnames[] = {"zero", "one"};
+
+int
+sum(int *p, int n)
+{
+	int i;
+	int s;
+
+	s = 0;
+	for (i = 0; i < n; i++)
+		s += p[i];
+	return s;
+}
+
+int
+area(struct rect r)
+{
+	return (r.max.x - r.min.x) * (r.max.y - r.min.y);
+}
+
+int
+main()
+{
+	int i;
+
+	if (sizeof(garr) != 4 * sizeof(int))
+		return 1;
+	if (sum(garr, 4) != 10)
+		return 2;
+	if (gsparse[2] != 5 || gsparse[7] != 9 || gsparse[8] != 10 || gsparse[9] != 0)
+		return 3;
+	if (grect.min.y != 2 || grect.max.x != 3 || grect.name[0] != 103 || grect.name[5] != 0)
+		return 4;
+	if (gelided.min.x != 5 || gelided.max.y != 8)
+		return 5;
+	if (sizeof(gpts) != 4 * sizeof(struct point) || gpts[1].y != 4 || gpts[3].y != 8 || gpts[3].x != 0)
+		return 6;
+	if (sizeof(gstr) != 10 || gstr[2] != 9 || gstr[8] != 10 || gstr[9] != 0)
+		return 7;
+	if (gexact[2] != 99)
+		return 8;
+	if (gflags.a != 5 || gflags.b != -3 || gflags.c != 200)
+		return 9;
+	if (gunion.c != 3)
+		return 10;
+	if (gptr[2] != 9 || gpoint->y != 20)
+		return 11;
+	if (*gaddrs[0] != 2 || *gaddrs[1] != 9)
+		return 12;
+	if (sizeof(struct vec) != sizeof(long) || gvec.data[2] != 3)
+		return 13;
+	if (gnames[1][0] != 111)
+		return 14;
+
+	{
+		int arr[] = {i = 3, i + 1, [4] = 6};
+		struct rect r = {.max = {i, i * 2}, .min.y = 1, .name = {104, 105}};
+		struct rect copy = r;
+		struct point pts[2][2] = {{{1, 2}}, 3, 4, 5, 6};
+		struct flags f = {.c = 255, .b = i - 5};
+		char s[] = "abc";
+		char t[5] = {"xy"};
+		union num u = {-1};
+
+		if (sizeof(arr) != 5 * sizeof(int) || arr[0] != 3 || arr[1] != 4 || arr[3] != 0 || arr[4] != 6)
+			return 20;
+		if (r.min.x != 0 || r.min.y != 1 || r.max.y != 6 || r.name[1] != 105 || r.name[2] != 0)
+			return 21;
+		if (copy.max.x != 3 || area(r) != 15)
+			return 22;
+		if (pts[0][0].y != 2 || pts[0][1].x != 0 || pts[1][0].x != 3 || pts[1][1].y != 6)
+			return 23;
+		if (f.a != 0 || f.b != -2 || f.c != 255)
+			return 24;
+		if (sizeof(s) != 4 || s[2] != 99 || t[1] != 121 || t[4] != 0)
+			return 25;
+		if (u.l != -1)
+			return 26;
+	}
+
+	for (i = 0; i < 3; i++) {
+		int *p = (int[]){i, i + 1};
+		struct point *q = &(struct point){.y = i};
+
+		if (p[0] + p[1] != 2 * i + 1 || q->x != 0 || q->y != i)
+			return 30;
+		q->x = 5;
+	}
+	if (area((struct rect){{0, 0}, {2, 3}}) != 6)
+		return 31;
+	if (((struct point){1, 2}).y != 2 || (int){4} != 4)
+		return 32;
+	if (sum((int[]){1, 2, 3}, 3) != 6)
+		return 33;
+	return 0;
+}
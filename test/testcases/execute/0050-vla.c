@@ -0,0 +1,147 @@
+int
+sum(int n, int a[n])
+{
+	int i;
+	int s;
+
+	s = 0;
+	for (i = 0; i < n; i++)
+		s += a[i];
+	return s;
+}
+
+int
+trace(int n, int m, int a[n][m])
+{
+	int i;
+	int s;
+
+	s = 0;
+	for (i = 0; i < n && i < m; i++)
+		s += a[i][i];
+	return s;
+}
+
+unsigned long
+rowsize(int n, int (*p)[n])
+{
+	return sizeof(*p);
+}
+
+void *
+stackptr(void)
+{
+	char c;
+	char *p;
+
+	p = &c;
+	return p;
+}
+
+int
+loop(int iters)
+{
+	int i;
+	char *first;
+
+	first = 0;
+	for (i = 0; i < iters; i++) {
+		char buf[iters + 1000];
+
+		buf[i] = i;
+		if (!first)
+			first = buf;
+		if (buf != first)
+			return 1;
+	}
+	return 0;
+}
+
+int
+jumps(int n)
+{
+	int count;
+	char *first;
+
+	count = 0;
+	first = 0;
+again:
+	{
+		char buf[n];
+
+		buf[0] = count;
+		if (!first)
+			first = buf;
+		if (buf != first)
+			return 100;
+		count++;
+		if (count < 50)
+			goto again;
+	}
+	while (count < 100) {
+		char a[n];
+
+		a[n - 1] = count;
+		if (a != first)
+			return 101;
+		count++;
+		if (count & 1)
+			continue;
+		if (count == 90)
+			break;
+	}
+	return count;
+}
+
+int
+main()
+{
+	int n;
+	int i;
+	int j;
+
+	n = 5;
+	{
+		int a[n];
+		int b[n + 1][n * 2];
+		int (*p)[n * 2];
+		char *c;
+
+		if (sizeof(a) != 5 * sizeof(int))
+			return 1;
+		if (sizeof(b) != 6 * 10 * sizeof(int) || sizeof(b[1]) != 10 * sizeof(int))
+			return 2;
+		if (sizeof(int[n][3]) != n * 3 * sizeof(int))
+			return 3;
+		for (i = 0; i < n; i++)
+			a[i] = i + 1;
+		n = 100;
+		if (sizeof(a) != 5 * sizeof(int))
+			return 4;
+		if (sum(5, a) != 15)
+			return 5;
+		for (i = 0; i < 6; i++)
+			for (j = 0; j < 10; j++)
+				b[i][j] = i * 10 + j;
+		if (b[3][7] != 37 || trace(6, 10, b) != 0 + 11 + 22 + 33 + 44 + 55)
+			return 6;
+		p = b;
+		p++;
+		if (p[1][2] != 22 || p - b != 1 || *(p + 2) != b[3])
+			return 7;
+		if (rowsize(7, 0) != 7 * sizeof(int))
+			return 8;
+		c = __builtin_alloca(n);
+		for (i = 0; i < n; i++)
+			c[i] = i;
+		if (c[99] != 99 || (unsigned long)c % 16 != 0 || a[4] != 5)
+			return 9;
+		if (sum(3, (int *)__builtin_alloca(3 * sizeof(int))) + 1 == 0)
+			return 10;
+	}
+	if (loop(200) != 0)
+		return 11;
+	if (jumps(30) != 90)
+		return 12;
+	return 0;
+}
@@ -0,0 +1,121 @@
+_Static_assert(sizeof(int) == 4, "int is 4 bytes");
+_Static_assert(1 + 1 == 2);
+
+struct tagged {
+	int kind;
+	union {
+		long l;
+		struct {
+			int lo;
+			int hi;
+		};
+	};
+	struct {
+		char c;
+	} named;
+	_Static_assert(sizeof(long) == 8, "long is 8 bytes");
+};
+
+struct aligned {
+	char c;
+	_Alignas(16) int i;
+	_Alignas(long) char d;
+};
+
+_Alignas(64) char gbuf[3];
+_Alignas(int) char gc;
+int gcount;
+
+_Noreturn void die(int);
+
+_Noreturn void
+loop(void)
+{
+	for (;;)
+		gcount++;
+}
+
+_Noreturn void
+die(int n)
+{
+	gcount = n;
+	loop();
+}
+
+int
+check(int n)
+{
+	if (n > 10)
+		return n;
+	die(n);
+}
+
+struct tagged gt = {1, .hi = 5, .named = {3}};
+struct tagged gp = {2, {7}};
+
+#define typeid(x) _Generic((x), int: 1, long: 2, char *: 3, const char *: 4, struct tagged: 5, default: 0)
+
+int
+main()
+{
+	struct tagged t;
+	struct tagged *p;
+	struct aligned a[2];
+	_Alignas(16) char c;
+	char d;
+	_Alignas(16) char e[3];
+	const char *s;
+	char arr[4];
+	int i;
+
+	_Static_assert(sizeof(struct aligned) == 32, "layout");
+	if (__builtin_offsetof(struct aligned, i) != 16 || __builtin_offsetof(struct aligned, d) != 24)
+		return 1;
+	if (__builtin_offsetof(struct tagged, hi) != 12 || sizeof(struct tagged) != 24)
+		return 2;
+	if ((unsigned long)gbuf % 64 != 0 || (unsigned long)&c % 16 != 0 || (unsigned long)e % 16 != 0)
+		return 3;
+	if ((unsigned long)&a[1].i % 16 != 0)
+		return 4;
+	d = 1;
+	c = 2;
+	if (d + c != 3)
+		return 5;
+
+	t.lo = 1;
+	t.hi = 2;
+	if (t.l != 0x200000001)
+		return 6;
+	p = &t;
+	p->l = 3;
+	if (p->lo != 3 || p->hi != 0)
+		return 7;
+	p->named.c = 4;
+	if (t.named.c != 4)
+		return 8;
+	if (gt.kind != 1 || gt.lo != 0 || gt.hi != 5 || gt.named.c != 3)
+		return 9;
+	if (gp.l != 7)
+		return 10;
+	{
+		struct tagged lt = {.lo = 8, 9, {10}};
+
+		if (lt.kind != 0 || lt.lo != 8 || lt.hi != 9 || lt.named.c != 10)
+			return 11;
+	}
+
+	s = "x";
+	i = 0;
+	if (typeid(i) != 1 || typeid(1L) != 2 || typeid(arr) != 3 || typeid(s) != 4 || typeid(t) != 5 || typeid(a[0]) != 0)
+		return 12;
+	if (typeid(i++) != 1 || i != 0)
+		return 13;
+	_Generic(i, int: i, default: s) = 6;
+	if (i != 6)
+		return 14;
+	if (_Generic(&t, struct tagged *: sizeof(t), default: 0) != 24)
+		return 15;
+	if (check(11) != 11)
+		return 16;
+	return 0;
+}
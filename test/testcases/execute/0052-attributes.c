@@ -0,0 +1,173 @@
+struct __attribute__((packed)) packed {
+	char c;
+	int i;
+	long l;
+};
+
+struct inner {
+	char c;
+	int i __attribute__((packed));
+};
+
+struct wide {
+	char c;
+} __attribute__((aligned(32)));
+
+struct member {
+	char c;
+	int i __attribute__((aligned(16)));
+};
+
+typedef int __attribute__((mode(DI))) di;
+typedef unsigned __attribute__((mode(word))) uword;
+typedef int __attribute__((__mode__(__pointer__))) iptr;
+
+int gcount;
+int order;
+int ctor1;
+int ctor2;
+int __attribute__((section("mydata"))) insection = 3;
+int __attribute__((aligned(64))) galigned;
+int __attribute__((weak)) weakint = 4;
+int weakfn(void) __attribute__((weak));
+int realfn(void) { return 7; }
+int aliasfn(void) __attribute__((alias("realfn")));
+extern int aliasint __attribute__((alias("gcount")));
+__attribute__((visibility("hidden"))) int hiddenint = 5;
+static int __attribute__((unused)) unusedint;
+
+int printfmt(const char *fmt, ...) __attribute__((format(printf, 1, 2)));
+
+__attribute__((constructor)) static void
+ctor(void)
+{
+	ctor1 = ++order;
+}
+
+__attribute__((constructor(200))) static void
+ctorfirst(void)
+{
+	ctor2 = ++order;
+}
+
+__attribute__((section(".text.mine"), noinline)) static int
+insectionfn(void)
+{
+	return 9;
+}
+
+__attribute__((noreturn)) void
+forever(void)
+{
+	for (;;)
+		;
+}
+
+void
+release(int *p)
+{
+	gcount += *p;
+}
+
+int
+cleanupbreak(void)
+{
+	int i;
+
+	for (i = 0; i < 3; i++) {
+		int __attribute__((cleanup(release))) v = 1;
+
+		if (i == 1)
+			break;
+	}
+	return gcount;
+}
+
+int
+cleanupreturn(void)
+{
+	int __attribute__((cleanup(release))) v = 10;
+
+	v = 20;
+	return v + 1;
+}
+
+int
+cleanupgoto(void)
+{
+	int n;
+
+	n = 0;
+again:
+	{
+		int __attribute__((cleanup(release))) v = 100;
+
+		n++;
+		if (n < 3)
+			goto again;
+	}
+	return gcount;
+}
+
+int
+main()
+{
+	struct packed p;
+	struct inner in;
+	static struct wide w[2];
+	struct member m;
+	di d;
+
+	if (sizeof(struct packed) != 13 || __builtin_offsetof(struct packed, l) != 5)
+		return 1;
+	if (sizeof(struct inner) != 5 || __builtin_offsetof(struct inner, i) != 1)
+		return 2;
+	if (sizeof(struct wide) != 32 || sizeof w != 64 || (unsigned long)&w[1] % 32 != 0)
+		return 3;
+	if (sizeof(struct member) != 32 || __builtin_offsetof(struct member, i) != 16)
+		return 4;
+	if (sizeof d != 8 || (unsigned long)&galigned % 64 != 0)
+		return 5;
+	if (sizeof(uword) != sizeof(long) || (uword)-1 < 0 || sizeof(iptr) != sizeof(void *))
+		return 5;
+	p.c = 1;
+	p.i = 2;
+	p.l = 3;
+	if (p.c + p.i + p.l != 6)
+		return 6;
+	in.i = 7;
+	if (in.i != 7)
+		return 7;
+	if (insection != 3 || weakint != 4 || hiddenint != 5)
+		return 8;
+	if (&weakfn != 0)
+		return 9;
+	if (aliasfn() != 7)
+		return 10;
+	gcount = 11;
+	if (aliasint != 11)
+		return 11;
+	if (ctor2 != 1 || ctor1 != 2)
+		return 12;
+	if (insectionfn() != 9)
+		return 13;
+	gcount = 0;
+	if (cleanupbreak() != 2)
+		return 14;
+	gcount = 0;
+	if (cleanupreturn() != 21 || gcount != 20)
+		return 15;
+	gcount = 0;
+	if (cleanupgoto() != 300)
+		return 16;
+	gcount = 0;
+	{
+		int __attribute__((cleanup(release))) a = 1;
+		int __attribute__((cleanup(release))) b = 2;
+
+		m.i = 0;
+	}
+	if (gcount != 3)
+		return 17;
+	return 0;
+}
@@ -0,0 +1,202 @@
+#define max(a, b) ({ __auto_type _a = (a); __auto_type _b = (b); _a > _b ? _a : _b; })
+#define swap(x, y) do { typeof(x) _t = (x); (x) = (y); (y) = _t; } while (0)
+
+struct pair {
+	int a;
+	int b;
+};
+
+int gcount;
+int tbl[] = {1, 2, 3};
+typeof(tbl) tbl2;
+__typeof__(int *) gp = tbl;
+
+int
+next(void)
+{
+	return ++gcount;
+}
+
+void
+release(int *p)
+{
+	gcount += *p;
+}
+
+int
+classify(int c)
+{
+	switch (c) {
+	case 0 ... 9:
+		return 1;
+	case 10:
+		return 2;
+	case 11 ... 20:
+	case 30 ... 30:
+		return 3;
+	case -5 ... -1:
+		return 4;
+	default:
+		return 5;
+	}
+}
+
+int
+charclass(int c)
+{
+	switch (c) {
+	case 'a' ... 'z':
+		return 1;
+	case 'A' ... 'Z':
+		return 2;
+	case '0' ... '9':
+		return 3;
+	case '\n':
+		return 4;
+	}
+	return 0;
+}
+
+int
+wrapcase(int x)
+{
+	switch (x) {
+	case 4294967295u:
+		return 1;
+	case 2 ... 4:
+		return 2;
+	}
+	return 0;
+}
+
+int
+urange(unsigned x)
+{
+	switch (x) {
+	case 0 ... 5:
+		return 1;
+	case 4294967290u ... 4294967295u:
+		return 2;
+	case -7:
+		return 3;
+	}
+	return 0;
+}
+
+int
+interp(int *prog)
+{
+	static void *ops[] = {&&add, &&sub, &&halt};
+	int acc;
+
+	acc = 0;
+	goto *ops[*prog++];
+add:
+	acc += *prog++;
+	goto *ops[*prog++];
+sub:
+	acc -= *prog++;
+	goto *ops[*prog++];
+halt:
+	return acc;
+}
+
+struct pair
+mkpair(int a)
+{
+	return ({ struct pair p = {a, a + 1}; p; });
+}
+
+int
+main()
+{
+	int i;
+	int j;
+	long l;
+	char *s;
+	void *lbl;
+	int prog[] = {0, 5, 0, 7, 1, 2, 2};
+	struct pair p;
+
+	i = 1;
+	j = 2;
+	if (max(i, j) != 2 || max(i++, j) != 2 || i != 2)
+		return 1;
+	swap(i, j);
+	if (i != 2 || j != 2)
+		return 2;
+	i = 3;
+	swap(i, j);
+	if (i != 2 || j != 3)
+		return 3;
+	if (sizeof(tbl2) != 3 * sizeof(int) || gp[2] != 3)
+		return 4;
+	{
+		__auto_type x = 1L;
+		__auto_type y = tbl;
+		const __auto_type z = 2 - 2;
+		typeof(x) w;
+
+		if (sizeof(x) != 8 || sizeof(y) != 8 || sizeof(w) != 8 || z != 0 || y[1] != 2)
+			return 5;
+	}
+	if (({ int k = 3; k * 2; }) != 6)
+		return 6;
+	gcount = 0;
+	i = ({
+		int __attribute__((cleanup(release))) c = 10;
+		int k;
+
+		for (k = 0; k < 3; k++)
+			;
+		k + 1;
+	});
+	if (i != 4 || gcount != 10)
+		return 7;
+	({ gcount = 0; });
+	if (gcount != 0)
+		return 8;
+	gcount = 0;
+	i = next() ?: 7;
+	if (i != 1 || gcount != 1)
+		return 9;
+	l = 0;
+	l = l ?: 5;
+	s = 0;
+	s = s ?: "x";
+	if (l != 5 || s[1] != 0 || (0 ?: 3) != 3)
+		return 10;
+	if (classify(5) != 1 || classify(10) != 2 || classify(15) != 3 || classify(30) != 3)
+		return 11;
+	if (classify(-3) != 4 || classify(25) != 5 || classify(-6) != 5)
+		return 12;
+	if (interp(prog) != 10)
+		return 13;
+	lbl = &&done;
+	i = 0;
+	if (i == 0)
+		goto *lbl;
+	return 14;
+done:
+	p = mkpair(4);
+	if (p.a != 4 || p.b != 5)
+		return 15;
+	if (({ mkpair(6); }).b != 7)
+		return 16;
+	if (charclass('q') != 1 || charclass('Q') != 2 || charclass('5') != 3 || charclass('\n') != 4)
+		return 17;
+	if (charclass(' ') != 0 || charclass('z' + 1) != 0 || '\xff' != -1 || '\0' != 0 || '\101' != 'A')
+		return 18;
+	{
+		char buf[4];
+
+		__builtin_memset(buf, 'a', sizeof buf);
+		if (buf[0] != 'a' || buf[3] != 97 || sizeof('a') != sizeof(int))
+			return 19;
+	}
+	if (wrapcase(-1) != 1 || wrapcase(3) != 2 || wrapcase(5) != 0)
+		return 20;
+	if (urange(-1) != 2 || urange(-6) != 2 || urange(-7) != 3 || urange(3) != 1 || urange(6) != 0)
+		return 21;
+	return 0;
+}
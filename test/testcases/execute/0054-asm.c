@@ -0,0 +1,83 @@
+asm(".globl asmfn\n"
+    ".type asmfn, @function\n"
+    "asmfn:\n"
+    "\tmovl $42, %eax\n"
+    "\tret");
+
+int asmfn(void);
+
+int gmem = 5;
+
+int
+add(int a, int b)
+{
+	asm("addl %1, %0" : "+r"(a) : "r"(b));
+	return a;
+}
+
+long
+triple(long x)
+{
+	long r;
+
+	__asm__ __volatile__("leaq (%1,%1,2), %0" : "=r"(r) : "r"(x));
+	return r;
+}
+
+int
+main()
+{
+	unsigned lo;
+	unsigned hi;
+	int i;
+	long l;
+	int m;
+	int arr[4];
+
+	asm volatile("rdtsc" : "=a"(lo), "=d"(hi));
+	if (lo == 0 && hi == 0)
+		return 1;
+	if (add(3, 4) != 7 || triple(5) != 15)
+		return 2;
+	m = 1;
+	asm("addl $2, %0" : "+m"(m));
+	asm("addl %1, %0" : "+m"(gmem) : "i"(10));
+	if (m != 3 || gmem != 15)
+		return 3;
+	asm("movl $%c1, %0" : "=r"(i) : "i"(7));
+	if (i != 7)
+		return 4;
+	i = 20;
+	asm("subl %2, %0" : "=r"(i) : "0"(i), "r"(6));
+	if (i != 14)
+		return 5;
+	asm("imull %[f], %[v]" : [v] "+r"(i) : [f] "r"(2));
+	if (i != 28)
+		return 6;
+	l = -1;
+	asm("movl %k1, %k0" : "+r"(l) : "r"(9L));
+	if (l != 9)
+		return 7;
+	asm("movq %q1, %0" : "=r"(l) : "r"(-2L));
+	if (l != -2)
+		return 8;
+	asm("movl $3, %%ebx\n\tmovl %%ebx, %0" : "=r"(i) : : "rbx", "cc");
+	if (i != 3)
+		return 9;
+	arr[1] = 0;
+	asm("movl $11, 4(%0)" : : "r"(arr) : "memory");
+	if (arr[1] != 11)
+		return 10;
+	i = 0;
+	asm("jmp 1f\n\taddl $1, %0\n1:\n\taddl $2, %0" : "+r"(i));
+	if (i != 2)
+		return 11;
+	asm("movl %1, %0" : "=m"(i) : "r"(gmem));
+	if (i != 15)
+		return 12;
+	if (asmfn() != 42)
+		return 13;
+	asm("");
+	__asm("nop");
+	return 0;
+}
@@ -0,0 +1,107 @@
+int folded = __builtin_popcount(0xff) + __builtin_clz(1) + __builtin_bswap16(0x100);
+
+int
+sign(int x)
+{
+	if (x < 0)
+		return -1;
+	if (x > 0)
+		return 1;
+	if (x == 0)
+		return 0;
+	__builtin_unreachable();
+}
+
+int
+main()
+{
+	int i;
+	unsigned u;
+	long l;
+	unsigned long ul;
+	short s;
+	unsigned char uc;
+	int src[4] = {1, 2, 3, 4};
+	int dst[4];
+
+	if (folded != 40)
+		return 1;
+	i = 3;
+	if (!__builtin_expect(i == 3, 1) || __builtin_expect(i, 0) != 3)
+		return 2;
+	if (!__builtin_constant_p(3 * 4) || __builtin_constant_p(i))
+		return 3;
+	if (__builtin_memcpy(dst, src, sizeof src) != dst || dst[0] != 1 || dst[3] != 4)
+		return 4;
+	if (__builtin_memset(dst, 0, 2 * sizeof(int)) != dst || dst[0] != 0 || dst[1] != 0 || dst[2] != 3)
+		return 5;
+	u = 1;
+	l = 0x100;
+	if (__builtin_clz(u) != 31 || __builtin_clzl(u) != 63 || __builtin_clzll(l) != 55)
+		return 6;
+	u = 8;
+	l = 1L << 40;
+	if (__builtin_ctz(u) != 3 || __builtin_ctzl(l) != 40 || __builtin_ctzll(l) != 40)
+		return 7;
+	u = 0xff;
+	l = -1;
+	if (__builtin_popcount(u) != 8 || __builtin_popcountl(l) != 64 || __builtin_popcountll(0) != 0)
+		return 8;
+	u = 0x12345678;
+	ul = 0x0102030405060708;
+	if (__builtin_bswap16(u) != 0x7856 || __builtin_bswap32(u) != 0x78563412)
+		return 9;
+	if (__builtin_bswap64(ul) != 0x0807060504030201)
+		return 10;
+	if (!__builtin_add_overflow(2147483647, 1, &i) || i != -2147483647 - 1)
+		return 11;
+	if (__builtin_add_overflow(2, 3, &i) || i != 5)
+		return 12;
+	if (!__builtin_sub_overflow(0, 1, &u) || u != 0xffffffff)
+		return 13;
+	if (!__builtin_mul_overflow(1L << 62, 2, &l) || l != 1L << 63)
+		return 14;
+	if (__builtin_mul_overflow(1UL << 32, 1UL << 31, &ul) || ul != 1UL << 63)
+		return 15;
+	if (!__builtin_mul_overflow(1UL << 63, 2, &ul) || ul != 0)
+		return 16;
+	if (!__builtin_add_overflow(200, 100, &uc) || uc != 44)
+		return 17;
+	if (!__builtin_mul_overflow(200, 200, &s) || s != -25536)
+		return 18;
+	l = 9223372036854775807L;
+	if (!__builtin_add_overflow(l, 1, &l) || l >= 0)
+		return 19;
+	ul = 0;
+	if (!__builtin_sub_overflow(ul, 1, &ul) || ul + 1 != 0)
+		return 20;
+	if (__builtin_sadd_overflow(1, 2, &i) || i != 3 || !__builtin_umull_overflow(1UL << 63, 4, &ul))
+		return 21;
+	if (sign(-5) != -1 || sign(0) != 0)
+		return 22;
+	if (!__builtin_add_overflow(-1, 0, &u) || u != 0xffffffff)
+		return 23;
+	if (__builtin_add_overflow(-1, 2, &u) || u != 1)
+		return 24;
+	if (!__builtin_mul_overflow(-1, 1, &ul) || __builtin_sub_overflow(5, 3L, &ul) || ul != 2)
+		return 25;
+	if (!__builtin_add_overflow(4294967295u, 0, &i) || i != -1)
+		return 26;
+	if (__builtin_sub_overflow(0u, 1u, &i) || i != -1)
+		return 27;
+	if (__builtin_mul_overflow(-1, 4294967295u, &l) || l != -4294967295L)
+		return 28;
+	if (__builtin_mul_overflow(-1L, 1UL << 63, &l) || l != -9223372036854775807L - 1)
+		return 29;
+	if (!__builtin_mul_overflow(1UL << 63, 1UL << 63, &ul) || !__builtin_mul_overflow(~0UL, ~0UL, &ul))
+		return 30;
+	if (!__builtin_mul_overflow(~0UL, ~0UL, &l) || !__builtin_sub_overflow(-2, ~0UL, &l))
+		return 31;
+	if (__builtin_sub_overflow(0UL, 1UL << 63, &l) || l != -9223372036854775807L - 1)
+		return 32;
+	if (__builtin_add_overflow(~0UL, -1, &ul) || ul != ~0UL - 1)
+		return 33;
+	if (i == 100)
+		__builtin_trap();
+	return 0;
+}
@@ -0,0 +1,82 @@
+#define ZERO 0
+#define VERSION 3
+#define NEXTVERSION (VERSION + 1)
+#define TWICE(x) ((x) * 2)
+
+#if ZERO
+int zero = 1;
+#else
+int zero = 0;
+#endif
+
+#if VERSION >= 2
+int version = 20;
+#else
+int version = 10;
+#endif
+
+#if NEXTVERSION == 4 && TWICE(VERSION) == 6
+int expanded = 1;
+#else
+int expanded = 0;
+#endif
+
+#if TWICE || undefinedname
+int plainident = 1;
+#elif defined TWICE && defined(VERSION) && !defined ZERO2
+int plainident = 0;
+#else
+int plainident = 2;
+#endif
+
+#if VERSION == 1
+int elif = 1;
+#elif VERSION == 2
+int elif = 2;
+#elif VERSION == 3
+int elif = 3;
+#else
+int elif = 4;
+#endif
+
+#if __has_builtin(__builtin_clz) && !__has_builtin(__builtin_nonexistent)
+#define HAVE_CLZ 1
+#else
+#define HAVE_CLZ 0
+#endif
+
+#ifdef HAVE_CLZ
+int hasclz = HAVE_CLZ;
+#else
+int hasclz = -1;
+#endif
+
+#ifndef HAVE_CLZ
+#error "HAVE_CLZ is not defined"
+#elif __has_builtin(__builtin_mul_overflow)
+int hasmul = 1;
+#else
+int hasmul = 0;
+#endif
+
+#if 0
+#if 1
+int skipped = 1;
+#else
+#error "nested group in a skipped group"
+#endif
+#endif
+
+int
+main()
+{
+	if (zero != 0 || version != 20 || expanded != 1)
+		return 1;
+	if (plainident != 0 || elif != 3)
+		return 2;
+	if (hasclz != 1 || hasmul != 1)
+		return 3;
+	if (TWICE(2) != 4)
+		return 4;
+	return 0;
+}
@@ -0,0 +1,87 @@
+struct line {
+	char c;
+} __attribute__((aligned(64)));
+
+struct pair {
+	int a;
+	struct line l;
+};
+
+int cleaned;
+
+void
+cleanup(long *p)
+{
+	if ((unsigned long)p % 32 == 0)
+		cleaned = *p;
+}
+
+int
+misaligned(void *p, unsigned long align)
+{
+	return (unsigned long)p % align != 0;
+}
+
+int
+check(int depth)
+{
+	char c = 1;
+	_Alignas(64) char a[3] = {1, 2, 3};
+	int b[3] __attribute__((aligned(32))) = {4, 5, 6};
+	struct line l = {7};
+	if (misaligned(a, 64) || misaligned(b, 32) || misaligned(&l, 64))
+		return 1;
+	if (c + a[0] + a[1] + a[2] + b[0] + b[1] + b[2] + l.c != 29)
+		return 2;
+	if (depth > 0) {
+		_Alignas(128) int inner = depth;
+		if (misaligned(&inner, 128))
+			return 3;
+		return check(depth - 1);
+	}
+	return 0;
+}
+
+int
+vla(int n)
+{
+	char pad[3] = {0};
+	_Alignas(64) int arr[n];
+	for (int i = 0; i < n; i++)
+		arr[i] = i;
+	if (misaligned(arr, 64))
+		return 1;
+	return arr[n - 1] + pad[0];
+}
+
+int
+main()
+{
+	int r;
+
+	for (int i = 0; i < 4; i++) {
+		r = check(i);
+		if (r)
+			return r;
+	}
+	{
+		struct pair p = {1, {2}};
+		if (misaligned(&p, 64) || misaligned(&p.l, 64))
+			return 4;
+		if (p.a + p.l.c != 3)
+			return 5;
+		if (misaligned(&(struct line){8}, 64))
+			return 6;
+		if ((struct line){8}.c != 8)
+			return 7;
+	}
+	{
+		__attribute__((cleanup(cleanup))) _Alignas(32) long v = 9;
+	}
+	if (cleaned != 9)
+		return 8;
+	for (int i = 1; i < 5; i++)
+		if (vla(i) != i - 1)
+			return 9;
+	return 0;
+}
@@ -0,0 +1,45 @@
+// FLAGS: -Werror
+
+int
+once(void)
+{
+	do {
+		return 1;
+	} while (0);
+}
+
+int
+forever(int x)
+{
+	do {
+		if (x > 10)
+			return x;
+		x++;
+		continue;
+	} while (1);
+}
+
+int
+nested(int x)
+{
+	do {
+		do {
+			if (x)
+				break;
+			return 2;
+		} while (0);
+		return x;
+	} while (0);
+}
+
+int
+main()
+{
+	if (once() != 1)
+		return 1;
+	if (forever(0) != 11)
+		return 2;
+	if (nested(0) != 2 || nested(3) != 3)
+		return 3;
+	return 0;
+}
@@ -0,0 +1,95 @@
+struct a {
+	char c;
+	int x : 3;
+	int y : 30;
+	long z : 64;
+} __attribute__((packed));
+
+struct b {
+	char c;
+	int x : 31 __attribute__((packed));
+	char d;
+};
+
+struct c {
+	char c;
+	int : 0;
+	char d;
+	unsigned x : 5;
+} __attribute__((packed));
+
+struct d {
+	char c;
+	long x : 60;
+	unsigned long y : 63;
+} __attribute__((packed));
+
+union u {
+	long x : 12;
+	char c;
+} __attribute__((packed));
+
+struct guarded {
+	char before;
+	struct a a;
+	char after;
+} __attribute__((packed));
+
+struct a ga = {1, -2, 123456789, -5};
+struct d gd = {3, -1000, 0x7fffffffffffffffUL};
+
+int
+main()
+{
+	struct guarded g = {0x55, {2, 3, -7, 0x123456789abcdefL}, 0x66};
+	struct b b = {1, (1 << 29) + 5, 2};
+	struct c c = {1, 2, 17};
+	struct d d;
+	union u u;
+
+	if (sizeof(struct a) != 14 || sizeof(struct b) != 6)
+		return 1;
+	if (sizeof(struct c) != 6 || sizeof(struct d) != 17 || sizeof(union u) != 2)
+		return 2;
+	if (_Alignof(struct b) != 1)
+		return 3;
+	if (ga.c != 1 || ga.x != -2 || ga.y != 123456789 || ga.z != -5)
+		return 4;
+	if (gd.c != 3 || gd.x != -1000 || gd.y != 0x7fffffffffffffffUL)
+		return 5;
+	if (g.a.c != 2 || g.a.x != 3 || g.a.y != -7 || g.a.z != 0x123456789abcdefL)
+		return 6;
+	g.a.x = -4;
+	g.a.y += 8;
+	g.a.z = -1;
+	if (g.a.x != -4 || g.a.y != 1 || g.a.z != -1 || g.a.c != 2)
+		return 7;
+	if (g.before != 0x55 || g.after != 0x66)
+		return 8;
+	g.a.z = 0;
+	g.a.y = 0;
+	if (g.a.x != -4 || g.after != 0x66)
+		return 9;
+	if (b.c != 1 || b.x != (1 << 29) + 5 || b.d != 2)
+		return 10;
+	b.x = -1;
+	if (b.x != -1 || b.c != 1 || b.d != 2)
+		return 11;
+	if (c.c != 1 || c.d != 2 || c.x != 17)
+		return 12;
+	c.x++;
+	if (c.x != 18 || c.d != 2)
+		return 13;
+	d.c = 9;
+	d.x = 0x7ffffffffffffffL;
+	d.y = 1;
+	if (d.c != 9 || d.x != 0x7ffffffffffffffL || d.y != 1)
+		return 14;
+	d.x = -d.x - 1;
+	if (d.x != -0x800000000000000L || d.y != 1)
+		return 15;
+	u.x = -3;
+	if (u.x != -3 || u.c != -3)
+		return 16;
+	return 0;
+}
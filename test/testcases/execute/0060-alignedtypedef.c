@@ -0,0 +1,64 @@
+typedef int T __attribute__((aligned(8)));
+typedef T U;
+typedef const T CT;
+typedef struct { char c; } S __attribute__((aligned(32)));
+typedef char A[3] __attribute__((aligned(16)));
+typedef long L __attribute__((aligned(2)));
+
+struct m {
+	char c;
+	T t;
+	S s;
+};
+
+struct p {
+	char c;
+	L l;
+};
+
+T gt = 5;
+S gs;
+char pad;
+A ga;
+
+int
+misaligned(void *p, unsigned long align)
+{
+	return (unsigned long)p % align != 0;
+}
+
+int
+add(T a, U b)
+{
+	return a + b;
+}
+
+int
+main()
+{
+	T t = 3;
+	U u = 4;
+	CT ct = 5;
+	S s;
+	A a;
+
+	if (_Alignof(T) != 8 || _Alignof(U) != 8 || _Alignof(CT) != 8 || sizeof(T) != 4)
+		return 1;
+	if (_Alignof(S) != 32 || sizeof(S) != 1 || _Alignof(A) != 16 || sizeof(A) != 3)
+		return 2;
+	if (_Alignof(L) != 2 || sizeof(struct p) != 10)
+		return 3;
+	if (__builtin_offsetof(struct m, t) != 8 || __builtin_offsetof(struct m, s) != 32)
+		return 4;
+	if (_Alignof(struct m) != 32 || sizeof(struct m) != 64)
+		return 5;
+	if (misaligned(&gt, 8) || misaligned(&gs, 32) || misaligned(&ga, 16))
+		return 6;
+	if (misaligned(&t, 8) || misaligned(&u, 8) || misaligned((void *)&ct, 8))
+		return 7;
+	if (misaligned(&s, 32) || misaligned(&a, 16))
+		return 8;
+	if (add(t, u) + ct + gt != 17)
+		return 9;
+	return 0;
+}
@@ -0,0 +1,55 @@
+int
+f3(int a, int b, int c)
+{
+	return a * 100 + b * 10 + c;
+}
+
+int
+sum(int n)
+{
+	return 5 + ({
+		char b[n];
+		b[0] = 3;
+		b[0];
+	});
+}
+
+int
+call(int n)
+{
+	return f3(1, 2, ({
+		char b[n];
+		b[0] = 3;
+		b[0];
+	}));
+}
+
+int
+nested(int n)
+{
+	return 1 + (2 * ({
+		long a[n];
+		a[n - 1] = 4;
+		a[n - 1] + ({
+			char b[n * 3];
+			b[0] = 5;
+			b[0];
+		});
+	}));
+}
+
+int
+main()
+{
+	int i;
+
+	for (i = 1; i < 40; i += 7) {
+		if (sum(i) != 8)
+			return 1;
+		if (call(i) != 123)
+			return 2;
+		if (nested(i) != 19)
+			return 3;
+	}
+	return 0;
+}
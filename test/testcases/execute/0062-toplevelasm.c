@@ -0,0 +1,19 @@
+char *msg = "hello";
+
+asm(".globl answer\n"
+    ".type answer, @function\n"
+    "answer:\n"
+    "\tmovl $42, %eax\n"
+    "\tret");
+
+int answer(void);
+
+int
+main()
+{
+	if (msg[0] != 'h')
+		return 1;
+	if (answer() != 42)
+		return 2;
+	return 0;
+}
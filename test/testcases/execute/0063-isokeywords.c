@@ -0,0 +1,17 @@
+// FLAGS: -std=c11
+
+int typeof = 1;
+
+int
+asm(int asm)
+{
+	return asm + 1;
+}
+
+int
+main()
+{
+	int x = typeof;
+
+	return asm(x) - 2;
+}
@@ -0,0 +1,80 @@
+struct a {
+	char c;
+	int x : 3 __attribute__((aligned(8)));
+	char d;
+};
+
+struct b {
+	char c;
+	int x : 3 __attribute__((aligned(2)));
+	int y : 5;
+};
+
+struct c {
+	char c;
+	int x : 3 __attribute__((aligned(16)));
+} __attribute__((packed));
+
+struct d {
+	char c;
+	int : 3 __attribute__((aligned(8)));
+	char d;
+};
+
+struct e {
+	char c;
+	int x : 30 __attribute__((packed, aligned(8)));
+	int y : 30 __attribute__((packed));
+};
+
+union u {
+	char c;
+	int x : 3 __attribute__((aligned(8)));
+};
+
+int
+main()
+{
+	struct a a = {1, 3, 2};
+	struct b b = {1, 3, 9};
+	struct c c = {1, -2};
+	struct d d = {1, 2};
+	struct e e = {1, 5, 3};
+	union u u;
+	unsigned char *p;
+
+	if (sizeof(struct a) != 16 || _Alignof(struct a) != 8)
+		return 1;
+	if (sizeof(struct b) != 4 || _Alignof(struct b) != 4)
+		return 2;
+	if (sizeof(struct c) != 32 || _Alignof(struct c) != 16)
+		return 3;
+	if (sizeof(struct d) != 10 || _Alignof(struct d) != 1)
+		return 4;
+	if (sizeof(struct e) != 16 || _Alignof(struct e) != 8)
+		return 5;
+	if (sizeof(union u) != 8 || _Alignof(union u) != 8)
+		return 6;
+	p = (unsigned char *)&a;
+	if (p[8] != 3 || p[9] != 2 || a.x != 3 || a.d != 2)
+		return 7;
+	p = (unsigned char *)&b;
+	if (p[1] != 0 || p[2] != 0x4b || b.x != 3 || b.y != 9)
+		return 8;
+	p = (unsigned char *)&c;
+	if (p[16] != 6 || c.x != -2)
+		return 9;
+	p = (unsigned char *)&d;
+	if (p[9] != 2 || d.d != 2)
+		return 10;
+	p = (unsigned char *)&e;
+	if (p[8] != 5 || p[11] != 0xc0 || e.x != 5 || e.y != 3)
+		return 11;
+	e.y = -1;
+	if (e.x != 5 || e.y != -1)
+		return 12;
+	u.x = 3;
+	if (u.c != 3)
+		return 13;
+	return 0;
+}
@@ -0,0 +1,38 @@
+enum colour { RED, GREEN, BLUE, WHITE };
+enum sign { NEG = -2, POS = 1 };
+
+struct paint {
+	enum colour c : 2;
+	enum sign s : 2;
+	enum colour d : 3;
+};
+
+int sizeofpaint(void);
+int checkpaint(struct paint *p);
+void setpaint(struct paint *p);
+
+int
+main()
+{
+	struct paint p;
+
+	if (sizeof(struct paint) != sizeofpaint())
+		return 1;
+	p.c = BLUE;
+	p.s = NEG;
+	p.d = WHITE;
+	if (p.c != 2 || p.s != -2 || p.d != 3)
+		return 2;
+	if (!checkpaint(&p))
+		return 3;
+	setpaint(&p);
+	if (p.c != WHITE || p.c != 3 || p.s != POS || p.d != GREEN)
+		return 4;
+	p.c++;
+	if (p.c != RED)
+		return 5;
+	// Without negative enumerators the enum is unsigned.
+	if ((enum colour)-1 < 0 || (enum sign)-1 > 0)
+		return 6;
+	return 0;
+}
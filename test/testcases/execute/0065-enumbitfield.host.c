@@ -0,0 +1,24 @@
+enum colour { RED, GREEN, BLUE, WHITE };
+enum sign { NEG = -2, POS = 1 };
+
+struct paint {
+	enum colour c : 2;
+	enum sign s : 2;
+	enum colour d : 3;
+};
+
+int sizeofpaint() { return sizeof(struct paint); }
+
+int
+checkpaint(struct paint *p)
+{
+	return p->c == BLUE && p->s == NEG && p->d == WHITE;
+}
+
+void
+setpaint(struct paint *p)
+{
+	p->c = WHITE;
+	p->s = POS;
+	p->d = GREEN;
+}